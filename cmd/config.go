@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func NewConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Work with config files",
+	}
+
+	cmd.AddCommand(NewConfigValidateCommand())
+
+	return cmd
+}
+
+func NewConfigValidateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check a config file without starting the bot",
+		Long:  "Check a config file, with the GERRY_ environment variables applied, for unknown fields and invalid values, listing every problem found. Exits with an error if the config is invalid.",
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := cmd.Flag("config").Value.String()
+
+			cfg, err := config.Read(path)
+			if err != nil {
+				return err
+			}
+
+			if sources, _ := cmd.Flags().GetBool("sources"); sources {
+				for _, source := range cfg.Sources() {
+					from := string(source.Source)
+					if source.Var != "" {
+						from += " " + source.Var
+					}
+					fmt.Fprintf(cmd.OutOrStdout(), "%s = %s (%s)\n", source.Path, source.Value, from)
+				}
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "%s is valid\n", path)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("config", "c", "config.yaml", "config file to check")
+	cmd.Flags().BoolP("sources", "s", false, "print every value and where it was set, with secrets redacted")
+
+	return cmd
+}
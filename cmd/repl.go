@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/distrobyte/gerry/internal/bot"
+	"github.com/distrobyte/gerry/internal/cli"
+	"github.com/distrobyte/gerry/internal/config"
+)
+
+func NewReplCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "repl",
+		Short: "Run commands from the terminal",
+		Long:  "Read commands from stdin and print the responses, using the provided config file and its data directory. Discord, mumble and the HTTP endpoint are not started.",
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(cmd.Flag("config").Value.String())
+			if err != nil {
+				return err
+			}
+
+			// the terminal is only for responses, so logs stay quiet unless asked for
+			log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+			zerolog.SetGlobalLevel(zerolog.WarnLevel)
+			if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
+				zerolog.SetGlobalLevel(zerolog.DebugLevel)
+			}
+
+			// a running bot may already be listening on the port
+			cfg.HTTP.Enable = false
+
+			b, err := bot.New(cfg)
+			if err != nil {
+				return err
+			}
+
+			author, _ := cmd.Flags().GetString("author")
+
+			var prompt string
+			if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice != 0 {
+				prompt = "gerry> "
+			}
+
+			b.UsePlatforms(cli.New(cfg, b, cmd.InOrStdin(), cmd.OutOrStdout(), author, prompt, b.Stop))
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return b.Start(ctx)
+		},
+	}
+
+	cmd.Flags().StringP("config", "c", "config.yaml", "config file to use")
+	cmd.Flags().StringP("author", "a", defaultAuthor(), "author the commands are sent as, matched against the cli users in permissions")
+	cmd.Flags().BoolP("verbose", "v", false, "log every command")
+
+	return cmd
+}
+
+func defaultAuthor() string {
+	if user := os.Getenv("USER"); user != "" {
+		return user
+	}
+
+	return "cli"
+}
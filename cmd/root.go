@@ -35,6 +35,8 @@ func RootCmd() *cobra.Command {
 	addCmd(NewVersionCommand())
 	addCmd(NewStartCommand())
 	addCmd(NewConfgenCommand())
+	addCmd(NewConfigCommand())
+	addCmd(NewReplCommand())
 
 	cmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 
@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -12,34 +15,81 @@ import (
 	"github.com/distrobyte/gerry/internal/config"
 )
 
+// configWatchInterval is how often the config file is checked for changes
+// with --watch
+const configWatchInterval = 2 * time.Second
+
 func NewStartCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "start",
 		Short: "Start the bot",
-		Long:  "Start the bot with the provided config file",
+		Long:  "Start the bot with the provided config file. The config file is reloaded on SIGHUP, or whenever it changes with --watch.",
 
 		RunE: func(cmd *cobra.Command, args []string) error {
-			err := config.Load(cmd.Flag("config").Value.String())
+			path := cmd.Flag("config").Value.String()
+			cfg, err := config.Load(path)
 			if err != nil {
 				return err
 			}
 
-			config.StartTime = time.Now()
-
 			zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 			zerolog.SetGlobalLevel(zerolog.InfoLevel)
 
-			if config.GetEnvironment() == config.APP_ENVIRONMENT_LOCAL {
+			if cfg.GetEnvironment() == config.APP_ENVIRONMENT_LOCAL {
 				log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 				zerolog.SetGlobalLevel(zerolog.DebugLevel)
 				log.Debug().Msg("running locally in debug mode")
 			}
+			cfg.LogSources()
+
+			b, err := bot.New(cfg)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			hup := make(chan os.Signal, 1)
+			signal.Notify(hup, syscall.SIGHUP)
+			defer signal.Stop(hup)
 
-			return bot.Start()
+			var changed <-chan struct{}
+			if watch, _ := cmd.Flags().GetBool("watch"); watch {
+				changed = config.Watch(ctx, path, configWatchInterval)
+			}
+			go reloadConfig(ctx, b, path, hup, changed)
+
+			return b.Start(ctx)
 		},
 	}
 
 	cmd.Flags().StringP("config", "c", "config.yaml", "config file to use")
+	cmd.Flags().BoolP("watch", "w", false, "reload the config file whenever it changes")
 
 	return cmd
 }
+
+// reloadConfig reloads the config file at path into b on every signal on
+// hup and change on changed, until ctx is done. Invalid config is logged
+// and the running config kept.
+func reloadConfig(ctx context.Context, b *bot.Bot, path string, hup <-chan os.Signal, changed <-chan struct{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Info().Str("file", path).Msg("reloading config on SIGHUP")
+		case <-changed:
+			log.Info().Str("file", path).Msg("config file changed, reloading")
+		}
+
+		next, err := config.Read(path)
+		if err != nil {
+			log.Error().Err(err).Str("file", path).Msg("not reloading invalid config, keeping the running config")
+			continue
+		}
+
+		b.Reload(next)
+	}
+}
@@ -1,11 +1,14 @@
 package http
 
 import (
+	"encoding/json"
+	"expvar"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/platform"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
@@ -14,21 +17,42 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func initHTTPServer() {
+func initHTTPServer(cfg *config.Config, webhooks map[string]http.Handler, connections func() []platform.Status) {
 
 	r := chi.NewRouter()
 	r.Use(requestIDMiddleware)
 	r.Use(zerologMiddleware)
 
-	r.Get("/health", healthHandler)
+	r.Get("/health", healthHandler(connections))
+
+	// platforms receiving events over HTTP
+	for path, handler := range webhooks {
+		r.Handle(path, handler)
+	}
 
 	// Serve assets directory from root
 	// This allows direct access to files like /elo.html, /elo.png, /karting.json, etc.
-	r.Handle("/*", http.FileServer(http.Dir("assets")))
+	r.Handle("/*", http.FileServer(http.Dir(cfg.GetDataDir())))
+
+	if addr := cfg.GetHTTPDebugAddr(); addr != "" {
+		go serveDebug(addr)
+	}
 
-	log.Info().Msgf("Starting server on port %d", config.GetHTTPPort())
-	log.Fatal().Err(http.ListenAndServe(fmt.Sprintf(":%d", config.GetHTTPPort()), r)).Msg("")
-	log.Info().Msgf("Server started on port %d", config.GetHTTPPort())
+	log.Info().Msgf("Starting server on port %d", cfg.GetHTTPPort())
+	log.Fatal().Err(http.ListenAndServe(fmt.Sprintf(":%d", cfg.GetHTTPPort()), r)).Msg("")
+	log.Info().Msgf("Server started on port %d", cfg.GetHTTPPort())
+}
+
+// serveDebug serves metrics on a listener of their own, so they are not
+// exposed along with the public endpoint.
+func serveDebug(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	log.Info().Str("addr", addr).Msg("serving metrics")
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Error().Err(err).Str("addr", addr).Msg("failed to serve metrics")
+	}
 }
 
 func requestIDMiddleware(next http.Handler) http.Handler {
@@ -62,20 +86,60 @@ func zerologMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func ServeHTTP() {
-	initHTTPServer()
+// ServeHTTP serves the health check, data directory and the given webhook
+// handlers keyed by path, and metrics on the debug address. The health check reports the platform
+// connections returned by connections.
+func ServeHTTP(cfg *config.Config, webhooks map[string]http.Handler, connections func() []platform.Status) {
+	initHTTPServer(cfg, webhooks, connections)
 
-	err := http.ListenAndServe(fmt.Sprintf(":%d", config.GetHTTPPort()), nil)
+	err := http.ListenAndServe(fmt.Sprintf(":%d", cfg.GetHTTPPort()), nil)
 	if err != nil {
 		log.Fatal().Err(err).Msg("")
 	}
 }
 
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/plain")
-	w.WriteHeader(http.StatusOK)
-	_, err := w.Write([]byte("OK"))
-	if err != nil {
-		hlog.FromRequest(r).Error().Err(err).Msg("")
+type health struct {
+	Status    string           `json:"status"`
+	Platforms []platformStatus `json:"platforms"`
+}
+
+type platformStatus struct {
+	Name       string    `json:"name"`
+	State      string    `json:"state"`
+	Since      time.Time `json:"since"`
+	Reconnects int       `json:"reconnects"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// healthHandler reports the state of every platform connection. It
+// responds 503 while any of them is not connected, so orchestrators can
+// tell a bot that lost a platform from a healthy one.
+func healthHandler(connections func() []platform.Status) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := health{Status: "ok", Platforms: []platformStatus{}}
+		for _, status := range connections() {
+			if status.State != platform.StateConnected {
+				resp.Status = "degraded"
+			}
+
+			ps := platformStatus{
+				Name:       status.Platform,
+				State:      status.State.String(),
+				Since:      status.Since,
+				Reconnects: status.Reconnects,
+			}
+			if status.Err != nil {
+				ps.Error = status.Err.Error()
+			}
+			resp.Platforms = append(resp.Platforms, ps)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if resp.Status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			hlog.FromRequest(r).Error().Err(err).Msg("")
+		}
 	}
 }
@@ -1,93 +1,384 @@
 package bot
 
 import (
+	"context"
 	"fmt"
+	nethttp "net/http"
 	"os"
-	"os/signal"
-	"syscall"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/distrobyte/gerry/http"
+	"github.com/distrobyte/gerry/internal/bridge"
+	"github.com/distrobyte/gerry/internal/commands"
+	"github.com/distrobyte/gerry/internal/commands/middleware"
 	"github.com/distrobyte/gerry/internal/config"
-	"github.com/distrobyte/gerry/internal/discord"
-	"github.com/distrobyte/gerry/internal/handlers"
-	"github.com/distrobyte/gerry/internal/mumble"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/distrobyte/gerry/internal/ratelimit"
 	"github.com/rs/zerolog/log"
-	"layeh.com/gumble/gumbleutil"
 )
 
-func Start() error {
-	log.Info().Msg("bot initializing...")
+// how long shutdown waits for in-flight commands to notice they were cancelled
+const shutdownGracePeriod = 10 * time.Second
 
-	if config.IsEnvironment(config.APP_ENVIRONMENT_TEST) {
-		log.Info().Msg("app environment is test, aborting startup")
-		return fmt.Errorf("app environment is test")
+// Bot is a single running instance of gerry. It owns its config, platform
+// connections, command registry and feature state, so several bots can run
+// side by side in one process.
+type Bot struct {
+	config     *config.Config
+	registry   *commands.Registry
+	karting    *commands.Karting
+	kek        *commands.Kek
+	identities *commands.Identities
+	limiter    *ratelimit.Limiter
+	history    *commands.History
+	bridge     *bridge.Bridge
+	platforms  []platform.Platform
+	// supervisor tracks and restores the connections of the platforms
+	supervisor *supervisor
+	// noDrivers skips the platforms enabled in config
+	noDrivers bool
+	startTime time.Time
+	now       func() time.Time
+
+	// ctx is the context every platform event and command runs under, and
+	// cancel stops it
+	ctx      context.Context
+	cancel   context.CancelFunc
+	mu       sync.Mutex
+	stopping bool
+	inflight sync.WaitGroup
+
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+
+	// reloads are handed to the running bot, and stopped is closed once it
+	// no longer runs
+	reloads chan reloadRequest
+	stopped chan struct{}
+}
+
+// reloadRequest asks the running bot to apply next, with the changes sent
+// back on done.
+type reloadRequest struct {
+	next *config.Config
+	done chan []config.Change
+}
+
+// New creates a bot from cfg with the built-in commands registered and the
+// default middleware stack in front of them.
+func New(cfg *config.Config) (*Bot, error) {
+	registry := commands.NewRegistry()
+	if err := registry.Register(commands.Builtin()...); err != nil {
+		return nil, err
 	}
 
-	if config.IsDiscordEnabled() {
-		discord.InitSession()
+	registry.OnReaction(commands.BuiltinReactions()...)
+
+	registry.Use(
+		middleware.Logger,
+		middleware.Recoverer,
+		middleware.Metrics,
+		middleware.Permissions,
+		middleware.ValidateArgs,
+		middleware.RateLimit,
+		middleware.Timeout,
+		middleware.Typing,
+	)
+
+	b := &Bot{
+		config:   cfg,
+		registry: registry,
+		limiter:  ratelimit.New(cfg.GetRateLimit()),
+		history:  commands.NewHistory(),
+		now:      time.Now,
+		shutdown: make(chan struct{}),
+		reloads:  make(chan reloadRequest),
+		stopped:  make(chan struct{}),
 	}
+	b.supervisor = newSupervisor(func() time.Time { return b.now() })
+	b.bridge = bridge.New(cfg, b.registry, b.platformNamed)
 
-	if config.IsMumbleEnabled() {
-		mumble.InitSession()
+	return b, nil
+}
+
+// Registry returns the command registry of the bot.
+func (b *Bot) Registry() *commands.Registry {
+	return b.registry
+}
+
+// AddPlatform adds a platform that is connected on Start in addition to the
+// ones enabled in config.
+func (b *Bot) AddPlatform(p platform.Platform) {
+	b.platforms = append(b.platforms, p)
+}
+
+// SetClock replaces the clock the bot reads the time from. It must be called
+// before Start and is meant for tests.
+func (b *Bot) SetClock(now func() time.Time) {
+	b.now = now
+}
+
+// SetReconnectBackoff bounds the wait between attempts to reconnect a
+// platform that dropped. It must be called before Start and is meant for
+// tests.
+func (b *Bot) SetReconnectBackoff(min, max time.Duration) {
+	b.supervisor.minBackoff = min
+	b.supervisor.maxBackoff = max
+}
+
+// Connections returns the state of the connection of every platform.
+func (b *Bot) Connections() []platform.Status {
+	return b.supervisor.snapshot()
+}
+
+// UsePlatforms replaces the platforms enabled in config with platforms, so
+// only those are connected on Start.
+func (b *Bot) UsePlatforms(platforms ...platform.Platform) {
+	b.platforms = platforms
+	b.noDrivers = true
+}
+
+// Start connects to every platform and blocks until ctx is done or the bot
+// is stopped. In-flight commands are cancelled before disconnecting.
+func (b *Bot) Start(ctx context.Context) error {
+	defer close(b.stopped)
+
+	log.Info().Msg("bot initializing...")
+
+	if b.config.IsEnvironment(config.APP_ENVIRONMENT_TEST) {
+		log.Info().Msg("app environment is test, aborting startup")
+		return fmt.Errorf("app environment is test")
 	}
 
-	addHandlers()
+	b.startTime = b.now()
+	b.karting = commands.NewKarting(b.config.GetDataDir())
+	moveState(b.config.GetDataDir(), b.config.GetStateDir())
+	b.kek = commands.NewKek(b.config.GetStateDir())
+	b.identities = commands.NewIdentities(b.config.GetStateDir())
 
-	handlers.InitCommands()
+	if !b.noDrivers {
+		for _, driver := range platform.Drivers() {
+			if driver.Enabled(b.config) {
+				b.platforms = append(b.platforms, driver.New(b.config, b))
+			}
+		}
+	}
 
-	if config.IsHTTPEndpointEnabled() {
-		go http.ServeHTTP()
+	if b.config.IsHTTPEndpointEnabled() {
+		go http.ServeHTTP(b.config, b.webhooks(), b.Connections)
 	}
 
-	if config.IsDiscordEnabled() {
-		discord.InitConnection()
+	ctx, b.cancel = context.WithCancel(ctx)
+	defer b.cancel()
+	b.ctx = ctx
+
+	// a platform that is down at startup is retried in the background, so
+	// it does not keep the others from running
+	for _, p := range b.platforms {
+		if err := b.supervisor.connect(ctx, p); err != nil {
+			b.HandleState(p, platform.StateDisconnected, err)
+		}
 	}
 
 	log.Info().
-		Str("environment", config.GetEnvironment()).
+		Str("environment", b.config.GetEnvironment()).
 		Str("event", "startup").
 		Msg("bot is running. press CTRL+C to exit.")
 
-	signal.Notify(config.ShutdownChannel, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
-	<-config.ShutdownChannel
+	for running := true; running; {
+		select {
+		case <-ctx.Done():
+			running = false
+		case <-b.shutdown:
+			running = false
+		case req := <-b.reloads:
+			req.done <- b.reload(req.next)
+		}
+	}
 	log.Info().Msg("shutting down...")
 
-	if config.IsDiscordEnabled() {
-		err := discord.DiscordSession.Close()
-		if err != nil {
-			log.Error().Err(err).Msg("discord close error")
+	b.cancelInflight()
+	b.disconnect(b.platforms)
+
+	log.Info().Msg("goodbye")
+	return nil
+}
+
+// Reload applies the settings of next that can change while the bot runs,
+// such as the prefix, status, permissions, cooldowns and features, and
+// logs the ones that need a restart, which keep their old value. next must
+// be valid. It waits until the bot is running and connected, and returns
+// the changes, or nil if the bot stopped first.
+func (b *Bot) Reload(next *config.Config) []config.Change {
+	req := reloadRequest{next: next, done: make(chan []config.Change, 1)}
+
+	select {
+	case b.reloads <- req:
+		return <-req.done
+	case <-b.stopped:
+		return nil
+	}
+}
+
+func (b *Bot) reload(next *config.Config) []config.Change {
+	changes := b.config.Reload(next)
+
+	applied := 0
+	rateChanged := false
+	for _, change := range changes {
+		if change.Restart {
+			log.Warn().Str("field", change.Path).Msg("config change needs a restart to take effect")
+			continue
 		}
 
-		log.Info().Msg("discord closed")
+		log.Info().Str("field", change.Path).Msg("config change applied")
+		applied++
+		if change.Path == "ratelimit.rate" || change.Path == "ratelimit.burst" {
+			rateChanged = true
+		}
 	}
 
-	if config.IsMumbleEnabled() {
-		err := mumble.MumbleSession.Disconnect()
-		if err != nil {
-			log.Error().Err(err).Msg("mumble disconnect error")
+	if rateChanged {
+		b.limiter.SetRate(b.config.GetRateLimit())
+	}
+
+	if applied > 0 {
+		for _, p := range b.platforms {
+			if reloader, ok := p.(platform.Reloader); ok {
+				if err := reloader.Reload(); err != nil {
+					log.Warn().Err(err).Str("platform", p.Name()).Msg("failed to apply reloaded config")
+				}
+			}
 		}
+	}
+
+	log.Info().Int("applied", applied).Int("restart", len(changes)-applied).Msg("config reloaded")
+	return changes
+}
+
+// Stop asks a running bot to shut down. It is safe to call more than once.
+func (b *Bot) Stop() {
+	b.shutdownOnce.Do(func() {
+		close(b.shutdown)
+	})
+}
+
+// track registers an in-flight event, returning false once the bot is
+// shutting down and no new work should start.
+func (b *Bot) track() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-		log.Info().Msg("mumble disconnected")
+	if b.stopping {
+		return false
+	}
+
+	b.inflight.Add(1)
+	return true
+}
+
+// cancelInflight stops new events from being handled, cancels the ones
+// running and waits a while for them to return.
+func (b *Bot) cancelInflight() {
+	b.mu.Lock()
+	b.stopping = true
+	b.mu.Unlock()
+
+	b.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		b.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(shutdownGracePeriod):
+		log.Warn().Msg("in-flight commands did not finish before shutdown")
+	}
+}
+
+// platformNamed returns the platform called name, or nil if it is not one
+// of the platforms of the bot.
+func (b *Bot) platformNamed(name string) platform.Platform {
+	for _, p := range b.platforms {
+		if p.Name() == name {
+			return p
+		}
 	}
 
-	log.Info().Msg("goodbye")
 	return nil
 }
 
-func addHandlers() {
-	// register hanlders as callbacks for various events
+// webhooks returns the HTTP handlers of the platforms receiving events over
+// the HTTP endpoint, keyed by path.
+func (b *Bot) webhooks() map[string]nethttp.Handler {
+	webhooks := make(map[string]nethttp.Handler)
+	for _, p := range b.platforms {
+		if webhook, ok := p.(platform.Webhook); ok && webhook.WebhookPath() != "" {
+			webhooks[webhook.WebhookPath()] = webhook
+		}
+	}
+
+	return webhooks
+}
+
+func (b *Bot) env() *commands.Env {
+	return &commands.Env{
+		Config:      b.config,
+		Registry:    b.registry,
+		Karting:     b.karting,
+		Kek:         b.kek,
+		Identities:  b.identities,
+		Limiter:     b.limiter,
+		History:     b.history,
+		StartTime:   b.startTime,
+		Now:         b.now,
+		Shutdown:    b.Stop,
+		Connections: b.Connections,
+	}
+}
+
+func (b *Bot) disconnect(platforms []platform.Platform) {
+	for _, p := range platforms {
+		err := p.Disconnect()
+		if err != nil {
+			log.Error().Err(err).Str("platform", p.Name()).Msg("disconnect error")
+			continue
+		}
 
-	// discord
-	if config.IsDiscordEnabled() {
-		discord.DiscordSession.AddHandler(discord.ReadyHandler)
-		discord.DiscordSession.AddHandler(discord.MessageCreateHandler)
-		discord.DiscordSession.AddHandler(discord.MessageReactHandler)
+		log.Info().Str("platform", p.Name()).Msg("disconnected")
 	}
-	// mumble
-	if config.IsMumbleEnabled() {
-		mumble.MumbleSession.Config.Attach(gumbleutil.Listener{
-			Connect:     mumble.ReadyHandler,
-			TextMessage: mumble.MessageCreateHandler,
-		})
+}
+
+// stateFiles are the files of the state dir, which were kept in the data dir
+// before it had one.
+var stateFiles = []string{"kek.json", "identities.json"}
+
+// moveState moves the state files left in the data dir by older versions to
+// the state dir, so they are no longer served over HTTP.
+func moveState(dataDir, stateDir string) {
+	for _, name := range stateFiles {
+		from, to := filepath.Join(dataDir, name), filepath.Join(stateDir, name)
+		if _, err := os.Stat(from); err != nil {
+			continue
+		}
+		if _, err := os.Stat(to); err == nil {
+			log.Warn().Str("file", from).Str("kept", to).Msg("state file left in data dir, remove it")
+			continue
+		}
+
+		err := os.MkdirAll(stateDir, 0755)
+		if err == nil {
+			err = os.Rename(from, to)
+		}
+		if err != nil {
+			log.Error().Err(err).Str("file", from).Msg("failed to move state file out of data dir")
+			continue
+		}
+		log.Info().Str("from", from).Str("to", to).Msg("moved state file out of data dir")
 	}
 }
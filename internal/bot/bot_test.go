@@ -0,0 +1,90 @@
+package bot_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/bot"
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/distrobyte/gerry/internal/testkit"
+)
+
+func TestStartWithPlatformDown(t *testing.T) {
+	b, err := bot.New(&config.Config{
+		Prefix:      ">",
+		Environment: config.APP_ENVIRONMENT_LOCAL,
+		DataDir:     t.TempDir(),
+		StateDir:    t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	up := testkit.NewPlatform("up", b)
+	down := testkit.NewPlatform("down", b)
+	down.FailConnect(errors.New("connection refused"), errors.New("connection refused"))
+	b.SetReconnectBackoff(time.Millisecond, 10*time.Millisecond)
+	b.UsePlatforms(up, down)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("bot stopped with error: %v", err)
+		}
+	}()
+
+	select {
+	case <-up.Connected():
+	case err := <-done:
+		t.Fatalf("bot stopped while down was down: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		connected := 0
+		for _, status := range b.Connections() {
+			if status.State == platform.StateConnected {
+				connected++
+			}
+		}
+		if connected == 2 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Errorf("not every platform was connected: %+v", b.Connections())
+}
+
+func TestStateMovedOutOfDataDir(t *testing.T) {
+	dataDir := t.TempDir()
+	identities := `{"users": [{"id": "1", "name": "alice", "accounts": [` +
+		`{"platform": "discord", "id": "123", "name": "alice"}, {"platform": "fake", "id": "alice", "name": "alice_"}]}]}`
+	if err := os.WriteFile(filepath.Join(dataDir, "identities.json"), []byte(identities), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := testkit.New(t, func(cfg *config.Config) {
+		cfg.DataDir = dataDir
+	})
+
+	if _, err := os.Stat(filepath.Join(dataDir, "identities.json")); !os.IsNotExist(err) {
+		t.Errorf("identities.json is still in the data dir: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(h.StateDir, "identities.json")); err != nil {
+		t.Errorf("identities.json was not moved to the state dir: %v", err)
+	}
+	if got := h.Markdown("alice", ">whoami"); got != "you are alice: alice on discord, alice_ on fake" {
+		t.Errorf("whoami after moving got %q", got)
+	}
+}
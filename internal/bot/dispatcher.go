@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"context"
+
+	"github.com/distrobyte/gerry/internal/commands"
+	"github.com/distrobyte/gerry/internal/handlers"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/rs/zerolog/log"
+)
+
+// HandleMessage runs an incoming platform message through the command
+// handlers and replies on the platform it came from. ctx is the context of
+// the platform event and is cancelled when the bot shuts down.
+func (b *Bot) HandleMessage(ctx context.Context, p platform.Platform, message *models.Message) {
+	if !b.track() {
+		return
+	}
+	defer b.inflight.Done()
+
+	response, err := handlers.HandleMessage(ctx, b.env(), p, message)
+	if err != nil {
+		log.Error().Err(err).Str("platform", message.Platform).Msg("failed to handle message")
+		return
+	}
+
+	if !response.IsEmpty() {
+		if err := p.Reply(message, response); err != nil {
+			log.Error().Err(err).Str("platform", message.Platform).Msg("failed to send response")
+		}
+	}
+
+	b.bridge.Relay(message, response)
+}
+
+// HandleReaction runs a reaction being added or removed through the
+// reaction handlers and replies to the message reacted to.
+func (b *Bot) HandleReaction(ctx context.Context, p platform.Platform, reaction *models.MessageReaction) {
+	if !b.track() {
+		return
+	}
+	defer b.inflight.Done()
+
+	responses, err := handlers.HandleReaction(ctx, b.env(), p, reaction)
+	if err != nil {
+		log.Error().Err(err).Str("platform", p.Name()).Msg("failed to handle reaction")
+		return
+	}
+
+	for _, response := range responses {
+		if err := p.Reply(reaction.Message, response); err != nil {
+			log.Error().Err(err).Str("platform", p.Name()).Msg("failed to send response")
+		}
+	}
+}
+
+// HandleState records a change in the connection of a platform, and
+// connects it again when it dropped for good.
+func (b *Bot) HandleState(p platform.Platform, state platform.State, err error) {
+	b.supervisor.set(p, state, err)
+
+	if state != platform.StateDisconnected || !b.track() {
+		return
+	}
+
+	go func() {
+		defer b.inflight.Done()
+		b.supervisor.reconnect(b.ctx, p)
+	}()
+}
+
+// Complete suggests values for a command argument, for platforms with
+// autocompletion.
+func (b *Bot) Complete(ctx context.Context, arg *commands.Arg, value string) []string {
+	if !b.track() {
+		return nil
+	}
+	defer b.inflight.Done()
+
+	return handlers.HandleComplete(ctx, b.env(), arg, value)
+}
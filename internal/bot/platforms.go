@@ -0,0 +1,12 @@
+package bot
+
+// Platform adapters register themselves with the platform package when
+// imported. Add new adapters here to make them available to the bot.
+import (
+	_ "github.com/distrobyte/gerry/internal/discord"
+	_ "github.com/distrobyte/gerry/internal/irc"
+	_ "github.com/distrobyte/gerry/internal/matrix"
+	_ "github.com/distrobyte/gerry/internal/mumble"
+	_ "github.com/distrobyte/gerry/internal/slack"
+	_ "github.com/distrobyte/gerry/internal/telegram"
+)
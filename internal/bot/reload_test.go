@@ -0,0 +1,65 @@
+package bot_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/render"
+	"github.com/distrobyte/gerry/internal/testkit"
+)
+
+func TestReload(t *testing.T) {
+	h := testkit.New(t)
+
+	next := h.NextConfig()
+	next.Prefix = "!"
+	next.Status = "karting"
+	next.Permissions.Moderators.Users = map[string][]string{"fake": {"alice"}}
+	next.RateLimit.Cooldowns = map[string]config.Cooldown{"version": {User: time.Minute}}
+	next.Discord.Token = "new"
+
+	changes := h.Bot.Reload(next)
+
+	restart := make(map[string]bool)
+	for _, change := range changes {
+		restart[change.Path] = change.Restart
+	}
+	if len(changes) != 5 || !restart["discord.token"] || restart["prefix"] || restart["permissions.moderators.users"] {
+		t.Errorf("got changes %+v", changes)
+	}
+	if h.Config.GetDiscordToken() != "" {
+		t.Errorf("token was changed without a restart")
+	}
+	if h.Platform.Reloads() != 1 {
+		t.Errorf("platform reloaded %d times, want 1", h.Platform.Reloads())
+	}
+
+	if response := h.Send("alice", ">version"); response != nil {
+		t.Errorf("old prefix still works: %+v", response)
+	}
+	if response := h.Send("alice", "!version"); response == nil {
+		t.Fatal("new prefix does not work")
+	}
+	if got := h.Markdown("alice", "!version"); got != "try again in 60s" {
+		t.Errorf("cooldown not applied, got %q", got)
+	}
+	if got := h.Markdown("alice", "!karting reset"); got == "sorry, karting reset can only be used by moderators" {
+		t.Errorf("permissions not applied, got %q", got)
+	}
+}
+
+func TestReloadNothing(t *testing.T) {
+	h := testkit.New(t)
+
+	next := h.NextConfig()
+	if changes := h.Bot.Reload(next); len(changes) != 0 {
+		t.Errorf("got changes %+v", changes)
+	}
+	if h.Platform.Reloads() != 0 {
+		t.Errorf("platform reloaded without changes")
+	}
+	if got := render.Markdown(h.Send("alice", ">version")); got == "" {
+		t.Errorf("bot stopped responding after reload")
+	}
+}
@@ -0,0 +1,141 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/rs/zerolog/log"
+)
+
+// supervisor tracks the connection state of every platform, and connects
+// again the ones that dropped and cannot recover by themselves.
+type supervisor struct {
+	now func() time.Time
+	// minBackoff and maxBackoff bound the wait between reconnect attempts
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	mu           sync.Mutex
+	statuses     map[string]*platform.Status
+	order        []string
+	reconnecting map[string]bool
+}
+
+func newSupervisor(now func() time.Time) *supervisor {
+	return &supervisor{
+		now:          now,
+		minBackoff:   time.Second,
+		maxBackoff:   5 * time.Minute,
+		statuses:     make(map[string]*platform.Status),
+		reconnecting: make(map[string]bool),
+	}
+}
+
+// connect makes the first connection to p. If it fails, p is left
+// disconnected for the caller to reconnect.
+func (s *supervisor) connect(ctx context.Context, p platform.Platform) error {
+	s.set(p, platform.StateConnecting, nil)
+
+	if err := p.Connect(ctx); err != nil {
+		s.set(p, platform.StateDisconnected, err)
+		return err
+	}
+
+	s.set(p, platform.StateConnected, nil)
+	return nil
+}
+
+// set records a state change of p.
+func (s *supervisor) set(p platform.Platform, state platform.State, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status, ok := s.statuses[p.Name()]
+	if !ok {
+		status = &platform.Status{Platform: p.Name(), State: state, Since: s.now()}
+		s.statuses[p.Name()] = status
+		s.order = append(s.order, p.Name())
+	}
+
+	if state == status.State {
+		if err != nil {
+			status.Err = err
+		}
+		return
+	}
+
+	if state == platform.StateConnected && (status.State == platform.StateReconnecting || status.State == platform.StateDisconnected) {
+		status.Reconnects++
+	}
+
+	event := log.Info()
+	if state == platform.StateReconnecting || state == platform.StateDisconnected {
+		event = log.Warn().Err(err)
+	}
+	event.
+		Str("platform", p.Name()).
+		Str("from", status.State.String()).
+		Str("to", state.String()).
+		Msg("platform connection state changed")
+
+	status.State = state
+	status.Since = s.now()
+	status.Err = err
+}
+
+// reconnect calls Connect on p with backoff until it succeeds or ctx is
+// done. Only one reconnect runs per platform at a time.
+func (s *supervisor) reconnect(ctx context.Context, p platform.Platform) {
+	s.mu.Lock()
+	if s.reconnecting[p.Name()] {
+		s.mu.Unlock()
+		return
+	}
+	s.reconnecting[p.Name()] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.reconnecting, p.Name())
+		s.mu.Unlock()
+	}()
+
+	backoff := platform.Backoff{Min: s.minBackoff, Max: s.maxBackoff}
+	for {
+		wait := backoff.Next()
+		log.Info().Str("platform", p.Name()).Dur("backoff", wait).Msg("reconnecting platform")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		err := p.Connect(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			s.set(p, platform.StateConnected, nil)
+			return
+		}
+
+		s.set(p, platform.StateDisconnected, err)
+	}
+}
+
+// snapshot returns the status of every platform in the order they were
+// first connected.
+func (s *supervisor) snapshot() []platform.Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]platform.Status, 0, len(s.order))
+	for _, name := range s.order {
+		statuses = append(statuses, *s.statuses[name])
+	}
+
+	return statuses
+}
@@ -0,0 +1,138 @@
+// Package bridge mirrors conversation between linked channels on different
+// platforms, such as a discord channel and a mumble channel.
+package bridge
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/distrobyte/gerry/internal/commands"
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/handlers"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/distrobyte/gerry/internal/render"
+	"github.com/rs/zerolog/log"
+)
+
+// htmlPlatforms are the platforms whose messages are HTML rather than
+// markdown
+var htmlPlatforms = map[string]bool{"mumble": true}
+
+// relayedPattern matches the prefix of a relayed message, as markdown or
+// as HTML, capturing the platform it was relayed from
+var relayedPattern = regexp.MustCompile(`^(?:\*\*|<b>)?\[([a-z]+)\] `)
+
+// Bridge relays messages between the channels linked in config. Relayed
+// messages are prefixed with their platform and author, and are never
+// relayed again, so a bot or bridge echoing them back does not loop.
+type Bridge struct {
+	config *config.Config
+	// registry is the one commands are dispatched against, telling them
+	// from conversation that starts with the prefix
+	registry *commands.Registry
+	// platform returns the connected platform called name, or nil
+	platform func(name string) platform.Platform
+}
+
+// New returns a bridge sending through the platforms returned by lookup.
+func New(cfg *config.Config, registry *commands.Registry, lookup func(name string) platform.Platform) *Bridge {
+	return &Bridge{config: cfg, registry: registry, platform: lookup}
+}
+
+// Relay mirrors message to the channels linked with its channel. Commands
+// are only relayed when responses are, followed by response.
+func (b *Bridge) Relay(message *models.Message, response *models.Response) {
+	if strings.TrimSpace(message.Content) == "" {
+		return
+	}
+
+	targets := b.targets(message.Platform, message.Channel)
+	if len(targets) == 0 || b.relayed(message, targets) {
+		return
+	}
+
+	command := b.command(message)
+	if command && !b.config.IsBridgeResponsesEnabled() {
+		return
+	}
+
+	relay := &models.Response{
+		Text:     fmt.Sprintf("**[%s] %s:** %s", message.Platform, render.EscapeMarkdown(message.Author), markdown(message)),
+		Markdown: true,
+	}
+
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := b.platform(name)
+		if p == nil {
+			log.Warn().Str("platform", name).Msg("bridged platform is not connected")
+			continue
+		}
+
+		b.send(p, targets[name], relay)
+		if command && !response.IsEmpty() && !response.Ephemeral {
+			b.send(p, targets[name], response)
+		}
+	}
+}
+
+func (b *Bridge) send(p platform.Platform, channel string, response *models.Response) {
+	if err := p.Send(channel, response); err != nil {
+		log.Error().Err(err).Str("platform", p.Name()).Str("channel", channel).Msg("failed to relay message")
+	}
+}
+
+// command reports whether message runs a registered command, parsed the
+// way the handler does. Other messages starting with the prefix, such as
+// "> quotes", are conversation.
+func (b *Bridge) command(message *models.Message) bool {
+	_, _, ok := handlers.Command(b.registry, b.config.GetBotPrefix(), message.Content)
+	return ok
+}
+
+// targets returns the channels linked with channel, keyed by platform.
+func (b *Bridge) targets(source, channel string) map[string]string {
+	targets := make(map[string]string)
+	for _, link := range b.config.GetBridgeLinks() {
+		if link[source] != channel {
+			continue
+		}
+
+		for name, target := range link {
+			if name != source {
+				targets[name] = target
+			}
+		}
+	}
+
+	return targets
+}
+
+// relayed reports whether message was relayed from one of the linked
+// platforms, by a bridge sharing the channel with it.
+func (b *Bridge) relayed(message *models.Message, targets map[string]string) bool {
+	match := relayedPattern.FindStringSubmatch(message.Content)
+	if match == nil {
+		return false
+	}
+
+	_, linked := targets[match[1]]
+	return linked
+}
+
+// markdown returns the content of message as markdown.
+func markdown(message *models.Message) string {
+	if htmlPlatforms[message.Platform] {
+		return render.HTMLMarkdown(message.Content)
+	}
+
+	return message.Content
+}
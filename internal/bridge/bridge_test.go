@@ -0,0 +1,122 @@
+package bridge_test
+
+import (
+	"testing"
+
+	"github.com/distrobyte/gerry/internal/bridge"
+	"github.com/distrobyte/gerry/internal/commands"
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/distrobyte/gerry/internal/render"
+	"github.com/distrobyte/gerry/internal/testkit"
+)
+
+type bridged struct {
+	*bridge.Bridge
+	discord *testkit.Platform
+	mumble  *testkit.Platform
+}
+
+func newBridge(t *testing.T, responses bool) *bridged {
+	t.Helper()
+
+	registry := commands.NewRegistry()
+	if err := registry.Register(commands.Builtin()...); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Prefix: ">"}
+	cfg.Bridge.Responses = responses
+	cfg.Bridge.Links = []map[string]string{
+		{"discord": "123", "mumble": "0"},
+	}
+
+	b := &bridged{
+		discord: testkit.NewPlatform("discord", nil),
+		mumble:  testkit.NewPlatform("mumble", nil),
+	}
+	b.Bridge = bridge.New(cfg, registry, func(name string) platform.Platform {
+		switch name {
+		case "discord":
+			return b.discord
+		case "mumble":
+			return b.mumble
+		}
+		return nil
+	})
+
+	return b
+}
+
+func message(platform, channel, author, content string) *models.Message {
+	return &models.Message{Platform: platform, Channel: channel, Author: author, Content: content}
+}
+
+func TestRelay(t *testing.T) {
+	b := newBridge(t, false)
+
+	b.Relay(message("discord", "123", "alice", "**hello** from `discord` <3"), nil)
+	b.Relay(message("mumble", "0", "bob_", "<b>hi</b> 2 * 3<br />bye"), nil)
+	b.Relay(message("discord", "456", "carol", "not linked"), nil)
+
+	sent := b.mumble.Sent()
+	if len(sent) != 1 || sent[0].Channel != "0" {
+		t.Fatalf("mumble got %+v", sent)
+	}
+	if got, want := render.HTML(sent[0].Response, nil), "<b>[discord] alice:</b> <b>hello</b> from <code>discord</code> &lt;3"; got != want {
+		t.Errorf("mumble got %q, want %q", got, want)
+	}
+
+	sent = b.discord.Sent()
+	if len(sent) != 1 || sent[0].Channel != "123" {
+		t.Fatalf("discord got %+v", sent)
+	}
+	if got, want := sent[0].Response.Text, "**[mumble] bob\\_:** **hi** 2 \\* 3\nbye"; got != want {
+		t.Errorf("discord got %q, want %q", got, want)
+	}
+}
+
+func TestRelayNoEcho(t *testing.T) {
+	b := newBridge(t, false)
+
+	// what another bridge sharing the channels would send back
+	b.Relay(message("discord", "123", "otherbot", "**[mumble] bob:** hi"), nil)
+	b.Relay(message("mumble", "0", "otherbot", "<b>[discord] alice:</b> hi"), nil)
+
+	if sent := append(b.discord.Sent(), b.mumble.Sent()...); len(sent) != 0 {
+		t.Errorf("relayed messages were relayed again: %+v", sent)
+	}
+}
+
+func TestRelayCommands(t *testing.T) {
+	response := &models.Response{Title: "Karting stats"}
+
+	b := newBridge(t, false)
+	b.Relay(message("discord", "123", "alice", ">karting stats"), response)
+	// split like the handler does, which runs this as a command too
+	b.Relay(message("discord", "123", "alice", `>"karting" stats`), response)
+	if sent := b.mumble.Sent(); len(sent) != 0 {
+		t.Errorf("command was relayed: %+v", sent)
+	}
+
+	// quotes and unknown commands are conversation
+	b.Relay(message("discord", "123", "alice", "> nobody expects"), nil)
+	b.Relay(message("discord", "123", "alice", ">>the inquisition"), nil)
+	if sent := b.mumble.Sent(); len(sent) != 2 {
+		t.Errorf("mumble got %d messages, want 2", len(sent))
+	}
+
+	b = newBridge(t, true)
+	b.Relay(message("discord", "123", "alice", ">karting stats"), response)
+	b.Relay(message("discord", "123", "alice", ">help"), &models.Response{Text: "just for you", Ephemeral: true})
+
+	sent := b.mumble.Sent()
+	if len(sent) != 3 {
+		t.Fatalf("mumble got %d messages, want 3", len(sent))
+	}
+	if sent[0].Response.Text != "**[discord] alice:** >karting stats" || sent[1].Response != response ||
+		sent[2].Response.Text != "**[discord] alice:** >help" {
+		t.Errorf("mumble got %+v", sent)
+	}
+}
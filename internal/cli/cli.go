@@ -0,0 +1,165 @@
+// Package cli is a platform adapter that reads commands from a terminal,
+// used by the repl subcommand.
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/distrobyte/gerry/internal/render"
+	"github.com/rs/zerolog/log"
+)
+
+// Channel is the channel every message read from the terminal is sent in.
+const Channel = "cli"
+
+// CLI is the platform adapter for a terminal. Each line read is handled as
+// a message and the response is printed before the next prompt.
+type CLI struct {
+	config  *config.Config
+	handler platform.Handler
+	in      io.Reader
+	out     io.Writer
+	author  string
+	prompt  string
+	// done is called once the input is exhausted
+	done func()
+
+	outMu sync.Mutex
+}
+
+// New returns a CLI reading from in and writing to out. Messages are sent as
+// author and prompt is printed before each line is read. done is called
+// once in is exhausted.
+func New(cfg *config.Config, handler platform.Handler, in io.Reader, out io.Writer, author, prompt string, done func()) *CLI {
+	return &CLI{
+		config:  cfg,
+		handler: handler,
+		in:      in,
+		out:     out,
+		author:  author,
+		prompt:  prompt,
+		done:    done,
+	}
+}
+
+func (c *CLI) Name() string {
+	return "cli"
+}
+
+func (c *CLI) Connect(ctx context.Context) error {
+	go c.read(ctx)
+	return nil
+}
+
+func (c *CLI) Disconnect() error {
+	return nil
+}
+
+func (c *CLI) Send(channel string, response *models.Response) error {
+	c.outMu.Lock()
+	defer c.outMu.Unlock()
+
+	_, err := fmt.Fprintln(c.out, renderPlain(response))
+	return err
+}
+
+func (c *CLI) Reply(message *models.Message, response *models.Response) error {
+	return c.Send(message.Channel, response)
+}
+
+func (c *CLI) React(message *models.Message, emoji string) error {
+	return platform.ErrNotSupported
+}
+
+// read handles one line at a time until the input ends or ctx is done.
+func (c *CLI) read(ctx context.Context) {
+	if c.done != nil {
+		defer c.done()
+	}
+
+	scanner := bufio.NewScanner(c.in)
+	for {
+		c.printPrompt()
+
+		if !scanner.Scan() {
+			break
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		c.handler.HandleMessage(ctx, c, c.message(line))
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Error().Err(err).Str("platform", "cli").Msg("failed to read input")
+	}
+}
+
+// message wraps a line in a message. The prefix is optional in a terminal,
+// so it is added when missing.
+func (c *CLI) message(line string) *models.Message {
+	prefix := c.config.GetBotPrefix()
+	if !strings.HasPrefix(line, prefix) {
+		line = prefix + line
+	}
+
+	now := time.Now()
+	return &models.Message{
+		Content:    line,
+		Author:     c.author,
+		AuthorID:   c.author,
+		Channel:    Channel,
+		ID:         fmt.Sprint(now.UnixNano()),
+		Platform:   "cli",
+		RecievedAt: now,
+	}
+}
+
+func (c *CLI) printPrompt() {
+	if c.prompt == "" {
+		return
+	}
+
+	c.outMu.Lock()
+	defer c.outMu.Unlock()
+
+	fmt.Fprint(c.out, c.prompt)
+}
+
+// renderPlain renders a response as plain text, pointing images at the file
+// on disk rather than the URL it is served at.
+func renderPlain(response *models.Response) string {
+	text := *response
+	text.Images = nil
+
+	var lines []string
+	if plain := render.Plain(&text); plain != "" {
+		lines = append(lines, plain)
+	}
+
+	for _, image := range response.Images {
+		if image.Path != "" {
+			lines = append(lines, image.Path)
+			continue
+		}
+		lines = append(lines, image.URL)
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -1,7 +1,24 @@
 package commands
 
-import "strings"
+import (
+	"context"
+	"strings"
 
-func EchoCommand(args []string) string {
-	return strings.Join(args, " ")
+	"github.com/distrobyte/gerry/internal/models"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "echo",
+		Summary: "Repeat a message back",
+		Args: []Arg{
+			{Name: "text", Description: "Text to repeat", Variadic: true},
+		},
+		Examples: []string{"echo hello world"},
+		Handler:  EchoCommand,
+	})
+}
+
+func EchoCommand(ctx context.Context, req *Request) *models.Response {
+	return models.NewTextResponse(strings.Join(req.Args, " "))
 }
@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/distrobyte/gerry/internal/models"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "help",
+		Summary: "List commands or show usage for one",
+		Args: []Arg{
+			{Name: "command", Description: "Command to show usage for", Variadic: true},
+		},
+		Examples: []string{"help", "help karting race"},
+		Handler:  HelpCommand,
+	})
+}
+
+// HelpCommand lists every registered command, or shows the usage page of the
+// command named in the arguments.
+func HelpCommand(ctx context.Context, req *Request) *models.Response {
+	prefix := req.Env.Config.GetBotPrefix()
+
+	if len(req.Args) == 0 {
+		return models.NewTextResponse(helpIndex(req.Env.Registry, prefix))
+	}
+
+	cmd, rest, ok := req.Env.Registry.Resolve(req.Args)
+	if !ok {
+		return models.NewTextResponse(fmt.Sprintf("unknown command %q, use %shelp to list commands", req.Args[0], prefix))
+	}
+
+	if len(rest) > 0 {
+		return models.NewTextResponse(fmt.Sprintf("%s has no sub-command %q, use %shelp %s to list them", cmd.Path(), rest[0], prefix, cmd.Path()))
+	}
+
+	return models.NewTextResponse(helpPage(cmd, prefix))
+}
+
+func helpIndex(registry *Registry, prefix string) string {
+	var b strings.Builder
+
+	b.WriteString("Commands:\n")
+	for _, cmd := range registry.Commands() {
+		fmt.Fprintf(&b, "%s%s - %s\n", prefix, cmd.Name, cmd.Summary)
+	}
+	fmt.Fprintf(&b, "\nUse %shelp <command> for usage and examples.", prefix)
+
+	return b.String()
+}
+
+func helpPage(cmd *Command, prefix string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s - %s\n", cmd.Path(), cmd.Summary)
+	fmt.Fprintf(&b, "Usage: %s\n", cmd.Synopsis(prefix))
+
+	if cmd.Usage != "" {
+		fmt.Fprintf(&b, "%s\n", cmd.Usage)
+	}
+
+	if permission := cmd.RequiredPermission(); permission > PermissionEveryone {
+		fmt.Fprintf(&b, "Requires: %s\n", permission)
+	}
+
+	if len(cmd.Aliases) > 0 {
+		fmt.Fprintf(&b, "Aliases: %s\n", strings.Join(cmd.Aliases, ", "))
+	}
+
+	if len(cmd.Args) > 0 {
+		b.WriteString("\nArguments:\n")
+		for _, arg := range cmd.Args {
+			fmt.Fprintf(&b, "%s - %s", arg.Name, arg.Description)
+			if arg.Required {
+				b.WriteString(" (required)")
+			}
+			if len(arg.Choices) > 0 {
+				fmt.Fprintf(&b, " (one of: %s)", strings.Join(arg.Choices, ", "))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(cmd.SubCommands) > 0 {
+		b.WriteString("\nSub-commands:\n")
+		for _, sub := range cmd.SubCommands {
+			fmt.Fprintf(&b, "%s - %s\n", sub.Synopsis(prefix), sub.Summary)
+		}
+	}
+
+	if len(cmd.Examples) > 0 {
+		b.WriteString("\nExamples:\n")
+		for _, example := range cmd.Examples {
+			fmt.Fprintf(&b, "%s%s\n", prefix, example)
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,141 @@
+package commands
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/distrobyte/gerry/internal/models"
+)
+
+// historyKept is how many messages are remembered per channel
+const historyKept = 50
+
+// History remembers the recent messages of every channel, so commands can
+// refer to them on platforms without replies or reactions, such as Mumble
+// and IRC.
+type History struct {
+	mu       sync.Mutex
+	channels map[string][]*entry
+}
+
+type entry struct {
+	message *models.Message
+	// reactions holds the reactions added with the react command, keyed
+	// by reactor and emoji
+	reactions map[string]bool
+}
+
+// NewHistory returns an empty history.
+func NewHistory() *History {
+	return &History{channels: make(map[string][]*entry)}
+}
+
+// Add remembers a message, forgetting the oldest one of its channel once
+// the channel is full.
+func (h *History) Add(message *models.Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := channelKey(message.Platform, message.Channel)
+	entries := append(h.channels[key], &entry{message: message, reactions: make(map[string]bool)})
+	if len(entries) > historyKept {
+		entries = entries[1:]
+	}
+	h.channels[key] = entries
+}
+
+// Last returns the most recent message of a channel, by author if it is
+// set, or nil if there is none.
+func (h *History) Last(platform, channel, author string) *models.Message {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if e := h.last(platform, channel, author, nil); e != nil {
+		return e.message
+	}
+
+	return nil
+}
+
+// Previous returns the most recent message of the channel of message that
+// match accepts, or nil if there is none. It is meant to be called before
+// message is added.
+func (h *History) Previous(message *models.Message, match func(*models.Message) bool) *models.Message {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	e := h.last(message.Platform, message.Channel, "", func(e *entry) bool {
+		return match == nil || match(e.message)
+	})
+	if e != nil {
+		return e.message
+	}
+
+	return nil
+}
+
+// React records a reaction made with a command to the most recent message
+// of a channel, by author if it is set. It returns the message reacted
+// to, nil if there is none, and false if the reactor already reacted to it
+// with the same emoji.
+func (h *History) React(platform, channel, author, reactor, emoji string) (*models.Message, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	e := h.last(platform, channel, author, nil)
+	if e == nil {
+		return nil, false
+	}
+
+	key := reactionKey(reactor, emoji)
+	if e.reactions[key] {
+		return e.message, false
+	}
+	e.reactions[key] = true
+
+	return e.message, true
+}
+
+// Unreact takes back the most recent reaction made with a command with
+// emoji, to a message by author if it is set. It returns the message the
+// reaction was on, or nil if there is no such reaction.
+func (h *History) Unreact(platform, channel, author, reactor, emoji string) *models.Message {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := reactionKey(reactor, emoji)
+	e := h.last(platform, channel, author, func(e *entry) bool {
+		return e.reactions[key]
+	})
+	if e == nil {
+		return nil
+	}
+	delete(e.reactions, key)
+
+	return e.message
+}
+
+func (h *History) last(platform, channel, author string, match func(*entry) bool) *entry {
+	entries := h.channels[channelKey(platform, channel)]
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if author != "" && !strings.EqualFold(e.message.Author, author) && e.message.AuthorID != author {
+			continue
+		}
+		if match != nil && !match(e) {
+			continue
+		}
+
+		return e
+	}
+
+	return nil
+}
+
+func channelKey(platform, channel string) string {
+	return platform + "\x00" + channel
+}
+
+func reactionKey(reactor, emoji string) string {
+	return reactor + "\x00" + emoji
+}
@@ -0,0 +1,429 @@
+package commands
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// linkCodeTTL is how long a link code can be confirmed for
+const linkCodeTTL = 10 * time.Minute
+
+// linkCodeAlphabet leaves out letters and digits that are easily mistaken
+// for one another. Its 32 characters keep codes unbiased and, at
+// linkCodeLength, far too many to guess before they expire.
+const (
+	linkCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	linkCodeLength   = 8
+)
+
+// Identities links the accounts a person has on different platforms into a
+// single gerry user, and where the links are persisted. Accounts are
+// identified by their platform ID, never by name, and are linked by
+// confirming a one-time code from the other account.
+type Identities struct {
+	mu    sync.Mutex
+	dir   string
+	users []*identity
+	// codes are the link codes waiting to be confirmed, they are not
+	// persisted
+	codes map[string]linkCode
+}
+
+// Account is a user on one platform.
+type Account struct {
+	Platform string `json:"platform"`
+	ID       string `json:"id"`
+	// Name is the display name the account had when it was linked
+	Name string `json:"name"`
+}
+
+// identity is a gerry user with the accounts linked to it.
+type identity struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Accounts []Account `json:"accounts"`
+}
+
+type linkCode struct {
+	account Account
+	expires time.Time
+}
+
+type persistedIdentities struct {
+	Users []*identity `json:"users"`
+}
+
+// NewIdentities loads the identities stored in dir, starting from scratch
+// if there are none.
+func NewIdentities(dir string) *Identities {
+	i := &Identities{dir: dir, codes: make(map[string]linkCode)}
+
+	if err := i.load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Error().Err(err).Msg("failed to load identities")
+	}
+
+	return i
+}
+
+func init() {
+	Register(&Command{
+		Name:    "link",
+		Summary: "Link your accounts on different platforms",
+		Usage: "Without a code, sends you a one-time code. Send link with that code from your account on another " +
+			"platform to link the two, so keks, karting and permissions count them as one user.",
+		Args: []Arg{
+			{Name: "code", Description: "Code sent to your other account"},
+		},
+		Examples: []string{"link", "link K7QF2XMD"},
+		Handler:  LinkCommand,
+	})
+
+	Register(&Command{
+		Name:     "unlink",
+		Summary:  "Unlink this account from your other accounts",
+		Handler:  UnlinkCommand,
+		Examples: []string{"unlink"},
+	})
+
+	Register(&Command{
+		Name:     "whoami",
+		Summary:  "Show the accounts linked to yours",
+		Handler:  WhoamiCommand,
+		Examples: []string{"whoami"},
+	})
+}
+
+// LinkCommand sends a link code, or links the account of the author to
+// the one the code was sent to. Anyone who sees a code can link their
+// account with it, so codes are only sent privately, and revoked when the
+// platform cannot do that.
+func LinkCommand(ctx context.Context, req *Request) *models.Response {
+	account, ok := accountOf(req.Message)
+	if !ok {
+		return models.NewTextResponse(fmt.Sprintf("your %s account has no stable ID to link, e.g. it is not registered", req.Message.Platform))
+	}
+
+	ids := req.Env.Identities
+	if len(req.Args) == 0 {
+		code, err := ids.issue(account, req.Env.Now())
+		if err != nil {
+			log.Error().Err(err).Msg("failed to issue link code")
+			return models.NewTextResponse("failed to create a link code")
+		}
+
+		err = req.Platform.Reply(req.Message, &models.Response{
+			Text: fmt.Sprintf("Send `%s` from your account on another platform within %d minutes to link it with this one.",
+				Quote(req.Env.Config.GetBotPrefix()+"link", code), int(linkCodeTTL.Minutes())),
+			Markdown:  true,
+			Ephemeral: true,
+		})
+		if err != nil {
+			ids.revoke(code)
+			log.Warn().Err(err).Str("platform", req.Message.Platform).Msg("failed to send link code privately")
+			return models.NewTextResponse(fmt.Sprintf("could not send you a link code privately on %s, "+
+				"ask for one from your account on another platform and send it from this one", req.Message.Platform))
+		}
+
+		return nil
+	}
+
+	user, err := ids.confirm(req.Args[0], account, req.Env.Now())
+	if err != nil {
+		return models.NewTextResponse(err.Error())
+	}
+
+	return models.NewTextResponse(fmt.Sprintf("linked your %s account to %s (%s)", account.Platform, user.Name, user.platforms()))
+}
+
+// UnlinkCommand unlinks the account of the author from its identity.
+func UnlinkCommand(ctx context.Context, req *Request) *models.Response {
+	account, ok := accountOf(req.Message)
+	if !ok {
+		return models.NewTextResponse(fmt.Sprintf("your %s account is not linked", req.Message.Platform))
+	}
+
+	user, err := req.Env.Identities.unlink(account)
+	if err != nil {
+		return models.NewTextResponse(err.Error())
+	}
+
+	return models.NewTextResponse(fmt.Sprintf("unlinked your %s account from %s", account.Platform, user.Name))
+}
+
+// WhoamiCommand shows the identity of the author and its accounts.
+func WhoamiCommand(ctx context.Context, req *Request) *models.Response {
+	account, ok := accountOf(req.Message)
+	if !ok {
+		return models.NewTextResponse(fmt.Sprintf("you are %s on %s, without a stable ID to link", req.Message.Author, req.Message.Platform))
+	}
+
+	user, ok := req.Env.Identities.lookup(account)
+	if !ok {
+		return models.NewTextResponse(fmt.Sprintf("you are %s on %s, not linked to any other account", req.Message.Author, req.Message.Platform))
+	}
+
+	accounts := make([]string, len(user.Accounts))
+	for i, a := range user.Accounts {
+		accounts[i] = fmt.Sprintf("%s on %s", a.Name, a.Platform)
+	}
+
+	return models.NewTextResponse(fmt.Sprintf("you are %s: %s", user.Name, strings.Join(accounts, ", ")))
+}
+
+// accountOf returns the account of the author of message, if it has a
+// platform ID.
+func accountOf(message *models.Message) (Account, bool) {
+	if message.AuthorID == "" {
+		return Account{}, false
+	}
+
+	return Account{Platform: message.Platform, ID: message.AuthorID, Name: message.Author}, true
+}
+
+// Resolve returns the key of the user an account belongs to: the identity
+// it is linked to, or the account itself when it is not linked. A nil
+// Identities links nothing.
+func (i *Identities) Resolve(platform, id string) string {
+	if i == nil {
+		return platform + "/" + id
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if user := i.find(platform, id); user != nil {
+		return user.ID
+	}
+
+	return platform + "/" + id
+}
+
+// Accounts returns every account linked with an account, including
+// itself.
+func (i *Identities) Accounts(platform, id string) []Account {
+	if i == nil {
+		return []Account{{Platform: platform, ID: id}}
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if user := i.find(platform, id); user != nil {
+		return append([]Account(nil), user.Accounts...)
+	}
+
+	return []Account{{Platform: platform, ID: id}}
+}
+
+// Name returns the name of the identity an account is linked to.
+func (i *Identities) Name(platform, id string) (string, bool) {
+	if i == nil {
+		return "", false
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if user := i.find(platform, id); user != nil {
+		return user.Name, true
+	}
+
+	return "", false
+}
+
+// lookup returns a copy of the identity an account is linked to.
+func (i *Identities) lookup(account Account) (identity, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	user := i.find(account.Platform, account.ID)
+	if user == nil {
+		return identity{}, false
+	}
+
+	return user.copy(), true
+}
+
+// issue creates a link code for account, replacing any code it was sent
+// before.
+func (i *Identities) issue(account Account, now time.Time) (string, error) {
+	buf := make([]byte, linkCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for j, b := range buf {
+		buf[j] = linkCodeAlphabet[int(b)%len(linkCodeAlphabet)]
+	}
+	code := string(buf)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for c, pending := range i.codes {
+		if pending.account.Platform == account.Platform && pending.account.ID == account.ID || now.After(pending.expires) {
+			delete(i.codes, c)
+		}
+	}
+	i.codes[code] = linkCode{account: account, expires: now.Add(linkCodeTTL)}
+
+	return code, nil
+}
+
+// revoke removes a link code that could not be sent.
+func (i *Identities) revoke(code string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.codes, code)
+}
+
+// confirm links account to the account code was sent to. If account was
+// linked to another identity already, that identity is merged into the
+// one confirmed.
+func (i *Identities) confirm(code string, account Account, now time.Time) (identity, error) {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	pending, ok := i.codes[code]
+	if !ok || now.After(pending.expires) {
+		delete(i.codes, code)
+		return identity{}, fmt.Errorf("unknown or expired link code")
+	}
+	if pending.account.Platform == account.Platform {
+		return identity{}, fmt.Errorf("send the code from your account on another platform than %s", account.Platform)
+	}
+	delete(i.codes, code)
+
+	user := i.find(pending.account.Platform, pending.account.ID)
+	if user == nil {
+		user = &identity{ID: uuid.NewString(), Name: pending.account.Name, Accounts: []Account{pending.account}}
+		i.users = append(i.users, user)
+	}
+
+	other := i.find(account.Platform, account.ID)
+	switch {
+	case other == user:
+		return identity{}, fmt.Errorf("your %s account is already linked to %s", account.Platform, user.Name)
+	case other != nil:
+		user.Accounts = append(user.Accounts, other.Accounts...)
+		i.remove(other)
+	default:
+		user.Accounts = append(user.Accounts, account)
+	}
+	sort.SliceStable(user.Accounts, func(a, b int) bool {
+		return user.Accounts[a].Platform < user.Accounts[b].Platform
+	})
+
+	log.Info().Str("identity", user.ID).Str("platform", account.Platform).Str("account", account.ID).Msg("linked account")
+
+	return user.copy(), i.save()
+}
+
+// unlink removes account from its identity, and the identity with it when
+// it has no other account left to link.
+func (i *Identities) unlink(account Account) (identity, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	user := i.find(account.Platform, account.ID)
+	if user == nil {
+		return identity{}, fmt.Errorf("your %s account is not linked", account.Platform)
+	}
+
+	for j, a := range user.Accounts {
+		if a.Platform == account.Platform && a.ID == account.ID {
+			user.Accounts = append(user.Accounts[:j], user.Accounts[j+1:]...)
+			break
+		}
+	}
+	if len(user.Accounts) < 2 {
+		i.remove(user)
+	}
+
+	log.Info().Str("identity", user.ID).Str("platform", account.Platform).Str("account", account.ID).Msg("unlinked account")
+
+	return user.copy(), i.save()
+}
+
+// find returns the identity an account is linked to, or nil.
+func (i *Identities) find(platform, id string) *identity {
+	for _, user := range i.users {
+		for _, a := range user.Accounts {
+			if a.Platform == platform && a.ID == id {
+				return user
+			}
+		}
+	}
+
+	return nil
+}
+
+func (i *Identities) remove(user *identity) {
+	for j, u := range i.users {
+		if u == user {
+			i.users = append(i.users[:j], i.users[j+1:]...)
+			return
+		}
+	}
+}
+
+func (u *identity) copy() identity {
+	c := *u
+	c.Accounts = append([]Account(nil), u.Accounts...)
+	return c
+}
+
+// platforms lists the platforms of the accounts of u.
+func (u *identity) platforms() string {
+	names := make([]string, len(u.Accounts))
+	for i, a := range u.Accounts {
+		names[i] = a.Platform
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func (i *Identities) save() error {
+	if err := os.MkdirAll(i.dir, 0755); err != nil {
+		return err
+	}
+
+	out, err := json.MarshalIndent(persistedIdentities{Users: i.users}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(i.dir, "identities.json"), out, 0644)
+}
+
+func (i *Identities) load() error {
+	data, err := os.ReadFile(filepath.Join(i.dir, "identities.json"))
+	if err != nil {
+		return err
+	}
+
+	var state persistedIdentities
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	i.users = state.Users
+
+	log.Info().Str("file", filepath.Join(i.dir, "identities.json")).Int("users", len(i.users)).Msg("loaded identities")
+	return nil
+}
@@ -0,0 +1,205 @@
+package commands_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/render"
+	"github.com/distrobyte/gerry/internal/testkit"
+)
+
+var linkCodePattern = regexp.MustCompile(`>link ([A-Z0-9]{8})`)
+
+// account sends messages as a user on a platform other than the fake one,
+// through the same bot.
+type account struct {
+	h        *testkit.Harness
+	platform string
+	name     string
+	id       string
+}
+
+func (a account) message(content string) *models.Message {
+	return &models.Message{
+		Content:  content,
+		Author:   a.name,
+		AuthorID: a.id,
+		Channel:  "general",
+		Platform: a.platform,
+	}
+}
+
+func (a account) send(content string) *models.Response {
+	return a.h.Deliver(a.message(content))
+}
+
+func (a account) markdown(content string) string {
+	response := a.send(content)
+	if response == nil {
+		return ""
+	}
+
+	return render.Markdown(response)
+}
+
+// link links from to to with a code sent to from.
+func link(t *testing.T, from, to account) string {
+	t.Helper()
+
+	response := from.send(">link")
+	if !response.Ephemeral {
+		t.Errorf("link code was not sent privately")
+	}
+	match := linkCodePattern.FindStringSubmatch(response.Text)
+	if match == nil {
+		t.Fatalf("no link code in %q", response.Text)
+	}
+
+	return to.markdown(">link " + match[1])
+}
+
+func TestLink(t *testing.T) {
+	h := testkit.New(t)
+	discord := account{h, "discord", "alice", "123"}
+	mumble := account{h, "mumble", "alice_", "4"}
+	other := account{h, "discord", "alice2", "456"}
+	telegram := account{h, "telegram", "alice", "789"}
+
+	if got := mumble.markdown(">whoami"); got != "you are alice_ on mumble, not linked to any other account" {
+		t.Errorf("whoami before linking got %q", got)
+	}
+
+	if got := link(t, discord, mumble); got != "linked your mumble account to alice (discord, mumble)" {
+		t.Errorf("link got %q", got)
+	}
+	if got := mumble.markdown(">whoami"); got != "you are alice: alice on discord, alice_ on mumble" {
+		t.Errorf("whoami got %q", got)
+	}
+	if got := link(t, mumble, discord); got != "your discord account is already linked to alice" {
+		t.Errorf("linking again got %q", got)
+	}
+	if got := link(t, discord, other); got != "send the code from your account on another platform than discord" {
+		t.Errorf("linking on the same platform got %q", got)
+	}
+
+	code := linkCodePattern.FindStringSubmatch(discord.send(">link").Text)[1]
+	h.Clock.Advance(11 * time.Minute)
+	if got := telegram.markdown(">link " + code); got != "unknown or expired link code" {
+		t.Errorf("expired code got %q", got)
+	}
+	if got := telegram.markdown(">link AAAAAAAA"); got != "unknown or expired link code" {
+		t.Errorf("unknown code got %q", got)
+	}
+
+	if got := mumble.markdown(">unlink"); got != "unlinked your mumble account from alice" {
+		t.Errorf("unlink got %q", got)
+	}
+	if got := discord.markdown(">whoami"); got != "you are alice on discord, not linked to any other account" {
+		t.Errorf("whoami after unlinking got %q", got)
+	}
+	if got := mumble.markdown(">unlink"); got != "your mumble account is not linked" {
+		t.Errorf("unlinking again got %q", got)
+	}
+
+	if got := (account{h, "mumble", "guest", ""}).markdown(">link"); got != "your mumble account has no stable ID to link, e.g. it is not registered" {
+		t.Errorf("link without an ID got %q", got)
+	}
+}
+
+func TestLinkNotPrivate(t *testing.T) {
+	h := testkit.New(t)
+	h.Platform.NoPrivate()
+	matrix := account{h, "matrix", "alice", "@alice:example.org"}
+
+	response := matrix.send(">link")
+	if response.Ephemeral || linkCodePattern.MatchString(response.Text) {
+		t.Fatalf("link code was sent publicly: %q", response.Text)
+	}
+	if want := "could not send you a link code privately on matrix, ask for one from your account on another platform and send it from this one"; response.Text != want {
+		t.Errorf("link got %q", response.Text)
+	}
+}
+
+func TestLinkPersisted(t *testing.T) {
+	h := testkit.New(t)
+	link(t, account{h, "discord", "alice", "123"}, account{h, "mumble", "alice_", "4"})
+
+	restarted := testkit.New(t, func(cfg *config.Config) {
+		cfg.DataDir = h.Dir
+		cfg.StateDir = h.StateDir
+	})
+	if got := (account{restarted, "mumble", "alice_", "4"}).markdown(">whoami"); got != "you are alice: alice on discord, alice_ on mumble" {
+		t.Errorf("whoami after restart got %q", got)
+	}
+}
+
+func TestLinkedKeks(t *testing.T) {
+	h := testkit.New(t)
+	discord := account{h, "discord", "alice", "123"}
+	mumble := account{h, "mumble", "alice_", "4"}
+	alt := account{h, "discord", "alice2", "456"}
+	bob := account{h, "discord", "bob", "200"}
+
+	joke := discord.message("a joke")
+	h.Platform.Message(joke)
+	h.Platform.Reaction(&models.MessageReaction{Message: joke, Emoji: "😂", Reactor: "bob", ReactorID: "200"})
+
+	h.Platform.Message(mumble.message("a pun"))
+	h.Platform.Message(account{h, "mumble", "carol", "9"}.message("lol"))
+
+	link(t, discord, mumble)
+	if got := link(t, mumble, alt); got != "linked your discord account to alice (discord, discord, mumble)" {
+		t.Fatalf("linking a second account got %q", got)
+	}
+
+	// laughing at yourself from your other account does not count either
+	h.Platform.Reaction(&models.MessageReaction{Message: joke, Emoji: "🤣", Reactor: "alice2", ReactorID: "456"})
+
+	if got := mumble.markdown(">kek"); got != "alice has 2 keks, 2 in the past week and 2 in the past month" {
+		t.Errorf("kek got %q", got)
+	}
+	if got := bob.markdown(">kek <@123>"); got != "alice has 2 keks, 2 in the past week and 2 in the past month" {
+		t.Errorf("kek of a mention got %q", got)
+	}
+	if got := bob.markdown(">kek leaderboard"); got != "# Kek leaderboard (all time)\n```\n# | User  | Keks\n- | ----- | ----\n1 | alice |    2\n```" {
+		t.Errorf("leaderboard got %q", got)
+	}
+}
+
+func TestLinkedPermissions(t *testing.T) {
+	h := testkit.New(t, func(cfg *config.Config) {
+		cfg.Permissions.Moderators.Users = map[string][]string{"discord": {"123"}}
+	})
+	discord := account{h, "discord", "alice", "123"}
+	mumble := account{h, "mumble", "alice_", "4"}
+
+	if got := mumble.markdown(">karting reset"); got != "sorry, karting reset can only be used by moderators" {
+		t.Errorf("reset before linking got %q", got)
+	}
+
+	link(t, discord, mumble)
+	if got := mumble.markdown(">karting reset"); got == "sorry, karting reset can only be used by moderators" {
+		t.Errorf("reset from a linked account was denied")
+	}
+}
+
+func TestLinkedDrivers(t *testing.T) {
+	h := testkit.New(t)
+	discord := account{h, "discord", "alice", "123"}
+	mumble := account{h, "mumble", "alice_", "4"}
+	link(t, discord, mumble)
+
+	discord.send(">karting register alice")
+	discord.send(">karting register bob")
+	mumble.send(">karting race me bob")
+	discord.send(">karting race bob <@123>")
+
+	stats := discord.markdown(">karting stats")
+	if !strings.Contains(stats, "alice") || strings.Contains(stats, "alice_") || strings.Contains(stats, "<@") {
+		t.Errorf("linked drivers were not raced as alice:\n%s", stats)
+	}
+}
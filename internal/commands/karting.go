@@ -1,21 +1,35 @@
 package commands
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/distrobyte/gerry/internal/config"
 	"github.com/distrobyte/gerry/internal/models"
 	"github.com/distrobyte/multielo"
+	"github.com/distrobyte/multielo/domain"
 	"github.com/rs/zerolog/log"
 )
 
-var league *multielo.League
-var longestPlayerName int
+// Karting holds the state of a karting league and where it is persisted.
+type Karting struct {
+	dir string
+
+	// mu guards league, which reset replaces with the one loaded again
+	mu     sync.RWMutex
+	league *multielo.League
+
+	// graphMu serialises graph rendering, which writes to the same files
+	graphMu sync.Mutex
+}
 
 // multielo -> zerolog adapter to surface logs from vendored module
 type multieloZerologAdapter struct{}
@@ -54,131 +68,307 @@ type persistedConfig struct {
 	Config multielo.LeagueConfig `json:"config"`
 }
 
-func InitKarting() {
+// NewKarting loads the karting league stored in dir, creating it if needed.
+func NewKarting(dir string) *Karting {
+	k := &Karting{dir: dir}
+
 	// Initialize the karting instance
 	cfg := multielo.DefaultConfig()
-	cfg.OutputDirectory = "assets"
+	cfg.OutputDirectory = dir
 	cfg.DecayEnabled = true
 	cfg.DecayInitialPercent = 1.0
 	cfg.DecayPerMiss = -0.3
 
 	// Persist config before league is constructed
-	err := saveConfig(cfg)
+	err := k.saveConfig(cfg)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to save config data")
 	}
 
-	league = multielo.NewLeagueWithDependencies(cfg, multielo.LeagueDependencies{Logger: multieloZerologAdapter{}})
+	k.league = multielo.NewLeagueWithDependencies(cfg, multielo.LeagueDependencies{Logger: multieloZerologAdapter{}})
 
-	err = load()
+	err = k.load()
 	if err != nil {
 		log.Error().Err(err).Msg("failed to load karting data")
 	}
 
-	// Find the longest driver name
-	for _, driver := range league.GetPlayers() {
-		if len(driver.Name()) > longestPlayerName {
-			longestPlayerName = len(driver.Name())
-		}
-	}
-
-	_, err = league.GenerateGraph()
+	err = k.generateGraph(context.Background())
 	if err != nil {
 		log.Error().Err(err).Msg("failed to generate karting graph on startup")
 	}
+
+	return k
 }
 
-func KartingCommand(args []string, message models.Message) string {
-	if len(args) == 0 {
-		response := "karting command requires arguments"
-		return response
-	}
+func init() {
+	driverArg := Arg{Name: "driver", Description: "Name of the driver", Required: true}
+	registeredArg := driverArg
+	registeredArg.Complete = completeDriver
+
+	Register(&Command{
+		Name:     "karting",
+		Summary:  "Track karting races and driver ELO ratings",
+		Examples: []string{"karting stats", "karting race alice bob carol"},
+		SubCommands: []*Command{
+			{
+				Name:     "register",
+				Summary:  "Register a new driver",
+				Args:     []Arg{driverArg},
+				Examples: []string{"karting register alice"},
+				Handler:  KartingRegisterCommand,
+			},
+			{
+				Name:     "unregister",
+				Summary:  "Remove a driver from the league",
+				Args:     []Arg{registeredArg},
+				Examples: []string{"karting unregister alice"},
+				Handler:  KartingUnregisterCommand,
+			},
+			{
+				Name:    "graph",
+				Summary: "Show the ELO history graph",
+				// rendering the graph is expensive
+				Cooldown: config.Cooldown{User: 30 * time.Second, Channel: 10 * time.Second},
+				Timeout:  30 * time.Second,
+				Handler:  KartingGraphCommand,
+			},
+			{
+				Name:    "stats",
+				Summary: "Show the league standings",
+				Handler: KartingStatsCommand,
+			},
+			{
+				Name:    "race",
+				Timeout: 30 * time.Second,
+				Summary: "Record a race result",
+				Usage:   "Drivers are listed in finishing order, winner first, and must be registered.",
+				Args: []Arg{
+					{Name: "driver", Description: "Drivers in finishing order", Required: true, Variadic: true, Max: 12, Complete: completeDriver},
+				},
+				Examples: []string{"karting race alice bob carol"},
+				Handler:  KartingRaceCommand,
+			},
+			{
+				Name:    "predict",
+				Summary: "Show the ELO changes of a race result without recording it",
+				Usage:   "Drivers are listed in finishing order, winner first.",
+				Args: []Arg{
+					{Name: "driver", Description: "Drivers in finishing order", Required: true, Variadic: true, Max: 12, Complete: completeDriver},
+				},
+				Examples: []string{"karting predict alice bob carol"},
+				Handler:  KartingPredictCommand,
+			},
+			{
+				Name:    "enter",
+				Summary: "Enter a race result one driver at a time",
+				Usage:   "Shows a menu of drivers for each position on platforms with menus, and the predicted ELO changes before the race is recorded.",
+				Args: []Arg{
+					{Name: "driver", Description: "Drivers entered so far, in finishing order", Variadic: true},
+				},
+				Examples: []string{"karting enter"},
+				Handler:  KartingEnterCommand,
+				SubCommands: []*Command{
+					{
+						Name:    "register",
+						Summary: "Register a new driver and enter them in the next position",
+						Args: []Arg{
+							{Name: "driver", Description: "Drivers entered so far, then the new driver", Required: true, Variadic: true},
+						},
+						Examples: []string{"karting enter register alice dave"},
+						Handler:  KartingEnterRegisterCommand,
+					},
+				},
+			},
+			{
+				Name:       "reset",
+				Summary:    "Wipe all drivers and races",
+				Permission: PermissionModerator,
+				Handler:    KartingResetCommand,
+			},
+		},
+	})
+}
 
-	switch args[0] {
-	case "register":
-		if len(args) < 2 {
-			return "karting register command requires a driver name"
+// driverName resolves "me" to the author of req and a mention to the user
+// mentioned, by the name of their linked identity when they have one, so a
+// user races under the same name from every platform. Anything else is
+// taken as a driver name.
+func driverName(req *Request, arg string) string {
+	ids := req.Env.Identities
+	platform := req.Message.Platform
+
+	if strings.EqualFold(arg, "me") {
+		if name, ok := ids.Name(platform, reactor(req.Message)); ok {
+			return name
 		}
+		return req.Message.Author
+	}
 
-		err := league.AddPlayer(args[1])
-		if err != nil {
-			return err.Error()
+	if strings.HasPrefix(arg, "<@") {
+		if name, ok := ids.Name(platform, mentioned(arg)); ok {
+			return name
 		}
+	}
 
-		// update the longest driver name for formatting
-		if len(args[1]) > longestPlayerName {
-			longestPlayerName = len(args[1])
-		}
+	return arg
+}
 
-		err = save()
-		if err != nil {
-			return err.Error()
-		}
-		return "driver registered"
+// driverNames resolves every driver in args with driverName.
+func driverNames(req *Request, args []string) []string {
+	drivers := make([]string, len(args))
+	for i, arg := range args {
+		drivers[i] = driverName(req, arg)
+	}
 
-	case "unregister":
-		if len(args) < 2 {
-			return "karting unregister command requires a driver name"
-		}
+	return drivers
+}
 
-		err := league.RemovePlayer(args[1])
-		if err != nil {
-			return err.Error()
+// unknownDrivers returns an error naming the drivers that are not
+// registered in league, or nil if all of them are.
+func unknownDrivers(req *Request, league *multielo.League, drivers []string) error {
+	var unknown []string
+	for _, driver := range drivers {
+		if _, err := league.GetPlayer(driver); err != nil && !contains(unknown, driver) {
+			unknown = append(unknown, driver)
 		}
+	}
 
-		err = save()
-		if err != nil {
-			return err.Error()
-		}
-		return "driver unregistered"
+	if len(unknown) == 0 {
+		return nil
+	}
 
-	case "graph":
-		_, err := league.GenerateGraph()
-		if err != nil {
-			return err.Error()
-		}
+	register := kartingCommand(req, "register").Synopsis(req.Env.Config.GetBotPrefix())
+	if len(unknown) == 1 {
+		return fmt.Errorf("unknown driver %s, register them first with %s", unknown[0], register)
+	}
 
-		// return the URL to the graph
-		if config.IsEnvironment(config.APP_ENVIRONMENT_LOCAL) {
-			return fmt.Sprintf("http://localhost:%d/karting", config.GetHTTPPort())
-		} else {
-			return fmt.Sprintf("https://%s/karting.png", config.GetDomain())
-		}
+	return fmt.Errorf("unknown drivers %s, register them first with %s", strings.Join(unknown, ", "), register)
+}
 
-	case "stats":
-		return KartingStatsCommand(args, message)
+// kartingCommand returns the karting sub-command called name, from any of
+// the karting commands req may be running.
+func kartingCommand(req *Request, name string) *Command {
+	karting := req.Command
+	for karting.Parent() != nil {
+		karting = karting.Parent()
+	}
 
-	case "race":
-		return KartingRaceCommand(args, message)
+	return karting.SubCommand(name)
+}
 
-	case "reset":
-		league.ResetPlayers()
-		league.ResetMatches()
+// completeDriver suggests registered drivers whose name starts with value.
+func completeDriver(ctx context.Context, env *Env, value string) []string {
+	if env.Karting == nil {
+		return nil
+	}
 
-		err := save()
-		if err != nil {
-			return err.Error()
+	var names []string
+	for _, player := range env.Karting.current().GetPlayers() {
+		if strings.HasPrefix(strings.ToLower(player.Name()), strings.ToLower(value)) {
+			names = append(names, player.Name())
 		}
+	}
+	sort.Strings(names)
 
-		err = load()
-		if err != nil {
-			return err.Error()
-		}
+	return names
+}
 
-		return "karting stats have been reset"
+func KartingRegisterCommand(ctx context.Context, req *Request) *models.Response {
+	k := req.Env.Karting
+	league := k.current()
 
-	default:
-		break
+	err := league.AddPlayer(driverName(req, req.Args[0]))
+	if err != nil {
+		return models.NewTextResponse(err.Error())
 	}
 
-	response := "invalid karting command"
+	err = k.save()
+	if err != nil {
+		return models.NewTextResponse(err.Error())
+	}
+	return models.NewTextResponse("driver registered")
+}
 
-	return response
+func KartingUnregisterCommand(ctx context.Context, req *Request) *models.Response {
+	k := req.Env.Karting
+	league := k.current()
+
+	err := league.RemovePlayer(driverName(req, req.Args[0]))
+	if err != nil {
+		return models.NewTextResponse(err.Error())
+	}
+
+	err = k.save()
+	if err != nil {
+		return models.NewTextResponse(err.Error())
+	}
+	return models.NewTextResponse("driver unregistered")
 }
 
-func KartingStatsCommand(args []string, message models.Message) string {
-	response := fmt.Sprintf("# Karting stats\n```Rating | %-*s | Won | Total | Win %%  | Last 5 avg (all time) | Peak ELO\n", longestPlayerName, "Driver")
-	response += "------ | " + fmt.Sprintf("%s | --- | ----- | ------ | --------------------- | --------\n", strings.Repeat("-", longestPlayerName))
+func KartingGraphCommand(ctx context.Context, req *Request) *models.Response {
+	k := req.Env.Karting
+
+	err := k.generateGraph(ctx)
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		return models.NewTextResponse(err.Error())
+	}
+
+	// link to the graph as well as attaching it
+	var url string
+	cfg := req.Env.Config
+	if cfg.IsEnvironment(config.APP_ENVIRONMENT_LOCAL) {
+		url = fmt.Sprintf("http://localhost:%d/karting", cfg.GetHTTPPort())
+	} else {
+		url = fmt.Sprintf("https://%s/karting.png", cfg.GetDomain())
+	}
+
+	return &models.Response{
+		Title: "Karting ELO history",
+		Images: []models.Image{{
+			Name: "karting.png",
+			Path: filepath.Join(k.dir, "index.png"),
+			URL:  url,
+		}},
+	}
+}
+
+func KartingResetCommand(ctx context.Context, req *Request) *models.Response {
+	k := req.Env.Karting
+	league := k.current()
+	league.ResetPlayers()
+	league.ResetMatches()
+
+	err := k.save()
+	if err != nil {
+		return models.NewTextResponse(err.Error())
+	}
+
+	err = k.load()
+	if err != nil {
+		return models.NewTextResponse(err.Error())
+	}
+
+	return models.NewTextResponse("karting stats have been reset")
+}
+
+func KartingStatsCommand(ctx context.Context, req *Request) *models.Response {
+	k := req.Env.Karting
+	league := k.current()
+
+	table := models.Table{
+		Columns: []models.Column{
+			{Name: "Rating", AlignRight: true},
+			{Name: "Driver"},
+			{Name: "Won", AlignRight: true},
+			{Name: "Total", AlignRight: true},
+			{Name: "Win %", AlignRight: true},
+			{Name: "Last 5 avg (all time)", AlignRight: true},
+			{Name: "Peak ELO", AlignRight: true},
+		},
+	}
 
 	// Get all players and sort by ELO descending
 	players := league.GetPlayers()
@@ -203,34 +393,44 @@ func KartingStatsCommand(args []string, message models.Message) string {
 			last5 /= float64(len(last5Finishes))
 		}
 
-		response += fmt.Sprintf("%6d | %-*s | %3d | %5d | %5.2f%% | %21s | %8d\n", driver.ELO(), longestPlayerName, driver.Name(), matchesWon, matchesPlayed, winRate,
-			fmt.Sprintf("%.2f (%.2f)", last5, driver.AllTimeAvgPlace()), driver.PeakELO())
+		table.Rows = append(table.Rows, []string{
+			strconv.Itoa(driver.ELO()),
+			driver.Name(),
+			strconv.Itoa(matchesWon),
+			strconv.Itoa(matchesPlayed),
+			fmt.Sprintf("%.2f%%", winRate),
+			fmt.Sprintf("%.2f (%.2f)", last5, driver.AllTimeAvgPlace()),
+			strconv.Itoa(driver.PeakELO()),
+		})
 	}
 
-	response += "```"
-
-	return response
+	return &models.Response{
+		Title:  "Karting stats",
+		Tables: []models.Table{table},
+	}
 }
 
-func KartingRaceCommand(args []string, message models.Message) string {
-	if len(args) < 2 {
-		return "please provide a list of drivers"
-	}
+func KartingRaceCommand(ctx context.Context, req *Request) *models.Response {
+	k := req.Env.Karting
+	league := k.current()
+	drivers := driverNames(req, req.Args)
 
 	// Track before state for display
 	beforeELOs := make(map[string]int)
 
+	// a typo would otherwise add a driver to the league
+	if err := unknownDrivers(req, league, drivers); err != nil {
+		return models.NewTextResponse(err.Error())
+	}
+
 	// Build match results with league players
 	var results []*multielo.MatchResult
-	for i, driverName := range args[1:] {
-		// Get or create player
+	for i, driverName := range drivers {
 		player, err := league.GetPlayer(driverName)
 		if err != nil {
-			// Player doesn't exist, add them to the league first
-			_ = league.AddPlayer(driverName)
-			player, _ = league.GetPlayer(driverName)
+			return models.NewTextResponse(err.Error())
 		}
-		// Capture pre-race ELO (handles newly added players correctly)
+		// Capture pre-race ELO
 		beforeELOs[driverName] = player.ELO()
 		results = append(results, &multielo.MatchResult{
 			Position: i + 1,
@@ -238,13 +438,23 @@ func KartingRaceCommand(args []string, message models.Message) string {
 		})
 	}
 
-	err := league.AddMatch(results, time.Now())
+	// nothing has been recorded yet, so this is the last point to give up
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	err := league.AddMatch(results, req.Env.Now())
 	if err != nil {
-		return err.Error()
+		return models.NewTextResponse(err.Error())
 	}
 
-	response := "# Race results\n"
-	response += fmt.Sprintf("```%*s | Change | Cause\n", longestPlayerName, "Driver")
+	table := models.Table{
+		Columns: []models.Column{
+			{Name: "Driver", AlignRight: true},
+			{Name: "Change"},
+			{Name: "Cause"},
+		},
+	}
 
 	// Use last changes from multielo to annotate cause (position/decay)
 	last := multielo.GetLastChanges(league)
@@ -255,7 +465,7 @@ func KartingRaceCommand(args []string, message models.Message) string {
 	}
 
 	// Participants first (in the order provided)
-	for _, driverName := range args[1:] {
+	for _, driverName := range drivers {
 		player, _ := league.GetPlayer(driverName)
 		if player == nil {
 			continue
@@ -270,50 +480,250 @@ func KartingRaceCommand(args []string, message models.Message) string {
 				cause = fmt.Sprintf("position (%d)", c.Position)
 			}
 		}
-		response += fmt.Sprintf("%*s | %+d | %s\n", longestPlayerName, driverName, diff, cause)
+		table.Rows = append(table.Rows, []string{driverName, fmt.Sprintf("%+d", diff), cause})
 	}
 
-	// List decays for non-participants
+	// List decays for non-participants, in a stable order
+	var decayed []string
 	for name, c := range changeByName {
 		if !c.Attended && c.Cause == "decay" {
-			response += fmt.Sprintf("%*s | %+d | decay\n", longestPlayerName, name, c.Diff)
+			decayed = append(decayed, name)
 		}
 	}
-
-	response += "```"
+	sort.Strings(decayed)
+	for _, name := range decayed {
+		table.Rows = append(table.Rows, []string{name, fmt.Sprintf("%+d", changeByName[name].Diff), "decay"})
+	}
 
 	// Sync player histories to ensure all players have complete history for graph rendering
 	multielo.SyncPlayerHistories(league)
 
-	// update the graph
-	_, err = league.GenerateGraph()
+	// the race is recorded, so persist it even if the command is cancelled
+	err = k.save()
 	if err != nil {
-		return err.Error()
+		return models.NewTextResponse(err.Error())
 	}
 
-	err = save()
-	if err != nil {
-		return err.Error()
+	// update the graph; if this runs out of time it finishes in the
+	// background and the results are still worth sending
+	err = k.generateGraph(ctx)
+	if err != nil && ctx.Err() == nil {
+		return models.NewTextResponse(err.Error())
+	}
+
+	return &models.Response{
+		Title:  "Race results",
+		Tables: []models.Table{table},
+	}
+}
+
+// KartingEnterCommand shows the race entry of the drivers entered so far.
+func KartingEnterCommand(ctx context.Context, req *Request) *models.Response {
+	k := req.Env.Karting
+	league := k.current()
+
+	var drivers []string
+	for _, driver := range driverNames(req, req.Args) {
+		if !contains(drivers, driver) {
+			drivers = append(drivers, driver)
+		}
+	}
+
+	if err := unknownDrivers(req, league, drivers); err != nil {
+		return models.NewTextResponse(err.Error())
+	}
+
+	return raceEntry(req, league, drivers)
+}
+
+// KartingEnterRegisterCommand registers the last driver given and goes on
+// with the race entry, with them in the next position.
+func KartingEnterRegisterCommand(ctx context.Context, req *Request) *models.Response {
+	k := req.Env.Karting
+	league := k.current()
+
+	var drivers []string
+	for _, driver := range driverNames(req, req.Args) {
+		if !contains(drivers, driver) {
+			drivers = append(drivers, driver)
+		}
+	}
+
+	driver := drivers[len(drivers)-1]
+	if _, err := league.GetPlayer(driver); err != nil {
+		if err := league.AddPlayer(driver); err != nil {
+			return models.NewTextResponse(err.Error())
+		}
+		if err := k.save(); err != nil {
+			return models.NewTextResponse(err.Error())
+		}
 	}
 
+	if err := unknownDrivers(req, league, drivers); err != nil {
+		return models.NewTextResponse(err.Error())
+	}
+
+	return raceEntry(req, league, drivers)
+}
+
+// raceEntry shows the finishing order entered so far, with a menu to pick
+// the driver in the next position and buttons to register a new driver or
+// preview the result.
+func raceEntry(req *Request, league *multielo.League, drivers []string) *models.Response {
+	prefix := req.Env.Config.GetBotPrefix()
+
+	var remaining []string
+	for _, player := range league.GetPlayers() {
+		if !contains(drivers, player.Name()) {
+			remaining = append(remaining, player.Name())
+		}
+	}
+	sort.Strings(remaining)
+
+	var text strings.Builder
+	if len(drivers) > 0 {
+		text.WriteString("Finishing order:\n")
+		for i, driver := range drivers {
+			fmt.Fprintf(&text, "%d. %s\n", i+1, driver)
+		}
+		text.WriteString("\n")
+	}
+	fmt.Fprintf(&text, "Pick the driver in position %d, or record the race with %s.",
+		len(drivers)+1, kartingCommand(req, "race").Synopsis(prefix))
+
+	enter := Quote(append([]string{"karting", "enter"}, drivers...)...)
+	response := &models.Response{
+		Title: "Race entry",
+		Text:  text.String(),
+		Buttons: []models.Button{
+			{Label: "Register driver", Command: Quote(append([]string{"karting", "enter", "register"}, drivers...)...), Prompt: "Driver name"},
+		},
+	}
+
+	if len(remaining) > 0 {
+		response.Menu = &models.Menu{
+			Placeholder: fmt.Sprintf("Position %d", len(drivers)+1),
+			Options:     remaining,
+			Command:     enter,
+		}
+	}
+
+	if len(drivers) >= 2 {
+		response.Buttons = append(response.Buttons, models.Button{
+			Label:   "Preview",
+			Style:   models.ButtonPrimary,
+			Command: Quote(append([]string{"karting", "predict"}, drivers...)...),
+		})
+	}
+	response.Buttons = append(response.Buttons, models.Button{Label: "Cancel", Style: models.ButtonDanger})
+
 	return response
 }
 
-func save() error {
-	dir, err := os.Getwd()
+// KartingPredictCommand shows the ELO changes a race result would give,
+// with buttons to record or drop it.
+func KartingPredictCommand(ctx context.Context, req *Request) *models.Response {
+	k := req.Env.Karting
+	league := k.current()
+	drivers := driverNames(req, req.Args)
+
+	if err := unknownDrivers(req, league, drivers); err != nil {
+		return models.NewTextResponse(err.Error())
+	}
+
+	changes, err := k.predict(drivers)
 	if err != nil {
+		return models.NewTextResponse(err.Error())
+	}
+
+	table := models.Table{
+		Columns: []models.Column{
+			{Name: "Driver", AlignRight: true},
+			{Name: "Rating", AlignRight: true},
+			{Name: "Change", AlignRight: true},
+			{Name: "Predicted", AlignRight: true},
+		},
+	}
+
+	for i, driver := range drivers {
+		before := changes[i].Player.ELO()
+		table.Rows = append(table.Rows, []string{
+			driver,
+			strconv.Itoa(before),
+			fmt.Sprintf("%+d", changes[i].Diff),
+			strconv.Itoa(before + changes[i].Diff),
+		})
+	}
+
+	return &models.Response{
+		Title:  "Predicted ELO changes",
+		Text:   "Nothing is recorded until the race is confirmed.",
+		Tables: []models.Table{table},
+		Buttons: []models.Button{
+			{Label: "Confirm", Style: models.ButtonPrimary, Command: Quote(append([]string{"karting", "race"}, drivers...)...)},
+			{Label: "Cancel", Style: models.ButtonDanger},
+		},
+	}
+}
+
+// predict calculates the ELO changes of a race without recording it.
+func (k *Karting) predict(drivers []string) ([]multielo.MatchDiff, error) {
+	league := k.current()
+	cfg := league.GetConfig()
+
+	var results []*multielo.MatchResult
+	for i, driver := range drivers {
+		if contains(drivers[:i], driver) {
+			return nil, fmt.Errorf("%s is listed more than once", driver)
+		}
+
+		player, err := league.GetPlayer(driver)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &multielo.MatchResult{Position: i + 1, Player: player})
+	}
+
+	if len(results) < 2 {
+		return nil, fmt.Errorf("a race needs at least two drivers")
+	}
+
+	return domain.CalculateELOChanges(results, cfg)
+}
+
+// generateGraph renders the ELO graph, returning early once ctx is done.
+// The renderer cannot be interrupted, so it keeps running in the background.
+func (k *Karting) generateGraph(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		k.graphMu.Lock()
+		defer k.graphMu.Unlock()
+
+		_, err := k.current().GenerateGraph()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
 		return err
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
+
+func (k *Karting) save() error {
 	// Ensure assets directory exists
-	if err := os.MkdirAll(dir+"/assets", 0755); err != nil {
+	if err := os.MkdirAll(k.dir, 0755); err != nil {
 		log.Error().Err(err).Msg("failed to create assets directory")
 		return err
 	}
 	log.Info().
-		Str("file", dir+"/assets/karting.json").
+		Str("file", filepath.Join(k.dir, "karting.json")).
 		Msg("writing karting data to")
 
 	// Build snapshot state from current league
+	league := k.current()
 	players := league.GetPlayers()
 	pnames := make([]string, 0, len(players))
 	for _, p := range players {
@@ -338,7 +748,7 @@ func save() error {
 		return err
 	}
 
-	err = os.WriteFile(dir+"/assets/karting.json", out, 0644)
+	err = os.WriteFile(filepath.Join(k.dir, "karting.json"), out, 0644)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to write karting state")
 		return err
@@ -347,18 +757,14 @@ func save() error {
 	return nil
 }
 
-func saveConfig(cfg multielo.LeagueConfig) error {
-	dir, err := os.Getwd()
-	if err != nil {
-		return err
-	}
+func (k *Karting) saveConfig(cfg multielo.LeagueConfig) error {
 	// Ensure assets directory exists
-	if err := os.MkdirAll(dir+"/assets", 0755); err != nil {
+	if err := os.MkdirAll(k.dir, 0755); err != nil {
 		log.Error().Err(err).Msg("failed to create assets directory")
 		return err
 	}
 	log.Info().
-		Str("file", dir+"/assets/karting_config.json").
+		Str("file", filepath.Join(k.dir, "karting_config.json")).
 		Msg("writing karting config to")
 
 	// Write the provided config (do not depend on league being initialized yet)
@@ -369,7 +775,7 @@ func saveConfig(cfg multielo.LeagueConfig) error {
 		return err
 	}
 
-	err = os.WriteFile(dir+"/assets/karting_config.json", out, 0644)
+	err = os.WriteFile(filepath.Join(k.dir, "karting_config.json"), out, 0644)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to write karting config")
 		return err
@@ -378,14 +784,10 @@ func saveConfig(cfg multielo.LeagueConfig) error {
 	return nil
 }
 
-func load() error {
-	log.Info().Str("file", "assets/karting.json").Msg("loading karting data")
-	dir, err := os.Getwd()
-	if err != nil {
-		return err
-	}
+func (k *Karting) load() error {
+	log.Info().Str("file", filepath.Join(k.dir, "karting.json")).Msg("loading karting data")
 
-	data, err := os.ReadFile(dir + "/assets/karting.json")
+	data, err := os.ReadFile(filepath.Join(k.dir, "karting.json"))
 	if err != nil {
 		log.Error().Err(err).Msg("failed to read karting state")
 		return err
@@ -400,10 +802,10 @@ func load() error {
 	// Load config with sane fallback to defaults
 	cfg := multielo.DefaultConfig()
 	var configState persistedConfig
-	if configData, err := os.ReadFile(dir + "/assets/karting_config.json"); err != nil {
+	if configData, err := os.ReadFile(filepath.Join(k.dir, "karting_config.json")); err != nil {
 		log.Error().Err(err).Msg("failed to read karting config, using defaults")
 	} else {
-		log.Info().Str("file", "assets/karting_config.json").Msg("loading karting config")
+		log.Info().Str("file", filepath.Join(k.dir, "karting_config.json")).Msg("loading karting config")
 		if err := json.Unmarshal(configData, &configState); err != nil {
 			log.Error().Err(err).Msg("failed to unmarshal karting config, using defaults")
 		} else {
@@ -412,7 +814,7 @@ func load() error {
 	}
 
 	// Reconstruct league from snapshot
-	league = multielo.NewLeagueWithDependencies(cfg, multielo.LeagueDependencies{Logger: multieloZerologAdapter{}})
+	league := multielo.NewLeagueWithDependencies(cfg, multielo.LeagueDependencies{Logger: multieloZerologAdapter{}})
 
 	// Don't pre-add all players; let them be auto-created when first appearing in matches.
 	// This ensures players only get history entries starting from when they first participate.
@@ -435,8 +837,31 @@ func load() error {
 		}
 	}
 
+	// Registered drivers who have not raced yet are only in the players
+	// list. They are added after the replay, so the races before they
+	// registered do not decay their rating.
+	for _, name := range state.Players {
+		if _, err := league.GetPlayer(name); err != nil {
+			if err := league.AddPlayer(name); err != nil {
+				log.Error().Err(err).Str("driver", name).Msg("failed to restore driver from state")
+			}
+		}
+	}
+
 	// Sync player histories to backfill entries for players who joined late
 	multielo.SyncPlayerHistories(league)
 
+	k.mu.Lock()
+	k.league = league
+	k.mu.Unlock()
+
 	return nil
 }
+
+// current returns the league, which is replaced when it is reset.
+func (k *Karting) current() *multielo.League {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.league
+}
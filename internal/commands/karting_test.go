@@ -0,0 +1,184 @@
+package commands_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/commands"
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/render"
+	"github.com/distrobyte/gerry/internal/testkit"
+)
+
+// register registers drivers in the league of h.
+func register(h *testkit.Harness, drivers ...string) {
+	for _, driver := range drivers {
+		h.Send("alice", ">karting register "+commands.Quote(driver))
+	}
+}
+
+func TestKartingRace(t *testing.T) {
+	h := testkit.New(t)
+	register(h, "alice", "bob", "carol")
+
+	testkit.Golden(t, "karting_race", h.Markdown("alice", ">karting race alice bob carol"))
+}
+
+func TestKartingRaceUnknownDriver(t *testing.T) {
+	h := testkit.New(t)
+	register(h, "alice", "bob")
+
+	if got, want := h.Markdown("alice", ">karting race alice bbo carl"), "unknown drivers bbo, carl, register them first with >karting register <driver>"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := h.Markdown("alice", ">karting predict alice bbo"), "unknown driver bbo, register them first with >karting register <driver>"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// nothing was recorded
+	if stats := h.Markdown("alice", ">karting stats"); strings.Contains(stats, "bbo") || strings.Contains(stats, "1016") {
+		t.Errorf("race with unknown drivers was recorded:\n%s", stats)
+	}
+}
+
+func TestKartingStats(t *testing.T) {
+	h := testkit.New(t)
+	register(h, "alice", "bob", "carol")
+
+	for _, race := range []string{
+		">karting race alice bob carol",
+		">karting race bob alice carol",
+		">karting race alice carol bob",
+	} {
+		if h.Send("alice", race) == nil {
+			t.Fatalf("%q got no response", race)
+		}
+		h.Clock.Advance(24 * time.Hour)
+	}
+
+	testkit.Golden(t, "karting_stats", h.Markdown("alice", ">karting stats"))
+}
+
+func TestKartingStatsPersisted(t *testing.T) {
+	h := testkit.New(t)
+	register(h, "alice", "bob")
+	h.Send("alice", ">karting race alice bob")
+	want := h.Markdown("alice", ">karting stats")
+
+	// a second bot on the same data directory loads the same league
+	again := testkit.New(t, func(cfg *config.Config) {
+		cfg.DataDir = h.Dir
+	})
+
+	if got := again.Markdown("alice", ">karting stats"); got != want {
+		t.Errorf("stats after reload:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestKartingRegisteredPersisted(t *testing.T) {
+	h := testkit.New(t)
+	register(h, "alice", "bob")
+
+	// drivers who have not raced yet are still registered after a restart
+	again := testkit.New(t, func(cfg *config.Config) {
+		cfg.DataDir = h.Dir
+	})
+
+	if got := again.Markdown("alice", ">karting race alice bob"); strings.Contains(got, "unknown driver") {
+		t.Errorf("registered drivers were lost on restart: %q", got)
+	}
+}
+
+func TestKartingCompleteDriver(t *testing.T) {
+	h := testkit.New(t)
+	register(h, "Alice", "bob", "Amy")
+
+	race, _, _ := h.Bot.Registry().Resolve([]string{"karting", "race"})
+	got := h.Bot.Complete(context.Background(), &race.Args[0], "a")
+
+	if strings.Join(got, ",") != "Alice,Amy" {
+		t.Errorf("completions for %q = %q", "a", got)
+	}
+}
+
+func TestKartingPredict(t *testing.T) {
+	h := testkit.New(t)
+	register(h, "alice", "bob", "new kid")
+	h.Send("alice", ">karting race alice bob")
+	want := h.Markdown("alice", ">karting stats")
+
+	response := h.Send("alice", ">karting predict bob alice 'new kid'")
+	testkit.Golden(t, "karting_predict", render.Markdown(response))
+
+	if len(response.Buttons) != 2 || response.Buttons[0].Command != "karting race bob alice 'new kid'" || response.Buttons[1].Command != "" {
+		t.Errorf("unexpected buttons %+v", response.Buttons)
+	}
+
+	// nothing was recorded
+	if stats := h.Markdown("alice", ">karting stats"); stats != want {
+		t.Errorf("prediction changed the stats:\n%s\nwant:\n%s", stats, want)
+	}
+
+	if got := h.Markdown("alice", ">karting predict alice alice"); got != "alice is listed more than once" {
+		t.Errorf("duplicate driver got %q", got)
+	}
+}
+
+func TestKartingEnter(t *testing.T) {
+	h := testkit.New(t)
+	register(h, "alice", "bob", "carol")
+
+	response := h.Send("alice", ">karting enter")
+	if response.Menu == nil || strings.Join(response.Menu.Options, ",") != "alice,bob,carol" || response.Menu.Command != "karting enter" {
+		t.Fatalf("unexpected menu %+v", response.Menu)
+	}
+	for _, button := range response.Buttons {
+		if button.Label == "Preview" {
+			t.Errorf("preview offered without drivers")
+		}
+	}
+
+	// picking from the menu runs its command with the option appended
+	response = h.Send("alice", ">"+response.Menu.Command+" carol")
+	response = h.Send("alice", ">"+response.Menu.Command+" alice")
+	testkit.Golden(t, "karting_enter", render.Markdown(response))
+
+	if strings.Join(response.Menu.Options, ",") != "bob" || response.Menu.Placeholder != "Position 3" {
+		t.Errorf("unexpected menu %+v", response.Menu)
+	}
+
+	var labels []string
+	for _, button := range response.Buttons {
+		labels = append(labels, button.Label)
+		if button.Label == "Preview" && button.Command != "karting predict carol alice" {
+			t.Errorf("unexpected preview command %q", button.Command)
+		}
+	}
+	if strings.Join(labels, ",") != "Register driver,Preview,Cancel" {
+		t.Errorf("unexpected buttons %q", labels)
+	}
+
+	// registering a driver from the entry keeps the order picked so far
+	var registerButton string
+	for _, button := range response.Buttons {
+		if button.Label == "Register driver" {
+			registerButton = button.Command
+		}
+	}
+	if registerButton != "karting enter register carol alice" {
+		t.Fatalf("unexpected register command %q", registerButton)
+	}
+	entry := h.Send("alice", ">"+registerButton+" 'new kid'")
+	if !strings.Contains(entry.Text, "1. carol\n2. alice\n3. new kid\n") || entry.Menu.Placeholder != "Position 4" {
+		t.Errorf("entry did not go on with the new driver: %+v", entry)
+	}
+	if stats := h.Markdown("alice", ">karting stats"); !strings.Contains(stats, "new kid") {
+		t.Errorf("new driver was not registered:\n%s", stats)
+	}
+
+	if got := h.Markdown("alice", ">karting enter carol dave"); got != "unknown driver dave, register them first with >karting register <driver>" {
+		t.Errorf("unknown driver entered, got %q", got)
+	}
+}
@@ -0,0 +1,481 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// kekLeaderboardSize is how many users the leaderboard shows
+const kekLeaderboardSize = 10
+
+// kekPeriods are the leaderboard views and how far back they count, all
+// time counting everything
+var kekPeriods = map[string]time.Duration{
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"all":   0,
+}
+
+var kekPeriodNames = map[string]string{
+	"week":  "past week",
+	"month": "past month",
+	"all":   "all time",
+}
+
+// Kek counts the laughs each user got and where they are persisted. The
+// author of a message is credited whenever someone reacts to it with one
+// of the kek emojis or replies with one of the kek keywords.
+type Kek struct {
+	mu      sync.Mutex
+	dir     string
+	credits []kekCredit
+}
+
+// kekCredit is a single laugh. A credit is identified by the message,
+// who laughed and the emoji or keyword they laughed with, so a reaction
+// taken back removes exactly its own credit.
+type kekCredit struct {
+	Platform string    `json:"platform"`
+	Message  string    `json:"message"`
+	UserID   string    `json:"user_id"`
+	User     string    `json:"user"`
+	GiverID  string    `json:"giver_id"`
+	Emoji    string    `json:"emoji"`
+	Time     time.Time `json:"time"`
+}
+
+type persistedKek struct {
+	Credits []kekCredit `json:"credits"`
+}
+
+// kekScore is how many laughs a user got. Key is the identity of the user
+// as resolved by Identities, and Platform is only set for users that are
+// not linked across platforms.
+type kekScore struct {
+	Key      string
+	Platform string
+	User     string
+	Keks     int
+}
+
+// NewKek loads the kek counter stored in dir, starting from scratch if
+// there is none.
+func NewKek(dir string) *Kek {
+	k := &Kek{dir: dir}
+
+	if err := k.load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Error().Err(err).Msg("failed to load kek data")
+	}
+
+	return k
+}
+
+func init() {
+	Register(&Command{
+		Name:    "kek",
+		Summary: "Show how many laughs a user got",
+		Usage:   "Reacting to a message with a kek emoji, or replying with a kek keyword such as lol, credits its author. Laughing at your own messages does not count.",
+		Args: []Arg{
+			{Name: "user", Description: "User to show, yourself if not given", Type: ArgUser},
+		},
+		Examples: []string{"kek", "kek alice", "kek leaderboard week"},
+		Handler:  KekCommand,
+		SubCommands: []*Command{
+			{
+				Name:    "leaderboard",
+				Summary: "Show who got the most laughs",
+				Args: []Arg{
+					{Name: "period", Description: "Laughs to count, all time if not given", Choices: []string{"week", "month", "all"}},
+				},
+				Examples: []string{"kek leaderboard", "kek leaderboard month"},
+				Handler:  KekLeaderboardCommand,
+			},
+		},
+	})
+
+	RegisterReaction(KekReaction)
+}
+
+// KekCommand shows the laughs of a user, or of the author of the command.
+func KekCommand(ctx context.Context, req *Request) *models.Response {
+	k := req.Env.Kek
+	ids := req.Env.Identities
+	now := req.Env.Now()
+
+	score := kekScore{
+		Key:  ids.Resolve(req.Message.Platform, reactor(req.Message)),
+		User: req.Message.Author,
+	}
+	if name, ok := ids.Name(req.Message.Platform, reactor(req.Message)); ok {
+		score.User = name
+	}
+	if len(req.Args) > 0 {
+		user := mentioned(req.Args[0])
+
+		var ok bool
+		if score, ok = k.find(ids, req.Message.Platform, user); !ok {
+			return models.NewTextResponse(fmt.Sprintf("%s has no keks yet", user))
+		}
+	}
+
+	all := k.count(ids, score.Key, time.Time{})
+	if all == 0 {
+		return models.NewTextResponse(fmt.Sprintf("%s has no keks yet", score.User))
+	}
+
+	return models.NewTextResponse(fmt.Sprintf("%s has %s, %d in the past week and %d in the past month",
+		score.User, keks(all),
+		k.count(ids, score.Key, now.Add(-kekPeriods["week"])),
+		k.count(ids, score.Key, now.Add(-kekPeriods["month"]))))
+}
+
+// KekLeaderboardCommand shows the users with the most laughs over a
+// period.
+func KekLeaderboardCommand(ctx context.Context, req *Request) *models.Response {
+	period := "all"
+	if len(req.Args) > 0 {
+		period = req.Args[0]
+	}
+
+	var since time.Time
+	if d := kekPeriods[period]; d > 0 {
+		since = req.Env.Now().Add(-d)
+	}
+
+	scores := req.Env.Kek.scores(req.Env.Identities, since)
+	if len(scores) == 0 {
+		return models.NewTextResponse(fmt.Sprintf("nobody has any keks (%s)", kekPeriodNames[period]))
+	}
+	if len(scores) > kekLeaderboardSize {
+		scores = scores[:kekLeaderboardSize]
+	}
+
+	// names are only told apart by platform when there is more than one,
+	// users linked across platforms are shown by their name alone
+	platforms := make(map[string]bool)
+	for _, score := range scores {
+		if score.Platform != "" {
+			platforms[score.Platform] = true
+		}
+	}
+
+	table := models.Table{
+		Columns: []models.Column{
+			{Name: "#", AlignRight: true},
+			{Name: "User"},
+			{Name: "Keks", AlignRight: true},
+		},
+	}
+	for i, score := range scores {
+		name := score.User
+		if len(platforms) > 1 && score.Platform != "" {
+			name = fmt.Sprintf("%s (%s)", score.User, score.Platform)
+		}
+
+		table.Rows = append(table.Rows, []string{strconv.Itoa(i + 1), name, strconv.Itoa(score.Keks)})
+	}
+
+	return &models.Response{
+		Title:  fmt.Sprintf("Kek leaderboard (%s)", kekPeriodNames[period]),
+		Tables: []models.Table{table},
+	}
+}
+
+// KekReaction credits the author of a message laughed at, and takes the
+// credit back when the reaction is removed.
+func KekReaction(ctx context.Context, req *ReactionRequest) *models.Response {
+	k := req.Env.Kek
+	reaction := req.Reaction
+	if k == nil || !isKek(req.Env.Config, reaction.Emoji) {
+		return nil
+	}
+
+	message := reaction.Message
+	credit := kekCredit{
+		Platform: message.Platform,
+		Message:  messageKey(message),
+		UserID:   reactor(message),
+		User:     message.Author,
+		GiverID:  reaction.ReactorID,
+		Emoji:    reaction.Emoji,
+		Time:     req.Env.Now(),
+	}
+	if credit.GiverID == "" {
+		credit.GiverID = reaction.Reactor
+	}
+	if credit.UserID == "" || credit.UserID == credit.GiverID {
+		return nil
+	}
+
+	// laughing at yourself from another of your accounts does not count
+	// either
+	ids := req.Env.Identities
+	if ids.Resolve(credit.Platform, credit.UserID) == ids.Resolve(credit.Platform, credit.GiverID) {
+		return nil
+	}
+
+	var err error
+	if reaction.Removed {
+		err = k.uncredit(credit)
+	} else {
+		err = k.credit(credit)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("platform", credit.Platform).Msg("failed to save kek data")
+	}
+
+	return nil
+}
+
+// KeywordReaction turns a message containing one of the kek keywords into
+// a reaction to the previous message in the channel, so laughing in text
+// counts like reacting. Earlier laughs are skipped, so everyone laughing
+// at a joke credits its author. It returns nil for any other message, and
+// must be called before the message is added to the history.
+func KeywordReaction(env *Env, message *models.Message) *models.MessageReaction {
+	keywords := env.Config.GetKekKeywords()
+
+	keyword, ok := kekKeyword(keywords, message.Content)
+	if !ok {
+		return nil
+	}
+
+	target := env.History.Previous(message, func(m *models.Message) bool {
+		_, laugh := kekKeyword(keywords, m.Content)
+		return !laugh
+	})
+	if target == nil {
+		return nil
+	}
+
+	return &models.MessageReaction{
+		Message:   target,
+		Emoji:     keyword,
+		Reactor:   message.Author,
+		ReactorID: message.AuthorID,
+	}
+}
+
+// kekKeyword returns the first of keywords found as a word in content.
+func kekKeyword(keywords []string, content string) (string, bool) {
+	if len(keywords) == 0 {
+		return "", false
+	}
+
+	words := strings.FieldsFunc(content, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		for _, keyword := range keywords {
+			if strings.EqualFold(word, keyword) {
+				return keyword, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// isKek reports whether emoji is one of the kek emojis or keywords.
+// Colons around emoji names, as slack and discord write them, are ignored.
+func isKek(cfg *config.Config, emoji string) bool {
+	emoji = strings.Trim(emoji, ":")
+	for _, kek := range append(cfg.GetKekEmojis(), cfg.GetKekKeywords()...) {
+		if strings.EqualFold(emoji, strings.Trim(kek, ":")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mentioned returns the user ID or name in a mention such as <@123> or
+// @alice.
+func mentioned(user string) string {
+	if id, ok := strings.CutPrefix(user, "<@"); ok {
+		return strings.TrimSuffix(strings.TrimPrefix(id, "!"), ">")
+	}
+
+	return strings.TrimPrefix(user, "@")
+}
+
+// messageKey identifies a message, by when it was received and who wrote
+// it on platforms without message IDs.
+func messageKey(message *models.Message) string {
+	if message.ID != "" {
+		return message.ID
+	}
+
+	return fmt.Sprintf("%s@%d", reactor(message), message.RecievedAt.UnixNano())
+}
+
+func keks(n int) string {
+	if n == 1 {
+		return "1 kek"
+	}
+
+	return fmt.Sprintf("%d keks", n)
+}
+
+// credit records a laugh unless the same one was recorded already.
+func (k *Kek) credit(credit kekCredit) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for _, c := range k.credits {
+		if c.same(credit) {
+			return nil
+		}
+	}
+	k.credits = append(k.credits, credit)
+
+	return k.save()
+}
+
+// uncredit removes a laugh recorded with credit.
+func (k *Kek) uncredit(credit kekCredit) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for i, c := range k.credits {
+		if c.same(credit) {
+			k.credits = append(k.credits[:i], k.credits[i+1:]...)
+			return k.save()
+		}
+	}
+
+	return nil
+}
+
+func (c kekCredit) same(other kekCredit) bool {
+	return c.Platform == other.Platform && c.Message == other.Message &&
+		c.GiverID == other.GiverID && c.Emoji == other.Emoji
+}
+
+// count returns the laughs of the user key since a time, counting every
+// account linked to it.
+func (k *Kek) count(ids *Identities, key string, since time.Time) int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	n := 0
+	for _, c := range k.credits {
+		if !c.Time.Before(since) && ids.Resolve(c.Platform, c.UserID) == key {
+			n++
+		}
+	}
+
+	return n
+}
+
+// find looks a user up by ID or name, preferring users on platform. The
+// name is the one the user last had when laughed at, or the name of the
+// identity their account is linked to.
+func (k *Kek) find(ids *Identities, platform, user string) (kekScore, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var found kekScore
+	ok := false
+	for _, c := range k.credits {
+		score := creditedScore(ids, c)
+		if c.UserID != user && !strings.EqualFold(c.User, user) && !strings.EqualFold(score.User, user) {
+			continue
+		}
+		if ok && (found.Platform == platform || found.Platform == "") && c.Platform != platform {
+			continue
+		}
+
+		found = score
+		ok = true
+	}
+
+	return found, ok
+}
+
+// scores returns the laughs of every user since a time, most first.
+// Accounts linked to the same identity are counted together.
+func (k *Kek) scores(ids *Identities, since time.Time) []kekScore {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	byUser := make(map[string]*kekScore)
+	for _, c := range k.credits {
+		if c.Time.Before(since) {
+			continue
+		}
+
+		score := creditedScore(ids, c)
+		if existing, ok := byUser[score.Key]; ok {
+			score.Keks = existing.Keks
+		}
+		score.Keks++
+		byUser[score.Key] = &score
+	}
+
+	scores := make([]kekScore, 0, len(byUser))
+	for _, score := range byUser {
+		scores = append(scores, *score)
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Keks != scores[j].Keks {
+			return scores[i].Keks > scores[j].Keks
+		}
+		return scores[i].User < scores[j].User
+	})
+
+	return scores
+}
+
+// creditedScore returns the user credited by c, without any laughs counted.
+func creditedScore(ids *Identities, c kekCredit) kekScore {
+	if name, ok := ids.Name(c.Platform, c.UserID); ok {
+		return kekScore{Key: ids.Resolve(c.Platform, c.UserID), User: name}
+	}
+
+	return kekScore{Key: ids.Resolve(c.Platform, c.UserID), Platform: c.Platform, User: c.User}
+}
+
+func (k *Kek) save() error {
+	if err := os.MkdirAll(k.dir, 0755); err != nil {
+		return err
+	}
+
+	out, err := json.MarshalIndent(persistedKek{Credits: k.credits}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(k.dir, "kek.json"), out, 0644)
+}
+
+func (k *Kek) load() error {
+	data, err := os.ReadFile(filepath.Join(k.dir, "kek.json"))
+	if err != nil {
+		return err
+	}
+
+	var state persistedKek
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	k.credits = state.Credits
+
+	log.Info().Str("file", filepath.Join(k.dir, "kek.json")).Int("credits", len(k.credits)).Msg("loaded kek data")
+	return nil
+}
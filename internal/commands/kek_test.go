@@ -0,0 +1,115 @@
+package commands_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/testkit"
+)
+
+func TestKekReactions(t *testing.T) {
+	h := testkit.New(t)
+
+	joke := h.Post("bob", "a joke")
+	h.React("alice", joke, "😂")
+	h.React("carol", joke, ":joy:")
+	h.React("alice", joke, "😂") // counted once
+	h.React("bob", joke, "😂")   // self-reactions do not count
+	h.React("carol", joke, "👍") // not a kek emoji
+	h.React("dave", joke, "🤣")
+
+	if got := h.Markdown("alice", ">kek bob"); got != "bob has 3 keks, 3 in the past week and 3 in the past month" {
+		t.Errorf("kek bob got %q", got)
+	}
+
+	h.Unreact("dave", joke, "🤣")
+	h.Unreact("dave", joke, "🤣")
+	if got := h.Markdown("bob", ">kek"); got != "bob has 2 keks, 2 in the past week and 2 in the past month" {
+		t.Errorf("kek got %q", got)
+	}
+
+	if got := h.Markdown("alice", ">kek"); got != "alice has no keks yet" {
+		t.Errorf("kek without keks got %q", got)
+	}
+	if got := h.Markdown("alice", ">kek <@erin>"); got != "erin has no keks yet" {
+		t.Errorf("kek of an unknown user got %q", got)
+	}
+}
+
+func TestKekKeywords(t *testing.T) {
+	h := testkit.New(t)
+
+	h.Post("alice", "lol") // nothing to laugh at yet
+	h.Post("alice", "a joke")
+	h.Post("bob", "LOL")
+	h.Post("carol", "lol, good one") // still alice's joke
+	h.Post("bob", "another joke")
+	h.Post("bob", "kek") // laughing at your own joke does not count
+	h.Post("dave", "kek")
+	h.Post("alice", "lollipop") // not a keyword
+
+	if got := h.Markdown("alice", ">kek @bob"); got != "bob has 1 kek, 1 in the past week and 1 in the past month" {
+		t.Errorf("kek bob got %q", got)
+	}
+	if got := h.Markdown("alice", ">kek"); got != "alice has 2 keks, 2 in the past week and 2 in the past month" {
+		t.Errorf("kek got %q", got)
+	}
+}
+
+func TestKekKeywordsOff(t *testing.T) {
+	h := testkit.New(t, func(cfg *config.Config) {
+		cfg.Kek.Emojis = []string{"💀"}
+		cfg.Kek.Keywords = []string{}
+	})
+
+	joke := h.Post("bob", "a joke")
+	h.Post("alice", "lol")
+	h.React("alice", joke, "😂")
+	h.React("carol", joke, "💀")
+
+	if got := h.Markdown("alice", ">kek bob"); got != "bob has 1 kek, 1 in the past week and 1 in the past month" {
+		t.Errorf("kek bob got %q", got)
+	}
+}
+
+func TestKekLeaderboard(t *testing.T) {
+	h := testkit.New(t)
+
+	if got := h.Markdown("alice", ">kek leaderboard week"); got != "nobody has any keks (past week)" {
+		t.Errorf("empty leaderboard got %q", got)
+	}
+
+	old := h.Post("carol", "an old joke")
+	for _, reactor := range []string{"alice", "bob", "dave"} {
+		h.React(reactor, old, "😂")
+	}
+
+	h.Clock.Advance(10 * 24 * time.Hour)
+	joke := h.Post("bob", "a joke")
+	h.React("alice", joke, "😂")
+	h.React("carol", joke, "🤣")
+	h.React("alice", h.Post("alice", "me"), "😂")
+	h.React("bob", h.Post("alice", "another joke"), "😂")
+
+	var got string
+	for _, period := range []string{"week", "month", "all"} {
+		got += h.Markdown("dave", ">kek leaderboard "+period) + "\n\n"
+	}
+	got += h.Markdown("dave", ">kek leaderboard") + "\n"
+
+	testkit.Golden(t, "kek_leaderboard", got)
+}
+
+func TestKekPersisted(t *testing.T) {
+	h := testkit.New(t)
+	h.React("alice", h.Post("bob", "a joke"), "😂")
+
+	restarted := testkit.New(t, func(cfg *config.Config) {
+		cfg.DataDir = h.Dir
+		cfg.StateDir = h.StateDir
+	})
+	if got := restarted.Markdown("alice", ">kek bob"); got != "bob has 1 kek, 1 in the past week and 1 in the past month" {
+		t.Errorf("kek bob after restart got %q", got)
+	}
+}
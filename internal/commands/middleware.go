@@ -0,0 +1,15 @@
+package commands
+
+// Middleware wraps a command handler to add behaviour around it, in the
+// same style as chi's func(http.Handler) http.Handler middleware.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Chain wraps handler in middlewares. The first middleware is the outermost
+// one, so it runs first and sees the final response last.
+func Chain(handler HandlerFunc, middlewares ...Middleware) HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/distrobyte/gerry/internal/commands"
+	"github.com/distrobyte/gerry/internal/models"
+)
+
+// ValidateArgs replies with the usage of the command when the arguments do
+// not match its schema.
+func ValidateArgs(next commands.HandlerFunc) commands.HandlerFunc {
+	return func(ctx context.Context, req *commands.Request) *models.Response {
+		if err := req.Command.ValidateArgs(req.Args); err != nil {
+			return models.NewTextResponse(fmt.Sprintf("%s\nusage: %s", err, req.Command.Synopsis(req.Env.Config.GetBotPrefix())))
+		}
+
+		return next(ctx, req)
+	}
+}
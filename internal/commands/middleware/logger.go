@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/commands"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// Logger logs every command once it has run, along with how long it took
+// since the message was received.
+func Logger(next commands.HandlerFunc) commands.HandlerFunc {
+	return func(ctx context.Context, req *commands.Request) *models.Response {
+		response := next(ctx, req)
+
+		fields(log.Info(), req, "message").
+			Str("content", req.Message.Content).
+			Str("id", req.Message.ID).
+			Bool("responded", !response.IsEmpty()).
+			TimeDiff("duration", time.Now(), req.Message.RecievedAt).
+			Msg("handled message")
+
+		return response
+	}
+}
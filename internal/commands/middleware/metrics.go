@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/commands"
+	"github.com/distrobyte/gerry/internal/models"
+)
+
+// Command metrics are published with expvar, keyed by command path.
+var (
+	calls    = expvar.NewMap("commands_total")
+	seconds  = expvar.NewMap("commands_seconds_total")
+	panics   = expvar.NewMap("commands_panics_total")
+	timeouts = expvar.NewMap("commands_timeouts_total")
+)
+
+// Metrics counts the calls of every command and the time spent in them.
+func Metrics(next commands.HandlerFunc) commands.HandlerFunc {
+	return func(ctx context.Context, req *commands.Request) *models.Response {
+		start := time.Now()
+		defer func() {
+			path := req.Command.Path()
+			calls.Add(path, 1)
+			seconds.AddFloat(path, time.Since(start).Seconds())
+		}()
+
+		return next(ctx, req)
+	}
+}
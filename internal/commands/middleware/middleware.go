@@ -0,0 +1,18 @@
+// Package middleware provides the command middlewares the bot layers around
+// every command handler, modelled on github.com/go-chi/chi/v5/middleware.
+package middleware
+
+import (
+	"github.com/distrobyte/gerry/internal/commands"
+	"github.com/rs/zerolog"
+)
+
+// fields adds the fields identifying a command invocation to a log event.
+func fields(e *zerolog.Event, req *commands.Request, event string) *zerolog.Event {
+	return e.
+		Str("platform", req.Message.Platform).
+		Str("event", event).
+		Str("command", req.Command.Path()).
+		Str("author", req.Message.Author).
+		Str("channel", req.Message.Channel)
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/distrobyte/gerry/internal/commands"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// Permissions stops users from running commands above their permission
+// level.
+func Permissions(next commands.HandlerFunc) commands.HandlerFunc {
+	return func(ctx context.Context, req *commands.Request) *models.Response {
+		required := req.Command.RequiredPermission()
+		if commands.PermissionOf(req.Env, req.Message) >= required {
+			return next(ctx, req)
+		}
+
+		fields(log.Warn(), req, "permission_denied").
+			Str("author_id", req.Message.AuthorID).
+			Str("required", required.String()).
+			Msg("permission denied")
+
+		return models.NewTextResponse(fmt.Sprintf("sorry, %s can only be used by %ss", req.Command.Path(), required))
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/commands"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// RateLimit enforces the cooldowns of the command and the global rate limit.
+// Users are told how long to wait unless the ratelimit config is silent.
+func RateLimit(next commands.HandlerFunc) commands.HandlerFunc {
+	return func(ctx context.Context, req *commands.Request) *models.Response {
+		wait, ok := acquire(req)
+		if ok {
+			return next(ctx, req)
+		}
+
+		fields(log.Warn(), req, "rate_limited").
+			Dur("wait", wait).
+			Msg("rate limited")
+
+		// the global limit is always silent, replying would only add to the load
+		if wait == 0 || req.Env.Config.IsRateLimitSilent() {
+			return nil
+		}
+
+		return models.NewTextResponse(fmt.Sprintf("try again in %ds", int(math.Ceil(wait.Seconds()))))
+	}
+}
+
+// acquire starts the cooldowns of the command and takes a token from the
+// global bucket. If the command may not run, it returns how long is left on
+// the cooldown, or 0 when the global limit was hit.
+func acquire(req *commands.Request) (time.Duration, bool) {
+	env, cmd, message := req.Env, req.Command, req.Message
+
+	cooldown := cmd.Cooldown
+	if configured, ok := env.Config.GetCooldown(cmd.Path()); ok {
+		cooldown = configured
+	}
+
+	user := message.AuthorID
+	if user == "" {
+		user = message.Author
+	}
+
+	if wait, ok := env.Limiter.Acquire(map[string]time.Duration{
+		"command:" + cmd.Path(): cooldown.Command,
+		"user:" + message.Platform + ":" + user + ":" + cmd.Path():               cooldown.User,
+		"channel:" + message.Platform + ":" + message.Channel + ":" + cmd.Path(): cooldown.Channel,
+	}); !ok {
+		return wait, false
+	}
+
+	return 0, env.Limiter.Allow()
+}
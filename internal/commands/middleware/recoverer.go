@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/distrobyte/gerry/internal/commands"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// Recoverer recovers from panics in the command, logs them with a stack
+// trace and tells the user the command failed instead of crashing the bot.
+func Recoverer(next commands.HandlerFunc) commands.HandlerFunc {
+	return func(ctx context.Context, req *commands.Request) (response *models.Response) {
+		defer func() {
+			if r := recover(); r != nil {
+				panics.Add(req.Command.Path(), 1)
+
+				fields(log.Error(), req, "panic").
+					Interface("panic", r).
+					Bytes("stack", debug.Stack()).
+					Msg("command panicked")
+
+				response = models.NewTextResponse(fmt.Sprintf("sorry, %s failed", req.Command.Path()))
+			}
+		}()
+
+		return next(ctx, req)
+	}
+}
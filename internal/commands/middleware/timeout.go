@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/commands"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// Timeout cancels the context of the command once it runs longer than its
+// timeout, and tells the user if the command gave up because of it.
+func Timeout(next commands.HandlerFunc) commands.HandlerFunc {
+	return func(ctx context.Context, req *commands.Request) *models.Response {
+		ctx, cancel := context.WithTimeout(ctx, timeout(req))
+		defer cancel()
+
+		response := next(ctx, req)
+		if response == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			timeouts.Add(req.Command.Path(), 1)
+
+			fields(log.Warn(), req, "timeout").Msg("command timed out")
+
+			return models.NewTextResponse(fmt.Sprintf("sorry, %s took too long", req.Command.Path()))
+		}
+
+		return response
+	}
+}
+
+// timeout returns how long the command may run: the configured timeout for
+// the command, else its own default, else the configured default.
+func timeout(req *commands.Request) time.Duration {
+	if timeout, ok := req.Env.Config.GetCommandTimeout(req.Command.Path()); ok {
+		return timeout
+	}
+
+	if req.Command.Timeout > 0 {
+		return req.Command.Timeout
+	}
+
+	return req.Env.Config.GetDefaultCommandTimeout()
+}
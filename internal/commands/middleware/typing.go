@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/commands"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// commands answering faster than this do not show the indicator
+	typingDelay = 500 * time.Millisecond
+	// discord clears the indicator after ten seconds
+	typingInterval = 8 * time.Second
+)
+
+// Typing shows a typing indicator on platforms that have one while a slow
+// command runs.
+func Typing(next commands.HandlerFunc) commands.HandlerFunc {
+	return func(ctx context.Context, req *commands.Request) *models.Response {
+		typer, ok := req.Platform.(platform.Typer)
+		if !ok {
+			return next(ctx, req)
+		}
+
+		done := make(chan struct{})
+		defer close(done)
+
+		go func() {
+			timer := time.NewTimer(typingDelay)
+			defer timer.Stop()
+
+			for {
+				select {
+				case <-done:
+					return
+				case <-ctx.Done():
+					return
+				case <-timer.C:
+				}
+
+				if err := typer.Typing(req.Message.Channel); err != nil {
+					log.Debug().Err(err).Str("platform", req.Message.Platform).Msg("failed to send typing indicator")
+					return
+				}
+				timer.Reset(typingInterval)
+			}
+		}()
+
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/models"
+)
+
+// Permission is the level of trust needed to run a command. Higher levels
+// include the lower ones.
+type Permission int
+
+const (
+	PermissionEveryone Permission = iota
+	PermissionModerator
+	PermissionAdmin
+)
+
+func (p Permission) String() string {
+	switch p {
+	case PermissionModerator:
+		return "moderator"
+	case PermissionAdmin:
+		return "admin"
+	default:
+		return "everyone"
+	}
+}
+
+// RequiredPermission returns the permission needed to run the command, which
+// is the highest of its own and that of the commands it is nested under.
+func (c *Command) RequiredPermission() Permission {
+	permission := c.Permission
+	for parent := c.parent; parent != nil; parent = parent.parent {
+		permission = max(permission, parent.Permission)
+	}
+
+	return permission
+}
+
+// PermissionOf returns the highest permission the author of message holds
+// according to the config of env. Authors without a platform ID are never
+// trusted, since their name is all that identifies them. Users listed in a
+// group hold its permission from every account linked with theirs, roles
+// only count on the platform they were given on.
+func PermissionOf(env *Env, message *models.Message) Permission {
+	if message.AuthorID == "" {
+		return PermissionEveryone
+	}
+
+	accounts := env.Identities.Accounts(message.Platform, message.AuthorID)
+
+	switch {
+	case inGroup(env.Config.GetAdmins(), message, accounts):
+		return PermissionAdmin
+	case inGroup(env.Config.GetModerators(), message, accounts):
+		return PermissionModerator
+	default:
+		return PermissionEveryone
+	}
+}
+
+func inGroup(group config.PermissionGroup, message *models.Message, accounts []Account) bool {
+	for _, account := range accounts {
+		if contains(group.Users[account.Platform], account.ID) {
+			return true
+		}
+	}
+
+	for _, role := range message.AuthorRoles {
+		if contains(group.Roles[message.Platform], role) {
+			return true
+		}
+	}
+
+	return false
+}
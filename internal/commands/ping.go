@@ -1,5 +1,19 @@
 package commands
 
-func PingCommand() string {
-	return "Pong!"
+import (
+	"context"
+
+	"github.com/distrobyte/gerry/internal/models"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "ping",
+		Summary: "Check that the bot is alive",
+		Handler: PingCommand,
+	})
+}
+
+func PingCommand(ctx context.Context, req *Request) *models.Response {
+	return models.NewTextResponse("Pong!")
 }
@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/distrobyte/gerry/internal/models"
+)
+
+func init() {
+	userArg := Arg{Name: "user", Description: "Author of the message, the last message in the channel if not given"}
+
+	Register(&Command{
+		Name:    "react",
+		Summary: "React to a recent message",
+		Usage:   "For platforms without reactions, such as Mumble and IRC. Reacts to the last message in the channel, or the last one by user.",
+		Args: []Arg{
+			{Name: "emoji", Description: "Emoji to react with", Required: true},
+			userArg,
+		},
+		Examples: []string{"react 😂", "react 😂 alice"},
+		Handler:  ReactCommand,
+	})
+
+	Register(&Command{
+		Name:    "unreact",
+		Summary: "Take back a reaction made with react",
+		Args: []Arg{
+			{Name: "emoji", Description: "Emoji reacted with", Required: true},
+			userArg,
+		},
+		Examples: []string{"unreact 😂"},
+		Handler:  UnreactCommand,
+	})
+}
+
+func ReactCommand(ctx context.Context, req *Request) *models.Response {
+	emoji, author := reactArgs(req.Args)
+	reactor := reactor(req.Message)
+
+	target, ok := req.Env.History.React(req.Message.Platform, req.Message.Channel, author, reactor, emoji)
+	if target == nil {
+		return models.NewTextResponse("there is no message to react to")
+	}
+	if !ok {
+		return models.NewTextResponse(fmt.Sprintf("you already reacted to %s's message with %s", target.Author, emoji))
+	}
+
+	return dispatchReaction(ctx, req, target, emoji, false)
+}
+
+func UnreactCommand(ctx context.Context, req *Request) *models.Response {
+	emoji, author := reactArgs(req.Args)
+	reactor := reactor(req.Message)
+
+	target := req.Env.History.Unreact(req.Message.Platform, req.Message.Channel, author, reactor, emoji)
+	if target == nil {
+		return models.NewTextResponse(fmt.Sprintf("you have not reacted with %s", emoji))
+	}
+
+	return dispatchReaction(ctx, req, target, emoji, true)
+}
+
+func reactArgs(args []string) (emoji, author string) {
+	emoji = args[0]
+	if len(args) > 1 {
+		author = args[1]
+	}
+
+	return emoji, author
+}
+
+// reactor identifies the author of a message for tracking reactions.
+func reactor(message *models.Message) string {
+	if message.AuthorID != "" {
+		return message.AuthorID
+	}
+
+	return message.Author
+}
+
+// dispatchReaction runs the reaction handlers as if the author of the
+// command reacted natively. Every response but the last is sent straight
+// away, the last one is the response of the command.
+func dispatchReaction(ctx context.Context, req *Request, target *models.Message, emoji string, removed bool) *models.Response {
+	responses := req.Env.Registry.DispatchReaction(ctx, &ReactionRequest{
+		Env: req.Env,
+		Reaction: &models.MessageReaction{
+			Message:   target,
+			Emoji:     emoji,
+			Reactor:   req.Message.Author,
+			ReactorID: req.Message.AuthorID,
+			Removed:   removed,
+		},
+		Platform: req.Platform,
+	})
+
+	if len(responses) == 0 {
+		return nil
+	}
+
+	for _, response := range responses[:len(responses)-1] {
+		if err := req.Platform.Reply(req.Message, response); err != nil {
+			return models.NewTextResponse(err.Error())
+		}
+	}
+
+	return responses[len(responses)-1]
+}
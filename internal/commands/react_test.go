@@ -0,0 +1,115 @@
+package commands_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/distrobyte/gerry/internal/commands"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/testkit"
+)
+
+// reactions subscribes to the reactions of a bot and records them.
+type reactions struct {
+	mu   sync.Mutex
+	seen []models.MessageReaction
+}
+
+func subscribe(h *testkit.Harness) *reactions {
+	r := &reactions{}
+	h.Bot.Registry().OnReaction(func(ctx context.Context, req *commands.ReactionRequest) *models.Response {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.seen = append(r.seen, *req.Reaction)
+		return nil
+	})
+
+	return r
+}
+
+func (r *reactions) last(t *testing.T, n int) models.MessageReaction {
+	t.Helper()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.seen) != n {
+		t.Fatalf("got %d reactions, want %d", len(r.seen), n)
+	}
+
+	return r.seen[n-1]
+}
+
+func TestReactCommand(t *testing.T) {
+	h := testkit.New(t)
+	seen := subscribe(h)
+
+	if got := h.Markdown("alice", ">react 😂"); got != "there is no message to react to" {
+		t.Errorf("react without messages got %q", got)
+	}
+
+	joke := h.Post("bob", "a joke")
+	h.Post("carol", "not funny")
+	h.Send("carol", ">uptime")
+
+	if response := h.Send("alice", ">react 😂 bob"); response != nil {
+		t.Errorf("react got a response %+v", response)
+	}
+
+	reaction := seen.last(t, 1)
+	if reaction.Message != joke || reaction.Emoji != "😂" || reaction.Reactor != "alice" || reaction.ReactorID != "alice" || reaction.Removed {
+		t.Errorf("unexpected reaction %+v", reaction)
+	}
+
+	if got := h.Markdown("alice", ">react 😂 bob"); got != "you already reacted to bob's message with 😂" {
+		t.Errorf("second react got %q", got)
+	}
+
+	h.Send("alice", ">unreact 😂")
+	if reaction := seen.last(t, 2); reaction.Message != joke || !reaction.Removed {
+		t.Errorf("unexpected reaction %+v", reaction)
+	}
+
+	if got := h.Markdown("alice", ">unreact 😂"); got != "you have not reacted with 😂" {
+		t.Errorf("second unreact got %q", got)
+	}
+
+	// without a user the last message in the channel is reacted to
+	h.Send("alice", ">react 👍")
+	if reaction := seen.last(t, 3); reaction.Message.Author != "carol" {
+		t.Errorf("reacted to %+v", reaction.Message)
+	}
+}
+
+func TestReactionHandlers(t *testing.T) {
+	h := testkit.New(t)
+	seen := subscribe(h)
+	h.Bot.Registry().OnReaction(func(ctx context.Context, req *commands.ReactionRequest) *models.Response {
+		panic("broken handler")
+	})
+	h.Bot.Registry().OnReaction(func(ctx context.Context, req *commands.ReactionRequest) *models.Response {
+		if req.Reaction.Removed {
+			return nil
+		}
+		return models.NewTextResponse(req.Reaction.Reactor + " reacted with " + req.Reaction.Emoji)
+	})
+
+	joke := h.Post("bob", "a joke")
+
+	sent := h.React("alice", joke, "😂")
+	if len(sent) != 1 || sent[0].Response.Text != "alice reacted with 😂" || sent[0].ReplyTo != joke {
+		t.Errorf("unexpected responses %+v", sent)
+	}
+	if reaction := seen.last(t, 1); reaction.Emoji != "😂" || reaction.Removed {
+		t.Errorf("unexpected reaction %+v", reaction)
+	}
+
+	if sent := h.Unreact("alice", joke, "😂"); len(sent) != 0 {
+		t.Errorf("unexpected responses %+v", sent)
+	}
+	if reaction := seen.last(t, 2); !reaction.Removed {
+		t.Errorf("unexpected reaction %+v", reaction)
+	}
+}
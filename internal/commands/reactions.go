@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"context"
+	"runtime/debug"
+
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/rs/zerolog/log"
+)
+
+// ReactionHandlerFunc handles a reaction being added to or removed from a
+// message. A nil response means nothing is sent.
+type ReactionHandlerFunc func(ctx context.Context, req *ReactionRequest) *models.Response
+
+// ReactionRequest is a single reaction event.
+type ReactionRequest struct {
+	Env      *Env
+	Reaction *models.MessageReaction
+	// Platform is the platform the reaction came from
+	Platform platform.Platform
+}
+
+// OnReaction subscribes handlers to the reactions added and removed on
+// every platform. Handlers run in the order they were added.
+func (r *Registry) OnReaction(handlers ...ReactionHandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reactions = append(r.reactions, handlers...)
+}
+
+// DispatchReaction runs every reaction handler and returns their
+// responses. A handler panicking does not stop the others.
+func (r *Registry) DispatchReaction(ctx context.Context, req *ReactionRequest) []*models.Response {
+	r.mu.RLock()
+	handlers := append([]ReactionHandlerFunc(nil), r.reactions...)
+	r.mu.RUnlock()
+
+	var responses []*models.Response
+	for _, handler := range handlers {
+		response := runReaction(ctx, handler, req)
+		if !response.IsEmpty() {
+			responses = append(responses, response)
+		}
+	}
+
+	return responses
+}
+
+func runReaction(ctx context.Context, handler ReactionHandlerFunc, req *ReactionRequest) (response *models.Response) {
+	defer func() {
+		if v := recover(); v != nil {
+			log.Error().
+				Interface("panic", v).
+				Bytes("stack", debug.Stack()).
+				Str("platform", req.Reaction.Message.Platform).
+				Str("emoji", req.Reaction.Emoji).
+				Msg("reaction handler panicked")
+			response = nil
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+var builtinReactions []ReactionHandlerFunc
+
+// RegisterReaction adds a built-in reaction handler. It is meant to be
+// called from init functions; every bot subscribes the built-in handlers
+// in its own registry.
+func RegisterReaction(handler ReactionHandlerFunc) {
+	builtinReactions = append(builtinReactions, handler)
+}
+
+// BuiltinReactions returns the handlers added with RegisterReaction.
+func BuiltinReactions() []ReactionHandlerFunc {
+	return append([]ReactionHandlerFunc(nil), builtinReactions...)
+}
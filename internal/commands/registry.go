@@ -0,0 +1,395 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/distrobyte/gerry/internal/ratelimit"
+)
+
+// HandlerFunc runs a command and returns the response to send back.
+// A nil response means nothing is sent. ctx is cancelled when the command
+// times out or the bot shuts down; long running handlers should stop then.
+type HandlerFunc func(ctx context.Context, req *Request) *models.Response
+
+// Env holds the state of the bot a command runs against. Each bot has its
+// own, so commands must not keep state of their own in package variables.
+type Env struct {
+	Config   *config.Config
+	Registry *Registry
+	Karting  *Karting
+	Kek      *Kek
+	// Identities links the accounts of users across platforms
+	Identities *Identities
+	Limiter    *ratelimit.Limiter
+	StartTime  time.Time
+	// History holds the recent messages of each channel
+	History *History
+	// Now returns the current time, swapped out in tests
+	Now func() time.Time
+	// Shutdown asks the bot to stop
+	Shutdown func()
+	// Connections returns the state of the connection of every platform
+	Connections func() []platform.Status
+}
+
+// Request is a single invocation of a command.
+type Request struct {
+	Env *Env
+	// Command is the resolved command, which may be a sub-command
+	Command *Command
+	// Args are the arguments left after the command path was consumed
+	Args    []string
+	Message *models.Message
+	// Platform is the platform the message came from
+	Platform platform.Platform
+}
+
+// ArgType is the kind of value an argument takes. Arguments are always
+// passed to handlers as text, the type is used to validate them and by
+// platforms with typed command options.
+type ArgType int
+
+const (
+	ArgString ArgType = iota
+	ArgInteger
+	ArgNumber
+	ArgBoolean
+	// ArgUser is a user mention
+	ArgUser
+)
+
+// Arg describes a positional argument accepted by a command.
+type Arg struct {
+	Name        string
+	Description string
+	Type        ArgType
+	Required    bool
+	// Variadic marks the last argument as accepting one or more values
+	Variadic bool
+	// Max is how many values a variadic argument takes on platforms with a
+	// fixed set of options, such as discord slash commands. Without it the
+	// values are entered as a single text option.
+	Max     int
+	Choices []string
+	// Complete suggests values starting with value, for platforms with
+	// autocompletion
+	Complete func(ctx context.Context, env *Env, value string) []string
+}
+
+// Command describes a chat command: its metadata and how to run it.
+// A command with sub-commands and no handler acts as a group.
+type Command struct {
+	Name    string
+	Aliases []string
+	Summary string
+	Usage   string
+	Args    []Arg
+	// Examples are invocations without the prefix, e.g. "karting stats"
+	Examples    []string
+	SubCommands []*Command
+	// Permission is required to run the command and its sub-commands
+	Permission Permission
+	// Cooldown is the default cooldown, overridden by the ratelimit config
+	Cooldown config.Cooldown
+	// Timeout overrides the default timeout, the timeouts config overrides both
+	Timeout time.Duration
+	Handler HandlerFunc
+
+	parent *Command
+}
+
+// Path returns the full name of the command, e.g. "karting race".
+func (c *Command) Path() string {
+	if c.parent == nil {
+		return c.Name
+	}
+
+	return c.parent.Path() + " " + c.Name
+}
+
+// Parent returns the command this one is a sub-command of, if any.
+func (c *Command) Parent() *Command {
+	return c.parent
+}
+
+// Synopsis returns a one-line usage string for the command, e.g.
+// ">karting register <driver>".
+func (c *Command) Synopsis(prefix string) string {
+	synopsis := prefix + c.Path()
+
+	if len(c.SubCommands) > 0 && len(c.Args) == 0 {
+		return synopsis + " <" + strings.Join(c.subCommandNames(), "|") + ">"
+	}
+
+	for _, arg := range c.Args {
+		name := arg.Name
+		if len(arg.Choices) > 0 {
+			name = strings.Join(arg.Choices, "|")
+		}
+
+		if arg.Variadic {
+			name += "..."
+		}
+
+		if arg.Required {
+			synopsis += " <" + name + ">"
+		} else {
+			synopsis += " [" + name + "]"
+		}
+	}
+
+	return synopsis
+}
+
+// SubCommand returns the sub-command matching name or one of its aliases.
+func (c *Command) SubCommand(name string) *Command {
+	for _, sub := range c.SubCommands {
+		if sub.matches(name) {
+			return sub
+		}
+	}
+
+	return nil
+}
+
+// ValidateArgs checks args against the argument schema of the command.
+func (c *Command) ValidateArgs(args []string) error {
+	for i, arg := range c.Args {
+		if i >= len(args) {
+			if arg.Required {
+				return fmt.Errorf("missing argument <%s>", arg.Name)
+			}
+			break
+		}
+
+		values := args[i : i+1]
+		if arg.Variadic {
+			values = args[i:]
+		}
+
+		for _, value := range values {
+			if len(arg.Choices) > 0 && !contains(arg.Choices, value) {
+				return fmt.Errorf("invalid %s %q, expected one of: %s", arg.Name, value, strings.Join(arg.Choices, ", "))
+			}
+
+			if err := arg.Type.validate(value); err != nil {
+				return fmt.Errorf("invalid %s %q, %w", arg.Name, value, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (t ArgType) validate(value string) error {
+	switch t {
+	case ArgInteger:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("expected a whole number")
+		}
+	case ArgNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("expected a number")
+		}
+	case ArgBoolean:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("expected true or false")
+		}
+	}
+
+	return nil
+}
+
+func (c *Command) matches(name string) bool {
+	return c.Name == name || contains(c.Aliases, name)
+}
+
+func (c *Command) subCommandNames() []string {
+	names := make([]string, 0, len(c.SubCommands))
+	for _, sub := range c.SubCommands {
+		names = append(names, sub.Name)
+	}
+
+	return names
+}
+
+func (c *Command) link() {
+	for _, sub := range c.SubCommands {
+		// built-in commands are shared between registries, so only write
+		// when the tree has not been linked yet
+		if sub.parent != c {
+			sub.parent = c
+		}
+		sub.link()
+	}
+}
+
+// Registry holds the set of commands the bot knows about.
+type Registry struct {
+	mu          sync.RWMutex
+	commands    map[string]*Command
+	aliases     map[string]*Command
+	middlewares []Middleware
+	reactions   []ReactionHandlerFunc
+}
+
+// NewRegistry returns an empty command registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		commands: make(map[string]*Command),
+		aliases:  make(map[string]*Command),
+	}
+}
+
+// Register adds commands to the registry. Names and aliases must be unique.
+func (r *Registry) Register(cmds ...*Command) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, cmd := range cmds {
+		if cmd.Name == "" {
+			return fmt.Errorf("command has no name")
+		}
+
+		for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+			if _, ok := r.commands[name]; ok {
+				return fmt.Errorf("command %q is already registered", name)
+			}
+			if _, ok := r.aliases[name]; ok {
+				return fmt.Errorf("command %q is already registered as an alias", name)
+			}
+		}
+
+		cmd.link()
+		r.commands[cmd.Name] = cmd
+		for _, alias := range cmd.Aliases {
+			r.aliases[alias] = cmd
+		}
+	}
+
+	return nil
+}
+
+// Use appends middlewares to the stack every command of the registry runs
+// through. Middlewares run in the order they were added.
+func (r *Registry) Use(middlewares ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// Handler returns the handler of cmd wrapped in the middleware stack.
+func (r *Registry) Handler(cmd *Command) HandlerFunc {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return Chain(cmd.Handler, r.middlewares...)
+}
+
+// Lookup returns the top-level command matching name or one of its aliases.
+func (r *Registry) Lookup(name string) (*Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if cmd, ok := r.commands[name]; ok {
+		return cmd, true
+	}
+
+	cmd, ok := r.aliases[name]
+	return cmd, ok
+}
+
+// Resolve walks args down the command tree and returns the deepest matching
+// command along with the arguments left over for it.
+func (r *Registry) Resolve(args []string) (*Command, []string, bool) {
+	if len(args) == 0 {
+		return nil, nil, false
+	}
+
+	cmd, ok := r.Lookup(args[0])
+	if !ok {
+		return nil, nil, false
+	}
+
+	args = args[1:]
+	for len(args) > 0 {
+		sub := cmd.SubCommand(args[0])
+		if sub == nil {
+			break
+		}
+
+		cmd = sub
+		args = args[1:]
+	}
+
+	return cmd, args, true
+}
+
+// Commands returns all top-level commands sorted by name.
+func (r *Registry) Commands() []*Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cmds := make([]*Command, 0, len(r.commands))
+	for _, cmd := range r.commands {
+		cmds = append(cmds, cmd)
+	}
+
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Name < cmds[j].Name
+	})
+
+	return cmds
+}
+
+var builtin []*Command
+
+// Register adds a built-in command. It is meant to be called from init
+// functions; every bot registers the built-in commands in its own registry.
+func Register(cmd *Command) {
+	cmd.link()
+	builtin = append(builtin, cmd)
+}
+
+// Builtin returns the commands added with Register.
+func Builtin() []*Command {
+	return append([]*Command(nil), builtin...)
+}
+
+// Quote joins args into a command line, quoting them where needed so the
+// line splits back into the same args.
+func Quote(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		switch {
+		case arg != "" && !strings.ContainsAny(arg, " \t\n\"'\\"):
+			quoted[i] = arg
+		case !strings.Contains(arg, "'"):
+			quoted[i] = "'" + arg + "'"
+		default:
+			quoted[i] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+		}
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,21 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/distrobyte/gerry/internal/models"
+)
+
+func init() {
+	Register(&Command{
+		Name:       "shutdown",
+		Summary:    "Shut the bot down",
+		Permission: PermissionAdmin,
+		Handler:    ShutdownCommand,
+	})
+}
+
+func ShutdownCommand(ctx context.Context, req *Request) *models.Response {
+	req.Env.Shutdown()
+	return models.NewTextResponse("Shutting down...")
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/models"
+)
+
+func init() {
+	Register(&Command{
+		Name:    "status",
+		Summary: "Show the connection state of every platform",
+		Handler: StatusCommand,
+	})
+}
+
+// StatusCommand lists the platforms with how long they have been in their
+// state, how often they reconnected and the last connection error.
+func StatusCommand(ctx context.Context, req *Request) *models.Response {
+	table := models.Table{
+		Columns: []models.Column{
+			{Name: "Platform"},
+			{Name: "State"},
+			{Name: "For", AlignRight: true},
+			{Name: "Reconnects", AlignRight: true},
+			{Name: "Error"},
+		},
+	}
+
+	now := req.Env.Now()
+	for _, status := range req.Env.Connections() {
+		var errText string
+		if status.Err != nil {
+			errText = status.Err.Error()
+		}
+
+		table.Rows = append(table.Rows, []string{
+			status.Platform,
+			status.State.String(),
+			now.Sub(status.Since).Round(time.Second).String(),
+			strconv.Itoa(status.Reconnects),
+			errText,
+		})
+	}
+
+	return &models.Response{
+		Title:  "Status",
+		Tables: []models.Table{table},
+	}
+}
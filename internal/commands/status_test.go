@@ -0,0 +1,24 @@
+package commands_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/testkit"
+)
+
+func TestStatus(t *testing.T) {
+	h := testkit.New(t)
+	h.Clock.Advance(time.Hour)
+
+	got := h.Markdown("alice", ">status") + "\n\n"
+
+	h.Platform.Drop(errors.New("connection reset"), errors.New("connection refused"))
+	h.WaitConnected()
+
+	h.Clock.Advance(5 * time.Minute)
+	got += h.Markdown("alice", ">status") + "\n"
+
+	testkit.Golden(t, "status", got)
+}
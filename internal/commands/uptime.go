@@ -1,16 +1,26 @@
 package commands
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"time"
 
-	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/models"
 )
 
+func init() {
+	Register(&Command{
+		Name:    "uptime",
+		Summary: "Show how long the bot has been running",
+		Handler: UptimeCommand,
+	})
+}
+
 // UptimeCommand returns the uptime of the bot
-func UptimeCommand() string {
+func UptimeCommand(ctx context.Context, req *Request) *models.Response {
 	// uptime should be in the form months weeks days hours minutes seconds, omitting values that are 0
-	year, month, day, hour, min, sec := diff(config.StartTime, time.Now())
+	year, month, day, hour, min, sec := diff(req.Env.StartTime, req.Env.Now())
 
 	uptime := ""
 	if year > 0 {
@@ -32,11 +42,9 @@ func UptimeCommand() string {
 		uptime += fmt.Sprintf("%d seconds", sec)
 	}
 
-	return fmt.Sprintf(
-		"```\n"+
-			"Uptime: %s\n"+
-			"```",
-		uptime)
+	return &models.Response{
+		Code: []models.CodeBlock{{Content: "Uptime: " + strings.TrimSpace(uptime)}},
+	}
 }
 
 func diff(a, b time.Time) (year, month, day, hour, min, sec int) {
@@ -0,0 +1,15 @@
+package commands_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/testkit"
+)
+
+func TestUptime(t *testing.T) {
+	h := testkit.New(t)
+	h.Clock.Advance(3*24*time.Hour + 4*time.Hour + 5*time.Minute + 6*time.Second)
+
+	testkit.Golden(t, "uptime", h.Markdown("alice", ">uptime"))
+}
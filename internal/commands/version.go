@@ -1,26 +1,47 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 
 	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/models"
 )
 
-func VersionCommand(args []string) string {
+func init() {
+	Register(&Command{
+		Name:    "version",
+		Summary: "Show version information",
+		Args: []Arg{
+			{Name: "detail", Description: "Which version detail to show", Choices: []string{"number", "commit", "all"}},
+		},
+		Examples: []string{"version", "version all"},
+		Handler:  VersionCommand,
+	})
+}
+
+func VersionCommand(ctx context.Context, req *Request) *models.Response {
+	args := req.Args
 	if len(args) == 0 {
-		return fmt.Sprintf("```\nVersion: %s\n```", config.GetVersion())
+		return versionResponse(fmt.Sprintf("Version: %s", config.GetVersion()))
 	} else {
 		switch args[0] {
 		case "number":
-			return fmt.Sprintf("```\nVersion: %s\n```", config.GetVersion())
+			return versionResponse(fmt.Sprintf("Version: %s", config.GetVersion()))
 		case "commit":
-			return fmt.Sprintf("```\nCommit: %s\n```", config.GitCommit)
+			return versionResponse(fmt.Sprintf("Commit: %s", config.GitCommit))
 		case "all":
-			return fmt.Sprintf("```\nVersion: %s\nBuild date: %s\nSystem version: %s/%s\nGolang version: %s\n```",
-				config.GetVersion(), config.BuildDate, runtime.GOOS, runtime.GOARCH, runtime.Version())
+			return versionResponse(fmt.Sprintf("Version: %s\nBuild date: %s\nSystem version: %s/%s\nGolang version: %s",
+				config.GetVersion(), config.BuildDate, runtime.GOOS, runtime.GOARCH, runtime.Version()))
 		default:
-			return "Invalid argument. Use `number`, `commit` or `all`."
+			return models.NewTextResponse("Invalid argument. Use number, commit or all.")
 		}
 	}
 }
+
+func versionResponse(content string) *models.Response {
+	return &models.Response{
+		Code: []models.CodeBlock{{Content: content}},
+	}
+}
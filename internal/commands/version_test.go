@@ -0,0 +1,32 @@
+package commands_test
+
+import (
+	"testing"
+
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/testkit"
+)
+
+func TestVersion(t *testing.T) {
+	tag, exact, commit := config.GitLastTag, config.GitExactTag, config.GitCommit
+	t.Cleanup(func() {
+		config.GitLastTag, config.GitExactTag, config.GitCommit = tag, exact, commit
+	})
+	config.GitLastTag, config.GitExactTag, config.GitCommit = "v1.2.3", "", "0123456789abcdef"
+
+	h := testkit.New(t)
+
+	for _, tt := range []struct {
+		name    string
+		command string
+	}{
+		{"version", ">version"},
+		{"version_number", ">version number"},
+		{"version_commit", ">version commit"},
+		{"version_invalid", ">version nope"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			testkit.Golden(t, tt.name, h.Markdown("alice", tt.command))
+		})
+	}
+}
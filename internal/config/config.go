@@ -1,7 +1,15 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -12,61 +20,281 @@ const APP_ENVIRONMENT_LOCAL string = "LOCAL"
 const APP_ENVIRONMENT_TEST string = "TEST"
 const APP_ENVIRONMENT_PRODUCTION string = "PROD"
 
-var defaultConfig = configuration{}
+// Config is the bot configuration loaded from a yaml file. Fields left out
+// of the file take the value of their default tag, and the validate tags
+// are checked when it is loaded, see Validate. Fields tagged
+// reload:"restart" are only read on startup, see Reload.
+type Config struct {
+	Discord     discordConfig     `yaml:"discord" reload:"restart"`
+	Mumble      mumbleConfig      `yaml:"mumble" reload:"restart"`
+	IRC         ircConfig         `yaml:"irc" reload:"restart"`
+	Matrix      matrixConfig      `yaml:"matrix" reload:"restart"`
+	Telegram    telegramConfig    `yaml:"telegram" reload:"restart"`
+	Slack       slackConfig       `yaml:"slack" reload:"restart"`
+	HTTP        httpConfig        `yaml:"http" reload:"restart"`
+	Permissions permissionsConfig `yaml:"permissions"`
+	RateLimit   rateLimitConfig   `yaml:"ratelimit"`
+	Timeouts    timeoutsConfig    `yaml:"timeouts"`
+	Kek         kekConfig         `yaml:"kek"`
+	Bridge      bridgeConfig      `yaml:"bridge"`
+	Prefix      string            `yaml:"prefix" default:">"`
+	Status      string            `yaml:"status"`
+	Environment string            `yaml:"environment" default:"LOCAL" validate:"required,oneof=LOCAL TEST PROD" reload:"restart"`
+	Domain      string            `yaml:"domain" reload:"restart"`
+	Name        string            `yaml:"name" reload:"restart"`
+	DataDir     string            `yaml:"data_dir" default:"assets" reload:"restart"`
+	StateDir    string            `yaml:"state_dir" default:"state" reload:"restart"`
+
+	// sources records where values were set, by yaml path
+	sources map[string]ValueSource
+
+	// mu guards the values Reload can change. Values that need a restart
+	// never change after loading, so only the getters of the others take it.
+	mu sync.RWMutex
+}
 
-var ShutdownChannel = make(chan os.Signal, 1)
-var StartTime time.Time
+type discordConfig struct {
+	Token  string `yaml:"token" secret:"true"`
+	Enable bool   `yaml:"enable" default:"false"`
+	// Commands is where slash commands are registered: global, guild (the
+	// guilds listed in Guilds, which updates instantly) or off
+	Commands string   `yaml:"commands" default:"global" validate:"oneof=off global guild"`
+	Guilds   []string `yaml:"guilds"`
+}
 
-type configuration struct {
-	Discord     discordConfig `yaml:"discord"`
-	Mumble      mumbleConfig  `yaml:"mumble"`
-	HTTP        httpConfig    `yaml:"http"`
-	Prefix      string        `yaml:"prefix" default:">"`
-	Status      string        `yaml:"status"`
-	Environment string        `yaml:"environment" default:"LOCAL" validate:"required,oneof=LOCAL TEST PROD"`
-	Domain      string        `yaml:"domain"`
-	Name        string        `yaml:"name"`
+type matrixConfig struct {
+	Enable bool `yaml:"enable" default:"false"`
+	// Homeserver is the base URL of the client-server API, e.g.
+	// https://matrix.org
+	Homeserver  string `yaml:"homeserver"`
+	AccessToken string `yaml:"access_token" secret:"true"`
+	// Rooms are room IDs or aliases joined on startup, in addition to the
+	// rooms the account is already in
+	Rooms []string `yaml:"rooms"`
 }
 
-type discordConfig struct {
-	Token  string `yaml:"token"`
+type telegramConfig struct {
 	Enable bool   `yaml:"enable" default:"false"`
+	Token  string `yaml:"token" secret:"true"`
+	// APIURL is the Bot API server, for self-hosted servers
+	APIURL string `yaml:"api_url" default:"https://api.telegram.org"`
+	// Format is how responses are formatted: html or markdown (MarkdownV2)
+	Format string `yaml:"format" default:"html" validate:"oneof=html markdown"`
+	// Webhook receives updates on the HTTP endpoint at
+	// https://<domain>/telegram instead of polling getUpdates
+	Webhook       bool   `yaml:"webhook" default:"false"`
+	WebhookSecret string `yaml:"webhook_secret" secret:"true"`
+}
+
+type slackConfig struct {
+	Enable bool `yaml:"enable" default:"false"`
+	// AppToken is the app-level token (xapp-) Socket Mode connects with
+	AppToken string `yaml:"app_token" secret:"true"`
+	// BotToken is the bot token (xoxb-) the Web API is called with
+	BotToken string `yaml:"bot_token" secret:"true"`
 }
 
 type httpConfig struct {
-	Port   int  `yaml:"port" default:"8080"`
+	Port   int  `yaml:"port" default:"8080" validate:"min=1,max=65535"`
 	Enable bool `yaml:"enable" default:"false"`
+	// DebugAddr is the address metrics are served on, apart from the
+	// public endpoint so they are not exposed with it, or "" to not serve
+	// them
+	DebugAddr string `yaml:"debug_addr" default:"localhost:6060"`
+}
+
+type permissionsConfig struct {
+	Admins     PermissionGroup `yaml:"admins"`
+	Moderators PermissionGroup `yaml:"moderators"`
+}
+
+// PermissionGroup lists who holds a permission. Both maps are keyed by
+// platform name. Users holds platform user IDs; Roles holds discord role IDs
+// and mumble ACL group names.
+type PermissionGroup struct {
+	Users map[string][]string `yaml:"users"`
+	Roles map[string][]string `yaml:"roles"`
+}
+
+type rateLimitConfig struct {
+	// Silent drops rate limited commands without telling the user
+	Silent bool `yaml:"silent" default:"false"`
+	// Rate is how many commands per second the bot runs across all users
+	// and platforms, 0 for no limit
+	Rate      float64             `yaml:"rate" default:"5" validate:"min=0"`
+	Burst     int                 `yaml:"burst" default:"10" validate:"min=0"`
+	Cooldowns map[string]Cooldown `yaml:"cooldowns"`
+}
+
+// Cooldown is how long a command is unavailable after being run, tracked
+// separately for everyone, per user and per channel.
+type Cooldown struct {
+	Command time.Duration `yaml:"command"`
+	User    time.Duration `yaml:"user"`
+	Channel time.Duration `yaml:"channel"`
+}
+
+type timeoutsConfig struct {
+	// Default applies to commands without a timeout of their own
+	Default time.Duration `yaml:"default" default:"10s" validate:"min=0"`
+	// Commands maps command paths such as "karting graph" to their timeout
+	Commands map[string]time.Duration `yaml:"commands"`
+}
+
+type kekConfig struct {
+	// Emojis are the reactions that credit the author of a message, as the
+	// platforms name them, e.g. "😂" on discord or "joy" on slack
+	Emojis []string `yaml:"emojis,omitempty"`
+	// Keywords credit the author of the previous message in the channel
+	// when a message contains one of them
+	Keywords []string `yaml:"keywords,omitempty"`
+}
+
+type bridgeConfig struct {
+	// Links are the channels mirrored into each other, each mapping
+	// platform names to a channel ID on that platform
+	Links []map[string]string `yaml:"links"`
+	// Responses relays commands and the responses of the bot to them,
+	// which otherwise stay on the side they were sent on
+	Responses bool `yaml:"responses" default:"false"`
 }
 
 type mumbleConfig struct {
 	Enable   bool   `yaml:"enable" default:"false"`
 	Host     string `yaml:"host"`
-	Port     int    `yaml:"port" default:"64738"`
+	Port     int    `yaml:"port" default:"64738" validate:"min=1,max=65535"`
 	TLS      bool   `yaml:"tls" default:"false"`
 	Username string `yaml:"username"`
 }
 
-var config *configuration
+type ircConfig struct {
+	Enable bool   `yaml:"enable" default:"false"`
+	Host   string `yaml:"host"`
+	// Port defaults to 6697 with TLS and 6667 without
+	Port          int    `yaml:"port" validate:"min=1,max=65535"`
+	TLS           bool   `yaml:"tls" default:"true"`
+	TLSSkipVerify bool   `yaml:"tls_skip_verify" default:"false"`
+	Nick          string `yaml:"nick" default:"gerry"`
+	// Auth is how the bot identifies itself: none, nickserv or sasl
+	Auth string `yaml:"auth" default:"none" validate:"oneof=none nickserv sasl"`
+	// Account is the services account to log in to, the nick if empty
+	Account  string   `yaml:"account"`
+	Password string   `yaml:"password" secret:"true"`
+	Channels []string `yaml:"channels"`
+}
+
+// Default returns the config with every field set from its default tag.
+func Default() *Config {
+	config := &Config{}
+	if err := applyDefaults(config); err != nil {
+		// the tags are part of the source, so this is a bug
+		panic(err)
+	}
 
-func Load(path string) error {
-	file, err := os.Open(path)
-	if err != nil {
+	return config
+}
+
+// Load reads the config file at path, generating a default one if there is
+// none. Invalid config is an error, with every invalid field listed.
+func Load(path string) (*Config, error) {
+	config, err := Read(path)
+	if errors.Is(err, fs.ErrNotExist) {
 		log.Error().Err(err).Msg("failed to open config file, creating empty file")
 		Generate(path)
-		return err
+		return nil, err
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to load config file")
+		return nil, err
+	}
+
+	log.Info().Str("file", path).Msg("config file loaded successfully")
+	return config, nil
+}
+
+// Read reads the config file at path like Parse.
+func Read(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
 	}
 	defer file.Close()
 
-	config = &defaultConfig
-	if err := yaml.NewDecoder(file).Decode(config); err != nil {
-		log.Error().Err(err).Msg("failed to decode config file")
+	return Parse(file)
+}
+
+// Parse decodes a yaml config from r over the defaults, applies the
+// environment variable overrides and validates the result. Values set in
+// the environment take precedence over the file, which takes precedence
+// over the defaults, see EnvVar. Fields the config does not have are an
+// error, so typos do not go unnoticed.
+func Parse(r io.Reader) (*Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	config := Default()
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("decoding config: %w", err)
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err == nil {
+		config.fileSources(&document, "")
+	}
+
+	if err := applyEnv(config, os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Validate checks the config against the validate tags of its fields. The
+// error is a ValidationError naming every invalid field by its yaml path.
+func (c *Config) Validate() error {
+	var errs ValidationError
+	if err := validate(c); err != nil && !errors.As(err, &errs) {
 		return err
 	}
 
-	log.Info().Str("file", path).Msg("config file loaded successfully")
+	if within(c.GetStateDir(), c.GetDataDir()) {
+		errs = append(errs, FieldError{Path: "state_dir", Message: "must not be inside data_dir, which is served over HTTP"})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
 }
 
+// within reports whether dir is base or a directory inside it.
+func within(dir, base string) bool {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	base, err = filepath.Abs(base)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(base, dir)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
 func Generate(filepath string) {
 	file, err := os.Create(filepath)
 	if err != nil {
@@ -76,7 +304,7 @@ func Generate(filepath string) {
 	defer file.Close()
 
 	log.Info().Str("file", filepath).Msg("writing default config to file")
-	if err := yaml.NewEncoder(file).Encode(defaultConfig); err != nil {
+	if err := yaml.NewEncoder(file).Encode(Default()); err != nil {
 		log.Error().Err(err).Msg("failed to encode config file")
 		return
 	}
@@ -84,17 +312,17 @@ func Generate(filepath string) {
 	log.Info().Msg("config file generated successfully")
 }
 
-func GetEnvironment() string {
-	return config.Environment
+func (c *Config) GetEnvironment() string {
+	return c.Environment
 }
 
-func IsEnvironment(environments ...string) bool {
+func (c *Config) IsEnvironment(environments ...string) bool {
 	if len(environments) == 0 {
-		return config.Environment == environments[0]
+		return c.Environment == environments[0]
 	}
 
 	for _, environment := range environments {
-		if config.Environment == environment {
+		if c.Environment == environment {
 			return true
 		}
 	}
@@ -102,54 +330,326 @@ func IsEnvironment(environments ...string) bool {
 	return false
 }
 
-func GetDomain() string {
-	return config.Domain
+func (c *Config) GetDomain() string {
+	return c.Domain
 }
 
-func GetBotPrefix() string {
-	return config.Prefix
+func (c *Config) GetBotPrefix() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Prefix
 }
 
-func GetBotStatus() string {
-	return config.Status
+func (c *Config) GetBotStatus() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Status
+}
+
+func (c *Config) GetBotName() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Name
+}
+
+func (c *Config) IsDiscordEnabled() bool {
+	return c.Discord.Enable
 }
 
-func GetBotName() string {
-	return config.Name
+func (c *Config) GetDiscordToken() string {
+	return c.Discord.Token
 }
 
-func IsDiscordEnabled() bool {
-	return config.Discord.Enable
+// GetDiscordCommands returns where slash commands are registered: global,
+// guild or off.
+func (c *Config) GetDiscordCommands() string {
+	if c.Discord.Commands == "" {
+		return "global"
+	}
+
+	return c.Discord.Commands
+}
+
+func (c *Config) GetDiscordGuilds() []string {
+	return c.Discord.Guilds
 }
 
-func GetDiscordToken() string {
-	return config.Discord.Token
+func (c *Config) IsMatrixEnabled() bool {
+	return c.Matrix.Enable
 }
 
-func IsMumbleEnabled() bool {
-	return config.Mumble.Enable
+func (c *Config) GetMatrixHomeserver() string {
+	return c.Matrix.Homeserver
 }
 
-func GetMumbleHost() string {
-	return config.Mumble.Host
+func (c *Config) GetMatrixAccessToken() string {
+	return c.Matrix.AccessToken
 }
 
-func GetMumblePort() int {
-	return config.Mumble.Port
+func (c *Config) GetMatrixRooms() []string {
+	return c.Matrix.Rooms
 }
 
-func GetMumbleUsername() string {
-	return config.Mumble.Username
+func (c *Config) IsTelegramEnabled() bool {
+	return c.Telegram.Enable
 }
 
-func GetMumbleTLS() bool {
-	return config.Mumble.TLS
+func (c *Config) GetTelegramToken() string {
+	return c.Telegram.Token
 }
 
-func IsHTTPEndpointEnabled() bool {
-	return config.HTTP.Enable
+func (c *Config) GetTelegramAPIURL() string {
+	if c.Telegram.APIURL == "" {
+		return "https://api.telegram.org"
+	}
+
+	return c.Telegram.APIURL
 }
 
-func GetHTTPPort() int {
-	return config.HTTP.Port
+// GetTelegramFormat returns how responses are formatted on telegram: html
+// or markdown.
+func (c *Config) GetTelegramFormat() string {
+	if c.Telegram.Format == "" {
+		return "html"
+	}
+
+	return c.Telegram.Format
+}
+
+func (c *Config) IsTelegramWebhook() bool {
+	return c.Telegram.Webhook
+}
+
+func (c *Config) GetTelegramWebhookSecret() string {
+	return c.Telegram.WebhookSecret
+}
+
+func (c *Config) IsSlackEnabled() bool {
+	return c.Slack.Enable
+}
+
+func (c *Config) GetSlackAppToken() string {
+	return c.Slack.AppToken
+}
+
+func (c *Config) GetSlackBotToken() string {
+	return c.Slack.BotToken
+}
+
+func (c *Config) IsMumbleEnabled() bool {
+	return c.Mumble.Enable
+}
+
+func (c *Config) GetMumbleHost() string {
+	return c.Mumble.Host
+}
+
+func (c *Config) GetMumblePort() int {
+	return c.Mumble.Port
+}
+
+func (c *Config) GetMumbleUsername() string {
+	return c.Mumble.Username
+}
+
+func (c *Config) GetMumbleTLS() bool {
+	return c.Mumble.TLS
+}
+
+func (c *Config) IsIRCEnabled() bool {
+	return c.IRC.Enable
+}
+
+func (c *Config) GetIRCHost() string {
+	return c.IRC.Host
+}
+
+func (c *Config) GetIRCPort() int {
+	if c.IRC.Port != 0 {
+		return c.IRC.Port
+	}
+
+	if c.IRC.TLS {
+		return 6697
+	}
+
+	return 6667
+}
+
+func (c *Config) GetIRCTLS() bool {
+	return c.IRC.TLS
+}
+
+func (c *Config) GetIRCTLSSkipVerify() bool {
+	return c.IRC.TLSSkipVerify
+}
+
+func (c *Config) GetIRCNick() string {
+	if c.IRC.Nick == "" {
+		return "gerry"
+	}
+
+	return c.IRC.Nick
+}
+
+// GetIRCAuth returns how the bot identifies itself on IRC: none, nickserv
+// or sasl.
+func (c *Config) GetIRCAuth() string {
+	if c.IRC.Auth == "" {
+		return "none"
+	}
+
+	return c.IRC.Auth
+}
+
+func (c *Config) GetIRCAccount() string {
+	if c.IRC.Account == "" {
+		return c.GetIRCNick()
+	}
+
+	return c.IRC.Account
+}
+
+func (c *Config) GetIRCPassword() string {
+	return c.IRC.Password
+}
+
+func (c *Config) GetIRCChannels() []string {
+	return c.IRC.Channels
+}
+
+func (c *Config) IsHTTPEndpointEnabled() bool {
+	return c.HTTP.Enable
+}
+
+func (c *Config) GetHTTPPort() int {
+	return c.HTTP.Port
+}
+
+func (c *Config) GetHTTPDebugAddr() string {
+	return c.HTTP.DebugAddr
+}
+
+func (c *Config) GetAdmins() PermissionGroup {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Permissions.Admins
+}
+
+func (c *Config) GetModerators() PermissionGroup {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Permissions.Moderators
+}
+
+func (c *Config) IsRateLimitSilent() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.RateLimit.Silent
+}
+
+func (c *Config) GetRateLimit() (rate float64, burst int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.RateLimit.Rate, c.RateLimit.Burst
+}
+
+// GetCooldown returns the cooldown configured for a command path such as
+// "karting graph", if any.
+func (c *Config) GetCooldown(command string) (Cooldown, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cooldown, ok := c.RateLimit.Cooldowns[command]
+	return cooldown, ok
+}
+
+// GetCommandTimeout returns the timeout configured for a command path, if
+// any.
+func (c *Config) GetCommandTimeout(command string) (time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	timeout, ok := c.Timeouts.Commands[command]
+	return timeout, ok
+}
+
+func (c *Config) GetDefaultCommandTimeout() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.Timeouts.Default <= 0 {
+		return 10 * time.Second
+	}
+
+	return c.Timeouts.Default
+}
+
+// GetKekEmojis returns the reactions counted by the kek counter. An empty
+// list turns reactions off, leaving them unset uses the defaults.
+func (c *Config) GetKekEmojis() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.Kek.Emojis == nil {
+		return []string{"😂", "🤣", "😆", "joy", "rofl", "laughing", "kek"}
+	}
+
+	return c.Kek.Emojis
+}
+
+// GetKekKeywords returns the words counted by the kek counter, like
+// GetKekEmojis.
+func (c *Config) GetKekKeywords() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.Kek.Keywords == nil {
+		return []string{"kek", "lol"}
+	}
+
+	return c.Kek.Keywords
+}
+
+// GetBridgeLinks returns the linked channels of the bridge, each mapping
+// platform names to a channel ID.
+func (c *Config) GetBridgeLinks() []map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Bridge.Links
+}
+
+func (c *Config) IsBridgeResponsesEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Bridge.Responses
+}
+
+// GetDataDir returns the directory feature state and generated assets are
+// stored in.
+func (c *Config) GetDataDir() string {
+	if c.DataDir == "" {
+		return "assets"
+	}
+
+	return c.DataDir
+}
+
+// GetStateDir returns the directory private state, such as linked accounts,
+// is stored in. Unlike the data directory it is not served over HTTP.
+func (c *Config) GetStateDir() string {
+	if c.StateDir == "" {
+		return "state"
+	}
+
+	return c.StateDir
 }
@@ -0,0 +1,270 @@
+package config_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/config"
+)
+
+func TestDefault(t *testing.T) {
+	cfg := config.Default()
+
+	if cfg.Prefix != ">" || cfg.Environment != config.APP_ENVIRONMENT_LOCAL || cfg.DataDir != "assets" {
+		t.Errorf("top level defaults not applied: %+v", cfg)
+	}
+	if cfg.HTTP.Port != 8080 || !cfg.IRC.TLS || cfg.IRC.Nick != "gerry" || cfg.Timeouts.Default != 10*time.Second || cfg.RateLimit.Rate != 5 {
+		t.Errorf("nested defaults not applied: %+v", cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("default config is invalid: %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		yaml  string
+		check func(t *testing.T, cfg *config.Config)
+		err   string
+	}{
+		{
+			name: "empty",
+			yaml: "",
+			check: func(t *testing.T, cfg *config.Config) {
+				if cfg.Prefix != ">" || cfg.GetMumblePort() != 64738 {
+					t.Errorf("defaults not applied: %+v", cfg)
+				}
+			},
+		},
+		{
+			name: "set values win over defaults",
+			yaml: "prefix: \"!\"\nirc:\n  tls: false\nratelimit:\n  rate: 0\n",
+			check: func(t *testing.T, cfg *config.Config) {
+				if cfg.Prefix != "!" || cfg.IRC.TLS || cfg.GetIRCPort() != 6667 || cfg.RateLimit.Rate != 0 {
+					t.Errorf("set values were overridden: %+v", cfg)
+				}
+				if cfg.HTTP.Port != 8080 {
+					t.Errorf("default next to a set value not applied: %+v", cfg.HTTP)
+				}
+			},
+		},
+		{
+			name: "unknown field",
+			yaml: "prefx: \"!\"\n",
+			err:  "line 1: field prefx not found",
+		},
+		{
+			name: "invalid values",
+			yaml: "environment: PRD\nirc:\n  auth: password\n  port: 70000\ntimeouts:\n  default: -1s\n",
+			err: "invalid config:\n" +
+				"  irc.port: must be at most 65535, got 70000\n" +
+				"  irc.auth: must be one of none, nickserv, sasl, got \"password\"\n" +
+				"  timeouts.default: must be at least 0, got -1s\n" +
+				"  environment: must be one of LOCAL, TEST, PROD, got \"PRD\"",
+		},
+		{
+			name: "required",
+			yaml: "environment: \"\"\n",
+			err:  "invalid config:\n  environment: is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.Parse(strings.NewReader(tt.yaml))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestValidationErrorFields(t *testing.T) {
+	_, err := config.Parse(strings.NewReader("telegram:\n  format: bbcode\n"))
+
+	var invalid config.ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("got %v, want a ValidationError", err)
+	}
+	if len(invalid) != 1 || invalid[0].Path != "telegram.format" {
+		t.Errorf("got fields %+v, want telegram.format", invalid)
+	}
+}
+
+func TestStateDirInsideDataDir(t *testing.T) {
+	for _, doc := range []string{
+		"data_dir: data\nstate_dir: data\n",
+		"data_dir: data\nstate_dir: data/state\n",
+		"data_dir: ./data/\nstate_dir: data/../data/state\n",
+	} {
+		_, err := config.Parse(strings.NewReader(doc))
+
+		var invalid config.ValidationError
+		if !errors.As(err, &invalid) || len(invalid) != 1 || invalid[0].Path != "state_dir" {
+			t.Errorf("%q got %v, want a state_dir error", doc, err)
+		}
+	}
+
+	if _, err := config.Parse(strings.NewReader("data_dir: data\nstate_dir: data-state\n")); err != nil {
+		t.Errorf("state dir next to the data dir got %v", err)
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "discord_token")
+	if err := os.WriteFile(secret, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("GERRY_PREFIX", "?")
+	t.Setenv("GERRY_DISCORD_TOKEN_FILE", secret)
+	t.Setenv("GERRY_IRC_TLS", "false")
+	t.Setenv("GERRY_IRC_CHANNELS", "#gerry, #karting")
+	t.Setenv("GERRY_TIMEOUTS_DEFAULT", "30s")
+	t.Setenv("GERRY_PERMISSIONS_ADMINS_USERS", `{discord: ["123"]}`)
+	t.Setenv("GERRY_SLACK_BOT_TOKEN", "xoxb-secret")
+
+	cfg, err := config.Parse(strings.NewReader("prefix: \"!\"\nirc:\n  nick: bob\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Prefix != "?" || cfg.GetDiscordToken() != "from-file" || cfg.IRC.TLS || cfg.GetDefaultCommandTimeout() != 30*time.Second {
+		t.Errorf("env not applied: %+v", cfg)
+	}
+	if got := cfg.GetIRCChannels(); len(got) != 2 || got[0] != "#gerry" || got[1] != "#karting" {
+		t.Errorf("got channels %q", got)
+	}
+	if got := cfg.GetAdmins().Users["discord"]; len(got) != 1 || got[0] != "123" {
+		t.Errorf("got admins %q", got)
+	}
+
+	sources := make(map[string]config.ValueSource)
+	for _, source := range cfg.Sources() {
+		sources[source.Path] = source
+	}
+	want := map[string]config.ValueSource{
+		"prefix":          {Path: "prefix", Source: config.SourceEnv, Var: "GERRY_PREFIX", Value: "?"},
+		"discord.token":   {Path: "discord.token", Source: config.SourceSecretFile, Var: "GERRY_DISCORD_TOKEN_FILE", Value: "[redacted]"},
+		"slack.bot_token": {Path: "slack.bot_token", Source: config.SourceEnv, Var: "GERRY_SLACK_BOT_TOKEN", Value: "[redacted]"},
+		"irc.nick":        {Path: "irc.nick", Source: config.SourceFile, Value: "bob"},
+		"http.port":       {Path: "http.port", Source: config.SourceDefault, Value: "8080"},
+	}
+	for path, source := range want {
+		if sources[path] != source {
+			t.Errorf("%s got source %+v, want %+v", path, sources[path], source)
+		}
+	}
+}
+
+func TestEnvOverrideErrors(t *testing.T) {
+	t.Setenv("GERRY_DISCORD_TOKEN", "abc")
+	t.Setenv("GERRY_DISCORD_TOKEN_FILE", "/run/secrets/discord_token")
+	t.Setenv("GERRY_HTTP_PORT", "eighty")
+	t.Setenv("GERRY_MATRIX_ACCESS_TOKEN_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	_, err := config.Parse(strings.NewReader(""))
+
+	var invalid config.ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("got %v, want a ValidationError", err)
+	}
+	var paths []string
+	for _, field := range invalid {
+		paths = append(paths, field.Path)
+	}
+	if got := strings.Join(paths, " "); got != "discord.token matrix.access_token http.port" {
+		t.Errorf("got errors for %s:\n%v", got, err)
+	}
+}
+
+func TestEnvVar(t *testing.T) {
+	for path, want := range map[string]string{
+		"discord.token":       "GERRY_DISCORD_TOKEN",
+		"irc.tls_skip_verify": "GERRY_IRC_TLS_SKIP_VERIFY",
+		"data_dir":            "GERRY_DATA_DIR",
+	} {
+		if got := config.EnvVar(path); got != want {
+			t.Errorf("EnvVar(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	cfg, err := config.Parse(strings.NewReader("prefix: \">\"\ndiscord:\n  token: old\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := config.Parse(strings.NewReader("prefix: \"!\"\ndiscord:\n  token: new\nkek:\n  emojis: [\"💀\"]\npermissions:\n  admins:\n    users:\n      discord: [\"123\"]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := cfg.Reload(next)
+
+	want := []config.Change{
+		{Path: "discord.token", Restart: true},
+		{Path: "permissions.admins.users"},
+		{Path: "kek.emojis"},
+		{Path: "prefix"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("got changes %+v, want %+v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d is %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+
+	if cfg.GetBotPrefix() != "!" || cfg.GetKekEmojis()[0] != "💀" || len(cfg.GetAdmins().Users["discord"]) != 1 {
+		t.Errorf("live changes not applied: %+v", cfg)
+	}
+	if cfg.GetDiscordToken() != "old" {
+		t.Errorf("token changed to %q without a restart", cfg.GetDiscordToken())
+	}
+
+	if changes := cfg.Reload(next); len(changes) != 1 || !changes[0].Restart {
+		t.Errorf("reloading the same config again got %+v", changes)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("prefix: \">\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := config.Watch(ctx, path, time.Millisecond)
+
+	select {
+	case <-changed:
+		t.Fatal("changed before the file was written")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(path, []byte("prefix: \"!\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change was not noticed")
+	}
+}
@@ -0,0 +1,237 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the environment variables that override config values.
+// The rest of the name is the yaml path in upper case with dots replaced
+// by underscores, e.g. GERRY_DISCORD_TOKEN for discord.token.
+const EnvPrefix = "GERRY_"
+
+// secretFileSuffix marks a variable holding the path of a file to read the
+// value from, such as a docker secret, e.g. GERRY_DISCORD_TOKEN_FILE
+const secretFileSuffix = "_FILE"
+
+// Source is where a config value was set.
+type Source string
+
+const (
+	SourceDefault    Source = "default"
+	SourceFile       Source = "config file"
+	SourceEnv        Source = "env"
+	SourceSecretFile Source = "secret file"
+)
+
+// ValueSource is where one config value was set. Var is the environment
+// variable it was read from, if any.
+type ValueSource struct {
+	Path   string
+	Source Source
+	Var    string
+	// Value is the value as text, redacted for secrets
+	Value string
+}
+
+// EnvVar returns the environment variable that overrides the value at
+// path, e.g. GERRY_IRC_TLS_SKIP_VERIFY for irc.tls_skip_verify.
+func EnvVar(path string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// applyEnv overrides the values of c from the environment. Each value can
+// be given in its variable, or in a file named by the variable with _FILE
+// appended, but not both. Lists take comma separated values or a yaml
+// list, maps take yaml.
+func applyEnv(c *Config, lookup func(string) (string, bool)) error {
+	var errs ValidationError
+	err := walkFields(reflect.ValueOf(c).Elem(), "", func(field reflect.Value, info reflect.StructField, path string) error {
+		if isSection(field) {
+			return nil
+		}
+
+		name := EnvVar(path)
+		value, inEnv := lookup(name)
+		file, inFile := lookup(name + secretFileSuffix)
+
+		source := SourceEnv
+		switch {
+		case inEnv && inFile:
+			errs = append(errs, FieldError{Path: path, Message: fmt.Sprintf("both %s and %s%s are set", name, name, secretFileSuffix)})
+			return nil
+		case inFile:
+			data, err := os.ReadFile(file)
+			if err != nil {
+				errs = append(errs, FieldError{Path: path, Message: fmt.Sprintf("reading %s%s: %v", name, secretFileSuffix, err)})
+				return nil
+			}
+			value = strings.TrimRight(string(data), "\r\n")
+			name += secretFileSuffix
+			source = SourceSecretFile
+		case !inEnv:
+			return nil
+		}
+
+		if err := setEnvValue(field, value); err != nil {
+			errs = append(errs, FieldError{Path: path, Message: fmt.Sprintf("invalid value in %s: %v", name, err)})
+			return nil
+		}
+		c.setSource(path, source, name)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// setEnvValue parses an environment variable into field.
+func setEnvValue(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.Slice:
+		if !strings.HasPrefix(strings.TrimSpace(value), "[") {
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			value = "[" + strings.Join(quoteAll(items), ", ") + "]"
+		}
+		fallthrough
+	case reflect.Map:
+		target := reflect.New(field.Type())
+		if err := yaml.Unmarshal([]byte(value), target.Interface()); err != nil {
+			return err
+		}
+		field.Set(target.Elem())
+		return nil
+	}
+
+	return setValue(field, value)
+}
+
+func quoteAll(items []string) []string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = fmt.Sprintf("%q", item)
+	}
+
+	return quoted
+}
+
+// fileSources records the values set in the yaml document node, a mapping
+// of yaml names, under prefix.
+func (c *Config) fileSources(node *yaml.Node, prefix string) {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		path := node.Content[i].Value
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		c.setSource(path, SourceFile, "")
+		if c.isStruct(path) {
+			c.fileSources(node.Content[i+1], path)
+		}
+	}
+}
+
+// isStruct reports whether the value at path is a section of the config
+// rather than a value.
+func (c *Config) isStruct(path string) bool {
+	found := false
+	_ = walkFields(reflect.ValueOf(c).Elem(), "", func(field reflect.Value, info reflect.StructField, p string) error {
+		if p == path {
+			found = isSection(field)
+		}
+		return nil
+	})
+
+	return found
+}
+
+func (c *Config) setSource(path string, source Source, variable string) {
+	if c.sources == nil {
+		c.sources = make(map[string]ValueSource)
+	}
+
+	c.sources[path] = ValueSource{Path: path, Source: source, Var: variable}
+}
+
+// Sources returns where each value of the config was set, sections left
+// out, sorted by path. Values of fields tagged secret are redacted.
+func (c *Config) Sources() []ValueSource {
+	var sources []ValueSource
+	_ = walkFields(reflect.ValueOf(c).Elem(), "", func(field reflect.Value, info reflect.StructField, path string) error {
+		if isSection(field) {
+			return nil
+		}
+
+		source, ok := c.sources[path]
+		if !ok {
+			source = ValueSource{Path: path, Source: SourceDefault}
+		}
+
+		source.Value = fmt.Sprint(field.Interface())
+		if info.Tag.Get("secret") == "true" || source.Source == SourceSecretFile {
+			source.Value = redact(field)
+		}
+
+		sources = append(sources, source)
+		return nil
+	})
+
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Path < sources[j].Path
+	})
+
+	return sources
+}
+
+// redact hides a secret value, only telling whether it is set.
+func redact(field reflect.Value) string {
+	if field.IsZero() {
+		return ""
+	}
+
+	return "[redacted]"
+}
+
+// LogSources logs where every value was set. Defaults are only logged at
+// debug level, since they are most of the config.
+func (c *Config) LogSources() {
+	for _, source := range c.Sources() {
+		event := log.Info()
+		if source.Source == SourceDefault {
+			event = log.Debug()
+		}
+		if source.Var != "" {
+			event = event.Str("var", source.Var)
+		}
+
+		event.
+			Str("field", source.Path).
+			Str("source", string(source.Source)).
+			Str("value", source.Value).
+			Msg("config value")
+	}
+}
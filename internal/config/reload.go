@@ -0,0 +1,67 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Change is a config value that differs after a reload.
+type Change struct {
+	Path string
+	// Restart is set for values that only take effect once the bot is
+	// restarted, which Reload leaves as they were
+	Restart bool
+}
+
+// Reload applies the values of next to c, except for the ones tagged, or
+// in a section tagged, reload:"restart". Those keep their old value and
+// are reported as needing a restart. Every value that differs is returned,
+// in the order of the config.
+func (c *Config) Reload(next *Config) []Change {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := make(map[string]reflect.Value)
+	_ = walkFields(reflect.ValueOf(c).Elem(), "", func(field reflect.Value, info reflect.StructField, path string) error {
+		current[path] = field
+		return nil
+	})
+
+	var changes []Change
+	restart := make(map[string]bool)
+	_ = walkFields(reflect.ValueOf(next).Elem(), "", func(field reflect.Value, info reflect.StructField, path string) error {
+		parent := path[:max(strings.LastIndex(path, "."), 0)]
+		if info.Tag.Get("reload") == "restart" || restart[parent] {
+			restart[path] = true
+		}
+
+		old := current[path]
+		if isSection(field) || sameValue(old, field) {
+			return nil
+		}
+
+		if !restart[path] {
+			old.Set(field)
+		}
+		changes = append(changes, Change{Path: path, Restart: restart[path]})
+
+		return nil
+	})
+
+	c.sources = next.sources
+
+	return changes
+}
+
+// sameValue reports whether a and b hold the same value, counting empty
+// and missing lists or maps as the same.
+func sameValue(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Slice, reflect.Map:
+		if a.Len() == 0 && b.Len() == 0 {
+			return true
+		}
+	}
+
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
@@ -0,0 +1,226 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError is a config value that failed validation. Path is the yaml
+// path of the value, e.g. irc.auth.
+type FieldError struct {
+	Path    string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationError lists every invalid value of a config.
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	lines := make([]string, len(e))
+	for i, field := range e {
+		lines[i] = "  " + field.Error()
+	}
+
+	return "invalid config:\n" + strings.Join(lines, "\n")
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyDefaults sets the fields of the struct v points to from their
+// default tags. Nested structs are filled in too.
+func applyDefaults(v any) error {
+	return walkFields(reflect.ValueOf(v).Elem(), "", func(field reflect.Value, info reflect.StructField, path string) error {
+		value, ok := info.Tag.Lookup("default")
+		if !ok {
+			return nil
+		}
+
+		if err := setValue(field, value); err != nil {
+			return fmt.Errorf("config: default of %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// validate checks the fields of the struct v points to against their
+// validate tags, a comma separated list of:
+//
+//	required     the value is not empty
+//	oneof=a b c  the value is one of the words listed
+//	min=n        the number is at least n
+//	max=n        the number is at most n
+//
+// Empty values only fail required, so optional fields can be left out.
+func validate(v any) error {
+	var errs ValidationError
+	err := walkFields(reflect.ValueOf(v).Elem(), "", func(field reflect.Value, info reflect.StructField, path string) error {
+		rules, ok := info.Tag.Lookup("validate")
+		if !ok {
+			return nil
+		}
+
+		for _, rule := range strings.Split(rules, ",") {
+			name, arg, _ := strings.Cut(rule, "=")
+
+			if name != "required" && field.IsZero() {
+				continue
+			}
+
+			message, err := checkRule(field, name, arg)
+			if err != nil {
+				return fmt.Errorf("config: rule %q of %s: %w", rule, path, err)
+			}
+			if message != "" {
+				errs = append(errs, FieldError{Path: path, Message: message})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkRule returns why field breaks a validate rule, or "" if it does not.
+func checkRule(field reflect.Value, name, arg string) (string, error) {
+	switch name {
+	case "required":
+		if field.IsZero() {
+			return "is required", nil
+		}
+
+	case "oneof":
+		choices := strings.Fields(arg)
+		value := fmt.Sprint(field.Interface())
+		for _, choice := range choices {
+			if value == choice {
+				return "", nil
+			}
+		}
+		return fmt.Sprintf("must be one of %s, got %q", strings.Join(choices, ", "), value), nil
+
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return "", err
+		}
+
+		var value float64
+		switch field.Kind() {
+		case reflect.Int, reflect.Int64:
+			value = float64(field.Int())
+		case reflect.Float64:
+			value = field.Float()
+		default:
+			return "", fmt.Errorf("%s is not a number", field.Type())
+		}
+
+		if name == "min" && value < limit {
+			return fmt.Sprintf("must be at least %s, got %v", arg, field.Interface()), nil
+		}
+		if name == "max" && value > limit {
+			return fmt.Sprintf("must be at most %s, got %v", arg, field.Interface()), nil
+		}
+
+	default:
+		return "", fmt.Errorf("unknown rule")
+	}
+
+	return "", nil
+}
+
+// walkFields calls fn with every field of the struct v and of the structs
+// nested in it, along with its yaml path under prefix.
+func walkFields(v reflect.Value, prefix string, fn func(field reflect.Value, info reflect.StructField, path string) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		info := t.Field(i)
+		if !info.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(info.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(info.Name)
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		field := v.Field(i)
+		if err := fn(field, info, path); err != nil {
+			return err
+		}
+
+		if isSection(field) {
+			if err := walkFields(field, path, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// isSection reports whether field is a section of the config, holding
+// other values, rather than a value.
+func isSection(field reflect.Value) bool {
+	return field.Kind() == reflect.Struct && field.Type() != durationType
+}
+
+// setValue parses value into field, which holds a string, bool, number or
+// duration.
+func setValue(field reflect.Value, value string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("cannot set a %s", field.Type())
+	}
+
+	return nil
+}
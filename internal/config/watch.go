@@ -0,0 +1,53 @@
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Watch polls the file at path every interval and sends on the returned
+// channel whenever its modification time or size changes, until ctx is
+// done. Changes made while the previous one was not received yet are
+// merged into it.
+func Watch(ctx context.Context, path string, interval time.Duration) <-chan struct{} {
+	changed := make(chan struct{}, 1)
+
+	last, err := os.Stat(path)
+	if err != nil {
+		log.Warn().Err(err).Str("file", path).Msg("failed to stat config file")
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				// editors may replace the file, it is looked at again on
+				// the next tick
+				continue
+			}
+			if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+				continue
+			}
+			last = info
+
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return changed
+}
@@ -0,0 +1,457 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/distrobyte/gerry/internal/commands"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// discord limits on slash commands
+const (
+	maxDescription = 100
+	maxChoices     = 25
+)
+
+// ownSubCommand is the sub-command that runs a command which has
+// sub-commands and a handler of its own, e.g. /kek show, as discord does
+// not allow both sub-commands and arguments on one command
+const ownSubCommand = "show"
+
+// commander is implemented by handlers that can describe and complete
+// their commands, which slash commands need. The bot implements it.
+type commander interface {
+	Registry() *commands.Registry
+	Complete(ctx context.Context, arg *commands.Arg, value string) []string
+}
+
+// interaction is a slash command or component being answered. Discord is
+// told the bot is thinking straight away, the response replaces that
+// message, or the message of the component when update is set.
+type interaction struct {
+	*discordgo.Interaction
+	update  bool
+	replied bool
+}
+
+// registerCommands registers the commands of the handler as slash
+// commands, replacing the ones registered before.
+func (d *Discord) registerCommands(s *discordgo.Session, appID string) {
+	c, ok := d.handler.(commander)
+	if !ok || d.config.GetDiscordCommands() == "off" {
+		return
+	}
+
+	cmds := applicationCommands(c.Registry().Commands())
+
+	guilds := []string{""}
+	if d.config.GetDiscordCommands() == "guild" {
+		guilds = d.config.GetDiscordGuilds()
+	}
+
+	for _, guildID := range guilds {
+		if _, err := s.ApplicationCommandBulkOverwrite(appID, guildID, cmds); err != nil {
+			log.Error().Err(err).Str("guild_id", guildID).Msg("failed to register slash commands")
+			continue
+		}
+
+		log.Info().Str("guild_id", guildID).Int("commands", len(cmds)).Msg("registered slash commands")
+	}
+}
+
+// applicationCommands describes commands as slash commands. Groups become
+// sub-commands and arguments become typed options. A group with a handler
+// of its own is run by its ownSubCommand.
+func applicationCommands(cmds []*commands.Command) []*discordgo.ApplicationCommand {
+	var acs []*discordgo.ApplicationCommand
+	for _, cmd := range cmds {
+		acs = append(acs, &discordgo.ApplicationCommand{
+			Type:        discordgo.ChatApplicationCommand,
+			Name:        cmd.Name,
+			Description: description(cmd.Summary, cmd.Name),
+			Options:     commandOptions(cmd),
+		})
+	}
+
+	return acs
+}
+
+func commandOptions(cmd *commands.Command) []*discordgo.ApplicationCommandOption {
+	if len(cmd.SubCommands) == 0 {
+		return argOptions(cmd.Args)
+	}
+
+	var options []*discordgo.ApplicationCommandOption
+	if cmd.Handler != nil && cmd.SubCommand(ownSubCommand) == nil {
+		options = append(options, &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        ownSubCommand,
+			Description: description(cmd.Summary, cmd.Name),
+			Options:     argOptions(cmd.Args),
+		})
+	}
+
+	for _, sub := range cmd.SubCommands {
+		option := &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        sub.Name,
+			Description: description(sub.Summary, sub.Name),
+			Options:     commandOptions(sub),
+		}
+		if len(sub.SubCommands) > 0 {
+			option.Type = discordgo.ApplicationCommandOptionSubCommandGroup
+		}
+		options = append(options, option)
+	}
+
+	return options
+}
+
+// argOptions describes arguments as options. Variadic arguments with a
+// maximum become numbered options, e.g. driver1 to driver12.
+func argOptions(args []commands.Arg) []*discordgo.ApplicationCommandOption {
+	var options []*discordgo.ApplicationCommandOption
+	for _, arg := range args {
+		if arg.Variadic && arg.Max > 0 {
+			for i := 1; i <= arg.Max; i++ {
+				options = append(options, argOption(arg, arg.Name+strconv.Itoa(i), arg.Required && i == 1))
+			}
+			continue
+		}
+
+		options = append(options, argOption(arg, arg.Name, arg.Required))
+	}
+
+	return options
+}
+
+func argOption(arg commands.Arg, name string, required bool) *discordgo.ApplicationCommandOption {
+	option := &discordgo.ApplicationCommandOption{
+		Type:        optionType(arg.Type),
+		Name:        name,
+		Description: description(arg.Description, arg.Name),
+		Required:    required,
+	}
+
+	for _, choice := range arg.Choices {
+		option.Choices = append(option.Choices, &discordgo.ApplicationCommandOptionChoice{Name: choice, Value: choice})
+	}
+
+	// discord does not allow both
+	option.Autocomplete = arg.Complete != nil && len(arg.Choices) == 0
+
+	return option
+}
+
+func optionType(t commands.ArgType) discordgo.ApplicationCommandOptionType {
+	switch t {
+	case commands.ArgInteger:
+		return discordgo.ApplicationCommandOptionInteger
+	case commands.ArgNumber:
+		return discordgo.ApplicationCommandOptionNumber
+	case commands.ArgBoolean:
+		return discordgo.ApplicationCommandOptionBoolean
+	case commands.ArgUser:
+		return discordgo.ApplicationCommandOptionUser
+	default:
+		return discordgo.ApplicationCommandOptionString
+	}
+}
+
+func description(text, fallback string) string {
+	if text == "" {
+		text = fallback
+	}
+
+	return truncate(text, maxDescription)
+}
+
+func (d *Discord) interactionCreateHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	c, ok := d.handler.(commander)
+	if !ok {
+		return
+	}
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		d.handleCommand(s, c.Registry(), i.Interaction)
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		d.handleAutocomplete(s, c, i.Interaction)
+	case discordgo.InteractionMessageComponent, discordgo.InteractionModalSubmit:
+		d.handleComponent(s, i.Interaction)
+	}
+}
+
+// handleCommand runs a slash command through the handler as if it was
+// typed as a prefixed message, and answers the interaction with the reply.
+func (d *Discord) handleCommand(s *discordgo.Session, registry *commands.Registry, i *discordgo.Interaction) {
+	cmd, options, ok := resolveInteraction(registry, i.ApplicationCommandData())
+	if !ok {
+		// commands removed since they were registered can still be run
+		// until discord catches up
+		d.respondText(s, i, discordgo.InteractionResponseChannelMessageWithSource, "unknown command")
+		return
+	}
+
+	d.run(s, i, interactionContent(d.config.GetBotPrefix(), cmd, options), false)
+}
+
+// run answers an interaction by running content through the handler as if
+// it was sent as a message. The response either replaces the message the
+// interaction came from, when update is set, or is sent as a new one.
+func (d *Discord) run(s *discordgo.Session, i *discordgo.Interaction, content string, update bool) {
+	// commands may take longer than the three seconds discord waits for
+	// an answer
+	deferred := discordgo.InteractionResponseDeferredChannelMessageWithSource
+	if update {
+		deferred = discordgo.InteractionResponseDeferredMessageUpdate
+	}
+
+	err := s.InteractionRespond(i, &discordgo.InteractionResponse{Type: deferred})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to acknowledge interaction")
+		return
+	}
+
+	user := i.User
+	var roles []string
+	if i.Member != nil {
+		user = i.Member.User
+		roles = i.Member.Roles
+	}
+
+	in := &interaction{Interaction: i, update: update}
+	d.interactionsMu.Lock()
+	d.interactions[i.ID] = in
+	d.interactionsMu.Unlock()
+
+	defer func() {
+		d.interactionsMu.Lock()
+		delete(d.interactions, i.ID)
+		replied := in.replied
+		d.interactionsMu.Unlock()
+
+		// nothing was sent, so drop the thinking message; updates leave
+		// the message as it was
+		if !replied && !update {
+			if err := s.InteractionResponseDelete(i); err != nil {
+				log.Warn().Err(err).Msg("failed to delete slash command response")
+			}
+		}
+	}()
+
+	d.handler.HandleMessage(d.ctx, d, &models.Message{
+		Content:     content,
+		Author:      user.Username,
+		AuthorID:    user.ID,
+		AuthorRoles: roles,
+		Channel:     i.ChannelID,
+		ID:          i.ID,
+		RecievedAt:  time.Now(),
+		Platform:    d.Name(),
+	})
+}
+
+// handleAutocomplete suggests values for the option being typed.
+func (d *Discord) handleAutocomplete(s *discordgo.Session, c commander, i *discordgo.Interaction) {
+	cmd, options, ok := resolveInteraction(c.Registry(), i.ApplicationCommandData())
+	if !ok {
+		return
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, option := range options {
+		if !option.Focused {
+			continue
+		}
+
+		arg := argFor(cmd, option.Name)
+		if arg == nil {
+			break
+		}
+
+		for _, value := range c.Complete(d.ctx, arg, option.StringValue()) {
+			if len(choices) == maxChoices {
+				break
+			}
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: value, Value: value})
+		}
+	}
+
+	err := s.InteractionRespond(i, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: choices},
+	})
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to send autocomplete choices")
+	}
+}
+
+// resolveInteraction walks the sub-commands of an interaction down the
+// command tree and returns the command run with the options given to it.
+func resolveInteraction(registry *commands.Registry, data discordgo.ApplicationCommandInteractionData) (*commands.Command, []*discordgo.ApplicationCommandInteractionDataOption, bool) {
+	cmd, ok := registry.Lookup(data.Name)
+	if !ok {
+		return nil, nil, false
+	}
+
+	options := data.Options
+	for len(options) == 1 && (options[0].Type == discordgo.ApplicationCommandOptionSubCommand ||
+		options[0].Type == discordgo.ApplicationCommandOptionSubCommandGroup) {
+		sub := cmd.SubCommand(options[0].Name)
+		if sub == nil && options[0].Name == ownSubCommand && cmd.Handler != nil {
+			return cmd, options[0].Options, true
+		}
+		if sub == nil {
+			return nil, nil, false
+		}
+		cmd, options = sub, options[0].Options
+	}
+
+	return cmd, options, true
+}
+
+// argFor returns the argument an option was generated from.
+func argFor(cmd *commands.Command, name string) *commands.Arg {
+	for i, arg := range cmd.Args {
+		if arg.Name == name {
+			return &cmd.Args[i]
+		}
+
+		if arg.Variadic && arg.Max > 0 {
+			if n, err := strconv.Atoi(strings.TrimPrefix(name, arg.Name)); err == nil && n >= 1 && n <= arg.Max {
+				return &cmd.Args[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+// interactionContent writes a slash command as the prefixed message it
+// stands for, e.g. ">karting race alice bob".
+func interactionContent(prefix string, cmd *commands.Command, options []*discordgo.ApplicationCommandInteractionDataOption) string {
+	values := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(options))
+	for _, option := range options {
+		values[option.Name] = option
+	}
+
+	words := []string{prefix + cmd.Path()}
+	for _, arg := range cmd.Args {
+		if arg.Variadic && arg.Max > 0 {
+			for i := 1; i <= arg.Max; i++ {
+				if option, ok := values[arg.Name+strconv.Itoa(i)]; ok {
+					words = append(words, commands.Quote(optionValue(option)))
+				}
+			}
+			continue
+		}
+
+		option, ok := values[arg.Name]
+		if !ok {
+			continue
+		}
+
+		// free text stands for several words, so it is not quoted
+		if arg.Variadic {
+			words = append(words, optionValue(option))
+			continue
+		}
+
+		words = append(words, commands.Quote(optionValue(option)))
+	}
+
+	return strings.Join(words, " ")
+}
+
+func optionValue(option *discordgo.ApplicationCommandInteractionDataOption) string {
+	switch option.Type {
+	case discordgo.ApplicationCommandOptionInteger:
+		return strconv.FormatInt(option.IntValue(), 10)
+	case discordgo.ApplicationCommandOptionNumber:
+		return strconv.FormatFloat(option.FloatValue(), 'f', -1, 64)
+	case discordgo.ApplicationCommandOptionBoolean:
+		return strconv.FormatBool(option.BoolValue())
+	case discordgo.ApplicationCommandOptionUser:
+		return fmt.Sprintf("<@%v>", option.Value)
+	default:
+		return option.StringValue()
+	}
+}
+
+// respond answers a slash command with a response, replacing the thinking
+// message. Ephemeral responses are sent as a follow-up only the author
+// sees.
+func (d *Discord) respond(in *interaction, response *models.Response) error {
+	message, files := messageSend(response)
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	message.Components = d.components(response)
+
+	d.interactionsMu.Lock()
+	replied := in.replied
+	in.replied = true
+	d.interactionsMu.Unlock()
+
+	var err error
+	switch {
+	case response.Ephemeral:
+		_, err = d.session.FollowupMessageCreate(in.Interaction, true, &discordgo.WebhookParams{
+			Content:    message.Content,
+			Embeds:     message.Embeds,
+			Files:      message.Files,
+			Components: message.Components,
+			Flags:      discordgo.MessageFlagsEphemeral,
+		})
+		if err == nil && !replied && !in.update {
+			// the thinking message is public and would otherwise stay
+			err = d.session.InteractionResponseDelete(in.Interaction)
+		}
+	case replied:
+		_, err = d.session.FollowupMessageCreate(in.Interaction, true, &discordgo.WebhookParams{
+			Content:    message.Content,
+			Embeds:     message.Embeds,
+			Files:      message.Files,
+			Components: message.Components,
+		})
+	default:
+		// set every field, so an update replaces all of the message
+		embeds := message.Embeds
+		if embeds == nil {
+			embeds = []*discordgo.MessageEmbed{}
+		}
+		components := message.Components
+		if components == nil {
+			components = []discordgo.MessageComponent{}
+		}
+		_, err = d.session.InteractionResponseEdit(in.Interaction, &discordgo.WebhookEdit{
+			Content:    &message.Content,
+			Embeds:     &embeds,
+			Components: &components,
+			Files:      message.Files,
+		})
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("failed to respond to slash command")
+	}
+
+	return err
+}
+
+// interaction returns the slash command a message stands for, if any.
+func (d *Discord) interaction(id string) *interaction {
+	d.interactionsMu.Lock()
+	defer d.interactionsMu.Unlock()
+
+	return d.interactions[id]
+}
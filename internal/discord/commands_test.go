@@ -0,0 +1,172 @@
+package discord
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/distrobyte/gerry/internal/commands"
+	"github.com/google/shlex"
+)
+
+func registry(t *testing.T) *commands.Registry {
+	t.Helper()
+
+	registry := commands.NewRegistry()
+	if err := registry.Register(commands.Builtin()...); err != nil {
+		t.Fatal(err)
+	}
+
+	return registry
+}
+
+func lookup(t *testing.T, acs []*discordgo.ApplicationCommand, name string) *discordgo.ApplicationCommand {
+	t.Helper()
+
+	for _, ac := range acs {
+		if ac.Name == name {
+			return ac
+		}
+	}
+
+	t.Fatalf("no %s command", name)
+	return nil
+}
+
+func TestApplicationCommands(t *testing.T) {
+	acs := applicationCommands(registry(t).Commands())
+
+	karting := lookup(t, acs, "karting")
+	subs := make(map[string]*discordgo.ApplicationCommandOption)
+	for _, option := range karting.Options {
+		// enter has sub-commands of its own
+		want := discordgo.ApplicationCommandOptionSubCommand
+		if option.Name == "enter" {
+			want = discordgo.ApplicationCommandOptionSubCommandGroup
+		}
+		if option.Type != want {
+			t.Errorf("karting %s has type %v, want %v", option.Name, option.Type, want)
+		}
+		subs[option.Name] = option
+	}
+
+	for _, name := range []string{"register", "race", "stats", "graph", "reset"} {
+		if subs[name] == nil {
+			t.Errorf("karting has no %s sub-command", name)
+		}
+	}
+
+	race := subs["race"].Options
+	if len(race) != 12 || race[0].Name != "driver1" || !race[0].Required || race[1].Required || !race[1].Autocomplete {
+		options, _ := json.Marshal(race)
+		t.Errorf("unexpected race options %s", options)
+	}
+
+	if register := subs["register"].Options; len(register) != 1 || register[0].Autocomplete {
+		t.Errorf("new drivers should not be completed")
+	}
+
+	kek := lookup(t, acs, "kek").Options
+	if len(kek) != 2 || kek[0].Name != ownSubCommand || kek[0].Type != discordgo.ApplicationCommandOptionSubCommand ||
+		len(kek[0].Options) != 1 || kek[0].Options[0].Name != "user" || kek[1].Name != "leaderboard" {
+		options, _ := json.Marshal(kek)
+		t.Errorf("unexpected kek options %s", options)
+	}
+
+	version := lookup(t, acs, "version").Options
+	if len(version) != 1 || len(version[0].Choices) != 3 || version[0].Required {
+		options, _ := json.Marshal(version)
+		t.Errorf("unexpected version options %s", options)
+	}
+
+	for _, ac := range acs {
+		if ac.Description == "" || len(ac.Description) > maxDescription {
+			t.Errorf("%s has an invalid description %q", ac.Name, ac.Description)
+		}
+	}
+}
+
+func TestInteractionContent(t *testing.T) {
+	registry := registry(t)
+
+	tests := []struct {
+		data discordgo.ApplicationCommandInteractionData
+		want []string
+	}{
+		{
+			data: discordgo.ApplicationCommandInteractionData{
+				Name: "karting",
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+					Name: "race",
+					Type: discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandInteractionDataOption{
+						{Name: "driver2", Type: discordgo.ApplicationCommandOptionString, Value: "Max V"},
+						{Name: "driver1", Type: discordgo.ApplicationCommandOptionString, Value: "alice"},
+						{Name: "driver3", Type: discordgo.ApplicationCommandOptionString, Value: `o'neil "the kart"`},
+					},
+				}},
+			},
+			want: []string{">karting", "race", "alice", "Max V", `o'neil "the kart"`},
+		},
+		{
+			data: discordgo.ApplicationCommandInteractionData{
+				Name: "echo",
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{Name: "text", Type: discordgo.ApplicationCommandOptionString, Value: "hello world"},
+				},
+			},
+			want: []string{">echo", "hello", "world"},
+		},
+		{
+			data: discordgo.ApplicationCommandInteractionData{Name: "version"},
+			want: []string{">version"},
+		},
+		{
+			data: discordgo.ApplicationCommandInteractionData{
+				Name: "kek",
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+					Name: ownSubCommand,
+					Type: discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandInteractionDataOption{
+						{Name: "user", Type: discordgo.ApplicationCommandOptionUser, Value: "42"},
+					},
+				}},
+			},
+			want: []string{">kek", "<@42>"},
+		},
+	}
+
+	for _, test := range tests {
+		cmd, options, ok := resolveInteraction(registry, test.data)
+		if !ok {
+			t.Fatalf("%s was not resolved", test.data.Name)
+		}
+
+		content := interactionContent(">", cmd, options)
+		got, err := shlex.Split(content)
+		if err != nil {
+			t.Fatalf("splitting %q: %v", content, err)
+		}
+
+		if len(got) != len(test.want) {
+			t.Errorf("%q split into %q, want %q", content, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%q split into %q, want %q", content, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestArgFor(t *testing.T) {
+	race, _, _ := registry(t).Resolve([]string{"karting", "race"})
+
+	for name, ok := range map[string]bool{"driver1": true, "driver12": true, "driver13": false, "drivers": false} {
+		if got := argFor(race, name); (got != nil) != ok {
+			t.Errorf("argFor(%q) = %v", name, got)
+		}
+	}
+}
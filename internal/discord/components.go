@@ -0,0 +1,215 @@
+package discord
+
+import (
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/distrobyte/gerry/internal/commands"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// discord limits on message components
+const (
+	maxMenuOptions = 25
+	maxRowButtons  = 5
+	maxLabel       = 80
+	maxOption      = 100
+	maxModalTitle  = 45
+)
+
+// actionsKept is how many menus and buttons are remembered. Older ones,
+// and the ones sent before a restart, stop working.
+const actionsKept = 1000
+
+// action is what a menu or button runs, see models.Button.
+type action struct {
+	command string
+	prompt  string
+}
+
+// actions maps the custom IDs of components to their action. Custom IDs
+// are short and commands may not fit in them.
+type actions struct {
+	mu    sync.Mutex
+	byID  map[string]action
+	order []string
+}
+
+func (a *actions) add(act action) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.byID == nil {
+		a.byID = make(map[string]action)
+	}
+
+	id := uuid.NewString()
+	a.byID[id] = act
+	a.order = append(a.order, id)
+
+	if len(a.order) > actionsKept {
+		delete(a.byID, a.order[0])
+		a.order = a.order[1:]
+	}
+
+	return id
+}
+
+func (a *actions) get(id string) (action, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	act, ok := a.byID[id]
+	return act, ok
+}
+
+// components renders the menu and buttons of a response as rows of
+// message components.
+func (d *Discord) components(response *models.Response) []discordgo.MessageComponent {
+	var rows []discordgo.MessageComponent
+
+	if menu := response.Menu; menu != nil && len(menu.Options) > 0 {
+		var options []discordgo.SelectMenuOption
+		for _, option := range menu.Options {
+			if len(options) == maxMenuOptions {
+				break
+			}
+			options = append(options, discordgo.SelectMenuOption{
+				Label: truncate(option, maxOption),
+				Value: option,
+			})
+		}
+
+		rows = append(rows, discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.SelectMenu{
+				MenuType:    discordgo.StringSelectMenu,
+				CustomID:    d.actions.add(action{command: menu.Command}),
+				Placeholder: menu.Placeholder,
+				Options:     options,
+			},
+		}})
+	}
+
+	var buttons []discordgo.MessageComponent
+	for _, button := range response.Buttons {
+		if len(buttons) == maxRowButtons {
+			rows = append(rows, discordgo.ActionsRow{Components: buttons})
+			buttons = nil
+		}
+
+		buttons = append(buttons, discordgo.Button{
+			Label:    truncate(button.Label, maxLabel),
+			Style:    buttonStyle(button.Style),
+			CustomID: d.actions.add(action{command: button.Command, prompt: button.Prompt}),
+		})
+	}
+	if len(buttons) > 0 {
+		rows = append(rows, discordgo.ActionsRow{Components: buttons})
+	}
+
+	return rows
+}
+
+func buttonStyle(style models.ButtonStyle) discordgo.ButtonStyle {
+	switch style {
+	case models.ButtonPrimary:
+		return discordgo.PrimaryButton
+	case models.ButtonDanger:
+		return discordgo.DangerButton
+	default:
+		return discordgo.SecondaryButton
+	}
+}
+
+// handleComponent runs the action of a menu, button or the form a button
+// prompted for, replacing the message it was on with the response.
+func (d *Discord) handleComponent(s *discordgo.Session, i *discordgo.Interaction) {
+	var id, value string
+	if i.Type == discordgo.InteractionModalSubmit {
+		data := i.ModalSubmitData()
+		id, value = data.CustomID, modalValue(data.Components)
+	} else {
+		data := i.MessageComponentData()
+		id = data.CustomID
+		if len(data.Values) > 0 {
+			value = data.Values[0]
+		}
+	}
+
+	act, ok := d.actions.get(id)
+	if !ok {
+		d.respondText(s, i, discordgo.InteractionResponseChannelMessageWithSource, "this menu has expired, run the command again")
+		return
+	}
+
+	switch {
+	case act.prompt != "" && i.Type == discordgo.InteractionMessageComponent:
+		err := s.InteractionRespond(i, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseModal,
+			Data: &discordgo.InteractionResponseData{
+				CustomID: id,
+				Title:    truncate(act.prompt, maxModalTitle),
+				Components: []discordgo.MessageComponent{
+					discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:  "value",
+							Label:     truncate(act.prompt, maxModalTitle),
+							Style:     discordgo.TextInputShort,
+							Required:  true,
+							MaxLength: maxOption,
+						},
+					}},
+				},
+			},
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to show form")
+		}
+
+	case act.command == "":
+		d.respondText(s, i, discordgo.InteractionResponseUpdateMessage, "cancelled")
+
+	default:
+		content := d.config.GetBotPrefix() + act.command
+		if value != "" {
+			content += " " + commands.Quote(value)
+		}
+		d.run(s, i, content, true)
+	}
+}
+
+// respondText answers an interaction with text. Updates replace the
+// message the interaction came from, anything else is only shown to the
+// user.
+func (d *Discord) respondText(s *discordgo.Session, i *discordgo.Interaction, kind discordgo.InteractionResponseType, text string) {
+	data := &discordgo.InteractionResponseData{
+		Content:    text,
+		Embeds:     []*discordgo.MessageEmbed{},
+		Components: []discordgo.MessageComponent{},
+	}
+	if kind != discordgo.InteractionResponseUpdateMessage {
+		data.Flags = discordgo.MessageFlagsEphemeral
+	}
+
+	if err := s.InteractionRespond(i, &discordgo.InteractionResponse{Type: kind, Data: data}); err != nil {
+		log.Warn().Err(err).Msg("failed to answer interaction")
+	}
+}
+
+// modalValue returns the value of the first text input of a form.
+func modalValue(components []discordgo.MessageComponent) string {
+	for _, component := range components {
+		switch c := component.(type) {
+		case *discordgo.ActionsRow:
+			if value := modalValue(c.Components); value != "" {
+				return value
+			}
+		case *discordgo.TextInput:
+			return c.Value
+		}
+	}
+
+	return ""
+}
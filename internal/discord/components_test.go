@@ -0,0 +1,84 @@
+package discord
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/distrobyte/gerry/internal/models"
+)
+
+func TestComponents(t *testing.T) {
+	d := New(nil, nil)
+
+	var options []string
+	for i := range 30 {
+		options = append(options, fmt.Sprintf("driver%d", i))
+	}
+
+	rows := d.components(&models.Response{
+		Menu: &models.Menu{Placeholder: "Position 1", Options: options, Command: "karting enter"},
+		Buttons: []models.Button{
+			{Label: "New driver", Command: "karting enter", Prompt: "Driver name"},
+			{Label: "Preview", Style: models.ButtonPrimary, Command: "karting predict alice bob"},
+			{Label: "Cancel", Style: models.ButtonDanger},
+		},
+	})
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want a menu and a button row", len(rows))
+	}
+
+	menu := rows[0].(discordgo.ActionsRow).Components[0].(discordgo.SelectMenu)
+	if len(menu.Options) != maxMenuOptions || menu.Placeholder != "Position 1" {
+		t.Errorf("unexpected menu %+v", menu)
+	}
+	if act, ok := d.actions.get(menu.CustomID); !ok || act.command != "karting enter" {
+		t.Errorf("menu action %+v", act)
+	}
+
+	buttons := rows[1].(discordgo.ActionsRow).Components
+	want := []action{
+		{command: "karting enter", prompt: "Driver name"},
+		{command: "karting predict alice bob"},
+		{},
+	}
+	styles := []discordgo.ButtonStyle{discordgo.SecondaryButton, discordgo.PrimaryButton, discordgo.DangerButton}
+	for i, component := range buttons {
+		button := component.(discordgo.Button)
+		if act, _ := d.actions.get(button.CustomID); act != want[i] || button.Style != styles[i] {
+			t.Errorf("button %d: %+v runs %+v", i, button, act)
+		}
+	}
+
+	if rows := d.components(models.NewTextResponse("hi")); len(rows) != 0 {
+		t.Errorf("text response got components %+v", rows)
+	}
+}
+
+func TestActionsKept(t *testing.T) {
+	var a actions
+
+	first := a.add(action{command: "first"})
+	for range actionsKept {
+		a.add(action{command: "later"})
+	}
+
+	if _, ok := a.get(first); ok {
+		t.Errorf("oldest action was kept")
+	}
+	if len(a.byID) != actionsKept {
+		t.Errorf("kept %d actions", len(a.byID))
+	}
+}
+
+func TestModalValue(t *testing.T) {
+	components := []discordgo.MessageComponent{
+		&discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			&discordgo.TextInput{CustomID: "value", Value: "new kid"},
+		}},
+	}
+
+	if got := modalValue(components); got != "new kid" {
+		t.Errorf("modalValue() = %q", got)
+	}
+}
@@ -1,18 +1,137 @@
 package discord
 
 import (
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/distrobyte/gerry/internal/render"
 	"github.com/rs/zerolog/log"
 )
 
-func SearchGuildByChannelID(textChannelID string) (guildID string) {
-	channel, _ := DiscordSession.Channel(textChannelID)
+// discord rejects embeds with a longer description
+const maxEmbedDescription = 4096
+
+func (d *Discord) SearchGuildByChannelID(textChannelID string) (guildID string) {
+	channel, _ := d.session.Channel(textChannelID)
 	guildID = channel.GuildID
 	return guildID
 }
 
-func SendMessage(channelID string, message string) {
-	_, err := DiscordSession.ChannelMessageSend(channelID, message)
+func (d *Discord) Send(channelID string, response *models.Response) error {
+	message, files := messageSend(response)
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	message.Components = d.components(response)
+
+	_, err := d.session.ChannelMessageSendComplex(channelID, message)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to send message")
 	}
+
+	return err
+}
+
+func (d *Discord) Reply(message *models.Message, response *models.Response) error {
+	if in := d.interaction(message.ID); in != nil {
+		return d.respond(in, response)
+	}
+
+	channelID := message.Channel
+
+	// discord only supports ephemeral messages for slash commands, so send
+	// them to the author directly instead
+	if response.Ephemeral {
+		if message.AuthorID == "" {
+			return platform.ErrNotPrivate
+		}
+
+		channel, err := d.session.UserChannelCreate(message.AuthorID)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to open direct message channel")
+			return err
+		}
+		channelID = channel.ID
+	}
+
+	return d.Send(channelID, response)
+}
+
+func (d *Discord) React(message *models.Message, emoji string) error {
+	err := d.session.MessageReactionAdd(message.Channel, message.ID, emoji)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to add reaction")
+	}
+
+	return err
+}
+
+// Typing shows the typing indicator in a channel for up to ten seconds.
+func (d *Discord) Typing(channelID string) error {
+	return d.session.ChannelTyping(channelID)
+}
+
+// messageSend renders a response as a discord message. Text-only responses
+// are sent as plain content, anything richer as an embed with its images
+// attached. The returned files must be closed once the message is sent.
+func messageSend(response *models.Response) (*discordgo.MessageSend, []io.ReadCloser) {
+	if response.IsPlainText() {
+		return &discordgo.MessageSend{Content: response.Text}, nil
+	}
+
+	body := *response
+	body.Title = ""
+	body.Images = nil
+
+	embed := &discordgo.MessageEmbed{
+		Title:       response.Title,
+		Description: truncate(render.Markdown(&body), maxEmbedDescription),
+	}
+	message := &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}}
+
+	var files []io.ReadCloser
+	for _, image := range response.Images {
+		if image.Path != "" {
+			file, err := os.Open(image.Path)
+			if err == nil {
+				files = append(files, file)
+				message.Files = append(message.Files, &discordgo.File{
+					Name:        image.Name,
+					ContentType: mime.TypeByExtension(filepath.Ext(image.Name)),
+					Reader:      file,
+				})
+
+				// discord only shows one image per embed, extra ones are
+				// still attached to the message
+				if embed.Image == nil {
+					embed.Image = &discordgo.MessageEmbedImage{URL: "attachment://" + image.Name}
+				}
+				continue
+			}
+
+			log.Warn().Err(err).Str("file", image.Path).Msg("failed to open image, falling back to url")
+		}
+
+		if embed.Image == nil && image.URL != "" {
+			embed.Image = &discordgo.MessageEmbedImage{URL: image.URL}
+		}
+	}
+
+	return message, files
+}
+
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+
+	return string(runes[:limit-1]) + "…"
 }
@@ -1,90 +1,189 @@
 package discord
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/distrobyte/gerry/internal/config"
-	"github.com/distrobyte/gerry/internal/handlers"
 	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
 	"github.com/rs/zerolog/log"
 )
 
-var DiscordSession *discordgo.Session
+func init() {
+	platform.Register(platform.Driver{
+		Name:    "discord",
+		Enabled: (*config.Config).IsDiscordEnabled,
+		New: func(cfg *config.Config, handler platform.Handler) platform.Platform {
+			return New(cfg, handler)
+		},
+	})
+}
+
+// Discord is the platform adapter for a discord bot account.
+type Discord struct {
+	ctx     context.Context
+	config  *config.Config
+	session *discordgo.Session
+	handler platform.Handler
+	// closing is set by Disconnect, so the disconnect it causes is not
+	// reported as a lost connection
+	closing atomic.Bool
+
+	// interactions maps the message IDs of slash commands being run to
+	// their interaction, so replies answer it
+	interactionsMu sync.Mutex
+	interactions   map[string]*interaction
+	actions        actions
+}
+
+func New(cfg *config.Config, handler platform.Handler) *Discord {
+	return &Discord{config: cfg, handler: handler, interactions: make(map[string]*interaction)}
+}
+
+func (d *Discord) Name() string {
+	return "discord"
+}
+
+func (d *Discord) Connect(ctx context.Context) error {
+	d.ctx = ctx
+	if err := d.initSession(); err != nil {
+		return err
+	}
+
+	// discordgo reconnects by itself and keeps the handlers, these only
+	// report how it is doing
+	d.session.AddHandler(d.disconnectHandler)
+	d.session.AddHandler(d.resumedHandler)
+	d.session.AddHandler(d.readyHandler)
+	d.session.AddHandler(d.messageCreateHandler)
+	d.session.AddHandler(d.messageReactHandler)
+	d.session.AddHandler(d.messageReactRemoveHandler)
+	d.session.AddHandler(d.interactionCreateHandler)
+
+	if err := d.session.Open(); err != nil {
+		log.Error().Err(err).Msg("failed to create websocket connection to discord")
+		return err
+	}
+
+	return nil
+}
 
-func InitSession() {
+func (d *Discord) Disconnect() error {
+	if d.session == nil {
+		return nil
+	}
+
+	d.closing.Store(true)
+	return d.session.Close()
+}
+
+// Reload shows the status from the current config.
+func (d *Discord) Reload() error {
+	if d.session == nil {
+		return nil
+	}
+
+	return d.session.UpdateListeningStatus(d.config.GetBotStatus())
+}
+
+func (d *Discord) initSession() error {
 	var err error
-	DiscordSession, err = discordgo.New("Bot " + config.GetDiscordToken())
+	d.session, err = discordgo.New("Bot " + d.config.GetDiscordToken())
 	if err != nil {
-		log.Fatal().Err(err).Msg("failed to create discord session")
+		log.Error().Err(err).Msg("failed to create discord session")
+		return err
 	}
 
-	DiscordSession.Identify.Intents = discordgo.IntentsGuildMessages |
+	d.session.Identify.Intents = discordgo.IntentsGuildMessages |
 		discordgo.IntentsGuildMessageReactions |
 		discordgo.IntentsGuilds |
 		discordgo.IntentsGuildMessageTyping |
 		discordgo.IntentsGuildVoiceStates |
 		discordgo.IntentsDirectMessages
 
-	DiscordSession.State.MaxMessageCount = 200
-	DiscordSession.State.TrackChannels = true
-	DiscordSession.State.TrackThreads = true
-	DiscordSession.State.TrackEmojis = true
-	DiscordSession.State.TrackMembers = true
-	DiscordSession.State.TrackThreadMembers = true
-	DiscordSession.State.TrackRoles = true
-	DiscordSession.State.TrackVoice = true
-	DiscordSession.State.TrackPresences = true
-}
-
-func InitConnection() {
-	if err := DiscordSession.Open(); err != nil {
-		log.Fatal().Err(err).Msg("failed to create websocket connection to discord")
-		return
-	}
+	d.session.State.MaxMessageCount = 200
+	d.session.State.TrackChannels = true
+	d.session.State.TrackThreads = true
+	d.session.State.TrackEmojis = true
+	d.session.State.TrackMembers = true
+	d.session.State.TrackThreadMembers = true
+	d.session.State.TrackRoles = true
+	d.session.State.TrackVoice = true
+	d.session.State.TrackPresences = true
+
+	return nil
 }
 
-func ReadyHandler(s *discordgo.Session, event *discordgo.Ready) {
-	err := s.UpdateListeningStatus(config.GetBotStatus())
+func (d *Discord) readyHandler(s *discordgo.Session, event *discordgo.Ready) {
+	err := s.UpdateListeningStatus(d.config.GetBotStatus())
 	if err != nil {
 		log.Warn().Err(err).Msg("failed to update game status")
 	}
 
 	log.Info().Msg("connected to discord")
+	d.handler.HandleState(d, platform.StateConnected, nil)
+
+	appID := s.State.User.ID
+	if event.Application != nil {
+		appID = event.Application.ID
+	}
+	d.registerCommands(s, appID)
 }
 
-func MessageCreateHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if m.Author.ID == s.State.User.ID {
+func (d *Discord) disconnectHandler(s *discordgo.Session, event *discordgo.Disconnect) {
+	if d.closing.Load() {
 		return
 	}
 
-	message := &models.Message{
-		Content:    m.Content,
-		Author:     m.Author.Username,
-		Channel:    m.ChannelID,
-		ID:         m.ID,
-		RecievedAt: time.Now(),
-		Platform:   "discord",
+	d.handler.HandleState(d, platform.StateReconnecting, errors.New("discord gateway connection lost"))
+}
+
+func (d *Discord) resumedHandler(s *discordgo.Session, event *discordgo.Resumed) {
+	log.Info().Msg("resumed discord session")
+	d.handler.HandleState(d, platform.StateConnected, nil)
+}
+
+func (d *Discord) messageCreateHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.Author.ID == s.State.User.ID {
+		return
 	}
 
-	response, err := handlers.HandleMessage(message)
-	if err == nil && response != "" {
-		SendMessage(message.Channel, response)
-		log.Info().
-			Str("platform", message.Platform).
-			Str("event", "message").
-			Str("content", message.Content).
-			Str("author", message.Author).
-			Str("channel", message.Channel).
-			Str("id", message.ID).
-			TimeDiff("duration", time.Now(), message.RecievedAt).
-			Msg("handled message")
-	} else if err != nil {
-		log.Error().Err(err).Msg("failed to handle message")
+	// member is only set for messages sent in a guild
+	var roles []string
+	if m.Member != nil {
+		roles = m.Member.Roles
 	}
+
+	d.handler.HandleMessage(d.ctx, d, &models.Message{
+		Content:     m.Content,
+		Author:      m.Author.Username,
+		AuthorID:    m.Author.ID,
+		AuthorRoles: roles,
+		Channel:     m.ChannelID,
+		ID:          m.ID,
+		RecievedAt:  time.Now(),
+		Platform:    d.Name(),
+	})
+}
+
+func (d *Discord) messageReactHandler(s *discordgo.Session, m *discordgo.MessageReactionAdd) {
+	d.handleReaction(s, m.MessageReaction, false)
+}
+
+func (d *Discord) messageReactRemoveHandler(s *discordgo.Session, m *discordgo.MessageReactionRemove) {
+	d.handleReaction(s, m.MessageReaction, true)
 }
 
-func MessageReactHandler(s *discordgo.Session, m *discordgo.MessageReactionAdd) {
+func (d *Discord) handleReaction(s *discordgo.Session, m *discordgo.MessageReaction, removed bool) {
+	if m.UserID == s.State.User.ID {
+		return
+	}
+
 	message, err := s.State.Message(m.ChannelID, m.MessageID)
 	if err == discordgo.ErrStateNotFound {
 		message, err = s.ChannelMessage(m.ChannelID, m.MessageID)
@@ -101,11 +200,40 @@ func MessageReactHandler(s *discordgo.Session, m *discordgo.MessageReactionAdd)
 		Str("message_id", m.MessageID).
 		Str("user_id", m.UserID).
 		Str("emoji", m.Emoji.Name).
-		Str("message_content", message.Content).
-		Str("emoji", m.Emoji.Name).
-		Msg("reaction added")
+		Bool("removed", removed).
+		Msg("reaction")
+
+	d.handler.HandleReaction(d.ctx, d, &models.MessageReaction{
+		Message: &models.Message{
+			Content:    message.Content,
+			Author:     message.Author.Username,
+			AuthorID:   message.Author.ID,
+			Channel:    m.ChannelID,
+			ID:         m.MessageID,
+			RecievedAt: time.Now(),
+			Platform:   d.Name(),
+		},
+		Emoji:     m.Emoji.Name,
+		Reactor:   d.userName(s, m.GuildID, m.UserID),
+		ReactorID: m.UserID,
+		Removed:   removed,
+	})
+}
 
-	if m.UserID == s.State.User.ID {
-		return
+// userName returns the username of a user, or the ID if it cannot be
+// looked up.
+func (d *Discord) userName(s *discordgo.Session, guildID, userID string) string {
+	if guildID != "" {
+		if member, err := s.State.Member(guildID, userID); err == nil && member.User != nil {
+			return member.User.Username
+		}
+	}
+
+	user, err := s.User(userID)
+	if err != nil {
+		log.Warn().Err(err).Str("user_id", userID).Msg("failed to look up user")
+		return userID
 	}
+
+	return user.Username
 }
@@ -1,75 +1,121 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
 	"strings"
-	"syscall"
 
 	"github.com/distrobyte/gerry/internal/commands"
-	"github.com/distrobyte/gerry/internal/config"
 	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
 	"github.com/google/shlex"
 	"github.com/rs/zerolog/log"
 )
 
-func InitCommands() {
-	commands.InitKarting()
-}
-
-func HandleMessage(message *models.Message) (string, error) {
-
-	var cmd string
-	var args []string
-
-	prefix := config.GetBotPrefix()
-	args, err := shlex.Split(message.Content)
-
+// HandleMessage parses a message into a command and runs it through the
+// middleware stack of the registry.
+func HandleMessage(ctx context.Context, env *commands.Env, p platform.Platform, message *models.Message) (*models.Response, error) {
+	prefix := env.Config.GetBotPrefix()
+	args, err := split(message.Content)
 	if err != nil {
-		if err.Error() == "EOF found when expecting closing quote" {
-			args = strings.Fields(message.Content)
-		} else {
-			log.Error().Err(err).Msg("failed to split message")
-			return "", err
-		}
+		log.Error().Err(err).Msg("failed to split message")
+		return nil, err
 	}
 
 	if len(args) == 0 {
-		return "", nil
+		return nil, nil
 	}
 
-	cmd, ok := strings.CutPrefix(args[0], prefix)
+	if !strings.HasPrefix(args[0], prefix) {
+		// laughing in text counts as reacting to the message laughed at
+		if reaction := commands.KeywordReaction(env, message); reaction != nil {
+			responses, _ := HandleReaction(ctx, env, p, reaction)
+			for _, response := range responses {
+				if err := p.Reply(message, response); err != nil {
+					log.Error().Err(err).Msg("failed to reply to keyword reaction")
+				}
+			}
+		}
+
+		// only conversation is remembered, so commands can refer to it
+		env.History.Add(message)
+		return nil, nil
+	}
+
+	cmd, args, ok := resolve(env.Registry, prefix, args)
 	if !ok {
-		return "", nil
+		return nil, nil
 	}
 
-	args = args[1:]
+	if cmd.Handler == nil {
+		if len(args) == 0 {
+			return models.NewTextResponse(fmt.Sprintf("%s requires a sub-command\nusage: %s", cmd.Path(), cmd.Synopsis(prefix))), nil
+		}
+		return models.NewTextResponse(fmt.Sprintf("invalid %s command %q\nusage: %s", cmd.Path(), args[0], cmd.Synopsis(prefix))), nil
+	}
 
-	switch cmd {
-	case "ping":
-		return commands.PingCommand(), nil
+	return env.Registry.Handler(cmd)(ctx, &commands.Request{
+		Env:      env,
+		Command:  cmd,
+		Args:     args,
+		Message:  message,
+		Platform: p,
+	}), nil
+}
 
-	case "echo":
-		return commands.EchoCommand(args), nil
+// Command returns the command content runs and the arguments left for it,
+// or false if content does not start with the prefix and the name of a
+// registered command. It parses content like HandleMessage.
+func Command(registry *commands.Registry, prefix, content string) (*commands.Command, []string, bool) {
+	args, err := split(content)
+	if err != nil || len(args) == 0 {
+		return nil, nil, false
+	}
 
-	case "karting":
-		return commands.KartingCommand(args, *message), nil
+	return resolve(registry, prefix, args)
+}
 
-	case "uptime":
-		return commands.UptimeCommand(), nil
+// split splits content into words like a shell does, taking an unclosed
+// quote literally.
+func split(content string) ([]string, error) {
+	args, err := shlex.Split(content)
+	if err != nil && err.Error() == "EOF found when expecting closing quote" {
+		return strings.Fields(content), nil
+	}
 
-	case "version":
-		return commands.VersionCommand(args), nil
+	return args, err
+}
 
-	case "shutdown":
-		config.ShutdownChannel <- syscall.SIGINT
-		return "Shutting down...", nil
+// resolve looks up the command named by the first of args, which starts
+// with prefix.
+func resolve(registry *commands.Registry, prefix string, args []string) (*commands.Command, []string, bool) {
+	name, ok := strings.CutPrefix(args[0], prefix)
+	if !ok {
+		return nil, nil, false
+	}
+
+	return registry.Resolve(append([]string{name}, args[1:]...))
+}
 
-	default:
-		break
+// HandleReaction passes a reaction being added or removed to every
+// reaction handler of the registry and returns their responses.
+func HandleReaction(ctx context.Context, env *commands.Env, p platform.Platform, reaction *models.MessageReaction) ([]*models.Response, error) {
+	if reaction.Message == nil {
+		return nil, fmt.Errorf("reaction has no message")
 	}
 
-	return "", nil
+	return env.Registry.DispatchReaction(ctx, &commands.ReactionRequest{
+		Env:      env,
+		Reaction: reaction,
+		Platform: p,
+	}), nil
 }
 
-func HandleReaction(message *models.Message) (string, error) {
-	return "", nil
+// HandleComplete suggests values for a command argument as it is typed.
+func HandleComplete(ctx context.Context, env *commands.Env, arg *commands.Arg, value string) []string {
+	if arg.Complete == nil {
+		return nil
+	}
+
+	return arg.Complete(ctx, env, value)
 }
@@ -0,0 +1,80 @@
+package irc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/distrobyte/gerry/internal/render"
+	"github.com/rs/zerolog/log"
+)
+
+var errNotConnected = errors.New("not connected to irc server")
+
+// Send posts a response to a channel, or to a user when channel is a nick.
+// Long responses are split over several lines.
+func (c *IRC) Send(channel string, response *models.Response) error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn == nil {
+		return errNotConnected
+	}
+
+	for _, line := range splitText(render.Plain(response), maxText(c.currentNick(), channel)) {
+		if err := c.writeLine(conn, "PRIVMSG "+channel+" :"+line); err != nil {
+			log.Error().Err(err).Str("platform", "irc").Msg("failed to send message")
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Reply responds in the channel the message came from, or privately to its
+// author for ephemeral responses.
+func (c *IRC) Reply(message *models.Message, response *models.Response) error {
+	if response.Ephemeral {
+		return c.Send(message.Author, response)
+	}
+
+	return c.Send(message.Channel, response)
+}
+
+func (c *IRC) React(message *models.Message, emoji string) error {
+	return platform.ErrNotSupported
+}
+
+// writeLine sends a single protocol line. Lines are paced so bursts of
+// output do not get the bot disconnected for flooding.
+func (c *IRC) writeLine(conn net.Conn, line string) error {
+	if strings.ContainsAny(line, "\r\n") {
+		return fmt.Errorf("line contains a line break: %q", line)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	now := time.Now()
+	if c.floodAt.Before(now) {
+		c.floodAt = now
+	}
+
+	if wait := c.floodAt.Sub(now) - c.floodBurst; wait > 0 {
+		ctx := c.context()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	c.floodAt = c.floodAt.Add(c.floodPenalty)
+
+	_, err := conn.Write([]byte(line + "\r\n"))
+	return err
+}
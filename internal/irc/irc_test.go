@@ -0,0 +1,377 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/distrobyte/gerry/internal/testkit/platformtest"
+)
+
+// fakeServer is a scripted IRC server. Tests read what the client sends and
+// write the server's side of the conversation.
+type fakeServer struct {
+	t     *testing.T
+	ln    net.Listener
+	conns chan net.Conn
+}
+
+func newFakeServer(t *testing.T, tlsConfig *tls.Config) *fakeServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+
+	s := &fakeServer{t: t, ln: ln, conns: make(chan net.Conn, 4)}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			// the client waits for the handshake before Connect returns
+			if tlsConn, ok := conn.(*tls.Conn); ok {
+				if err := tlsConn.Handshake(); err != nil {
+					conn.Close()
+					continue
+				}
+			}
+			s.conns <- conn
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+// accept waits for the client to connect.
+func (s *fakeServer) accept() *fakeConn {
+	s.t.Helper()
+
+	select {
+	case conn := <-s.conns:
+		s.t.Cleanup(func() { conn.Close() })
+		return &fakeConn{t: s.t, conn: conn, reader: bufio.NewReader(conn)}
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("client did not connect")
+		return nil
+	}
+}
+
+type fakeConn struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// expect reads the next line from the client and checks it is want.
+func (c *fakeConn) expect(want string) {
+	c.t.Helper()
+
+	if got := c.read(); got != want {
+		c.t.Fatalf("client sent %q, want %q", got, want)
+	}
+}
+
+func (c *fakeConn) read() string {
+	c.t.Helper()
+
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("reading from client: %v", err)
+	}
+
+	return strings.TrimRight(line, "\r\n")
+}
+
+func (c *fakeConn) send(lines ...string) {
+	c.t.Helper()
+
+	for _, line := range lines {
+		if _, err := c.conn.Write([]byte(line + "\r\n")); err != nil {
+			c.t.Fatalf("writing to client: %v", err)
+		}
+	}
+}
+
+// register plays the server side of a registration without capabilities.
+func (c *fakeConn) register(nick string) {
+	c.t.Helper()
+
+	c.expect("CAP LS 302")
+	c.expect("NICK " + nick)
+	c.expect("USER " + nick + " 0 * :")
+	c.send(":irc.test CAP * LS :multi-prefix")
+	c.expect("CAP END")
+	c.send(":irc.test 001 " + nick + " :Welcome")
+}
+
+func newConfig(port int) *config.Config {
+	cfg := &config.Config{}
+	cfg.IRC.Host = "127.0.0.1"
+	cfg.IRC.Port = port
+	cfg.IRC.Nick = "gerry"
+	cfg.IRC.Channels = []string{"#gerry", "#karting"}
+	return cfg
+}
+
+func connect(t *testing.T, cfg *config.Config, h *platformtest.Recorder) *IRC {
+	t.Helper()
+
+	c := New(cfg, h)
+	c.floodBurst = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := c.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		c.Disconnect()
+	})
+
+	return c
+}
+
+func TestJoinAndReply(t *testing.T) {
+	server := newFakeServer(t, nil)
+	h := platformtest.NewRecorder(t, models.NewTextResponse("Pong!"))
+	connect(t, newConfig(server.port()), h)
+
+	conn := server.accept()
+	conn.register("gerry")
+	conn.expect("JOIN #gerry,#karting")
+
+	conn.send("PING :irc.test")
+	conn.expect("PONG :irc.test")
+
+	conn.send("@account=alice;msgid=42 :alice!a@host PRIVMSG #gerry :>ping")
+	message := h.Message()
+	if message.Author != "alice" || message.AuthorID != "alice" || message.Channel != "#gerry" || message.ID != "42" || message.Content != ">ping" {
+		t.Errorf("unexpected message %+v", message)
+	}
+	conn.expect("PRIVMSG #gerry :Pong!")
+
+	// private messages are answered privately and without an account the
+	// author has no ID
+	conn.send(":bob!b@host PRIVMSG gerry :>ping")
+	message = h.Message()
+	if message.Channel != "bob" || message.AuthorID != "" {
+		t.Errorf("unexpected message %+v", message)
+	}
+	conn.expect("PRIVMSG bob :Pong!")
+}
+
+func TestMessageOrder(t *testing.T) {
+	server := newFakeServer(t, nil)
+	h := platformtest.NewRecorder(t, nil)
+	connect(t, newConfig(server.port()), h)
+
+	conn := server.accept()
+	conn.register("gerry")
+	conn.expect("JOIN #gerry,#karting")
+
+	for i := range 20 {
+		conn.send(fmt.Sprintf(":alice!a@host PRIVMSG #gerry :message %d", i))
+	}
+
+	// keyword reactions and the history rely on messages arriving in order
+	for i := range 20 {
+		if got, want := h.Message().Content, fmt.Sprintf("message %d", i); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSASL(t *testing.T) {
+	server := newFakeServer(t, nil)
+	cfg := newConfig(server.port())
+	cfg.IRC.Auth = "sasl"
+	cfg.IRC.Account = "gerrybot"
+	cfg.IRC.Password = "hunter2"
+	connect(t, cfg, platformtest.NewRecorder(t, nil))
+
+	conn := server.accept()
+	conn.expect("CAP LS 302")
+	conn.expect("NICK gerry")
+	conn.expect("USER gerry 0 * :")
+	conn.send(":irc.test CAP * LS * :multi-prefix sasl=PLAIN,EXTERNAL", ":irc.test CAP * LS :account-tag")
+	conn.expect("CAP REQ :sasl account-tag")
+	conn.send(":irc.test CAP gerry ACK :sasl account-tag")
+	conn.expect("AUTHENTICATE PLAIN")
+	conn.send("AUTHENTICATE +")
+	conn.expect("AUTHENTICATE " + base64.StdEncoding.EncodeToString([]byte("gerrybot\x00gerrybot\x00hunter2")))
+	conn.send(":irc.test 903 gerry :SASL authentication successful")
+	conn.expect("CAP END")
+	conn.send(":irc.test 001 gerry :Welcome")
+	conn.expect("JOIN #gerry,#karting")
+}
+
+func TestNickServ(t *testing.T) {
+	server := newFakeServer(t, nil)
+	cfg := newConfig(server.port())
+	cfg.IRC.Auth = "nickserv"
+	cfg.IRC.Password = "hunter2"
+	connect(t, cfg, platformtest.NewRecorder(t, nil))
+
+	conn := server.accept()
+	conn.expect("CAP LS 302")
+	conn.expect("NICK gerry")
+	conn.expect("USER gerry 0 * :")
+	conn.send(":irc.test CAP * LS :")
+	conn.expect("CAP END")
+	conn.send(":irc.test 433 * gerry :Nickname is already in use")
+	conn.expect("NICK gerry_")
+	conn.send(":irc.test 001 gerry_ :Welcome")
+	conn.expect("PRIVMSG NickServ :IDENTIFY gerry hunter2")
+	conn.expect("JOIN #gerry,#karting")
+}
+
+func TestReconnect(t *testing.T) {
+	server := newFakeServer(t, nil)
+	h := platformtest.NewRecorder(t, nil)
+	c := connect(t, newConfig(server.port()), h)
+
+	conn := server.accept()
+	conn.register("gerry")
+	conn.expect("JOIN #gerry,#karting")
+	conn.conn.Close()
+
+	// the lost connection is left to the bot to connect again
+	if got := h.State(); got.State != platform.StateDisconnected || got.Err == nil {
+		t.Errorf("got state %s (%v), want disconnected with an error", got.State, got.Err)
+	}
+	if err := c.Connect(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	conn = server.accept()
+	conn.register("gerry")
+	conn.expect("JOIN #gerry,#karting")
+}
+
+func TestTLS(t *testing.T) {
+	server := newFakeServer(t, &tls.Config{Certificates: []tls.Certificate{selfSigned(t)}})
+	cfg := newConfig(server.port())
+	cfg.IRC.TLS = true
+	cfg.IRC.TLSSkipVerify = true
+	connect(t, cfg, platformtest.NewRecorder(t, nil))
+
+	conn := server.accept()
+	conn.register("gerry")
+	conn.expect("JOIN #gerry,#karting")
+}
+
+func TestLongReplySplit(t *testing.T) {
+	server := newFakeServer(t, nil)
+	long := strings.Repeat("word ", 200)
+	h := platformtest.NewRecorder(t, models.NewTextResponse("first line\n\n"+long))
+	connect(t, newConfig(server.port()), h)
+
+	conn := server.accept()
+	conn.register("gerry")
+	conn.expect("JOIN #gerry,#karting")
+
+	conn.send(":alice!a@host PRIVMSG #gerry :>long")
+	h.Message()
+
+	conn.expect("PRIVMSG #gerry :first line")
+
+	var got []string
+	for len(strings.Join(got, " ")) < len(strings.TrimSpace(long)) {
+		line := conn.read()
+		if len(line)+len(":gerry!")+hostReserve > lineLimit {
+			t.Errorf("line of %d bytes is too long once relayed", len(line))
+		}
+		text, ok := strings.CutPrefix(line, "PRIVMSG #gerry :")
+		if !ok {
+			t.Fatalf("unexpected line %q", line)
+		}
+		got = append(got, text)
+	}
+
+	if len(got) < 2 {
+		t.Errorf("reply was sent in %d lines, want it split", len(got))
+	}
+	if strings.Join(got, " ") != strings.TrimSpace(long) {
+		t.Errorf("split lines do not add up to the reply")
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	for _, tt := range []struct {
+		text  string
+		limit int
+		want  []string
+	}{
+		{"short", 10, []string{"short"}},
+		{"one two three", 8, []string{"one two", "three"}},
+		{"a\n\nb", 10, []string{"a", "b"}},
+		{"abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		{"ééé", 3, []string{"é", "é", "é"}},
+	} {
+		got := splitText(tt.text, tt.limit)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("splitText(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	m, err := parseMessage(`@account=al\sice;msgid=1 :alice!a@host PRIVMSG #gerry :hello there`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.tags["account"] != "al ice" || m.nick() != "alice" || m.command != "PRIVMSG" || m.param(0) != "#gerry" || m.param(1) != "hello there" {
+		t.Errorf("unexpected message %+v", m)
+	}
+}
+
+func selfSigned(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
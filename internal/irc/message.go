@@ -0,0 +1,94 @@
+package irc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// message is a single IRC protocol line, see
+// https://modern.ircdocs.horse/#message-format
+type message struct {
+	tags    map[string]string
+	source  string
+	command string
+	params  []string
+}
+
+// parseMessage parses a line without its trailing CRLF.
+func parseMessage(line string) (*message, error) {
+	m := &message{}
+	raw := line
+
+	if strings.HasPrefix(line, "@") {
+		var tags string
+		tags, line, _ = strings.Cut(line[1:], " ")
+		m.tags = parseTags(tags)
+	}
+
+	line = strings.TrimLeft(line, " ")
+	if strings.HasPrefix(line, ":") {
+		m.source, line, _ = strings.Cut(line[1:], " ")
+	}
+
+	for line != "" {
+		line = strings.TrimLeft(line, " ")
+		if line == "" {
+			break
+		}
+
+		if strings.HasPrefix(line, ":") {
+			m.params = append(m.params, line[1:])
+			break
+		}
+
+		var param string
+		param, line, _ = strings.Cut(line, " ")
+		if m.command == "" {
+			m.command = strings.ToUpper(param)
+			continue
+		}
+		m.params = append(m.params, param)
+	}
+
+	if m.command == "" {
+		return nil, fmt.Errorf("no command in %q", raw)
+	}
+
+	return m, nil
+}
+
+func parseTags(raw string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(raw, ";") {
+		key, value, _ := strings.Cut(tag, "=")
+		tags[key] = unescapeTag(value)
+	}
+
+	return tags
+}
+
+var tagUnescaper = strings.NewReplacer(`\:`, ";", `\s`, " ", `\\`, `\`, `\r`, "\r", `\n`, "\n")
+
+func unescapeTag(value string) string {
+	return tagUnescaper.Replace(value)
+}
+
+// nick returns the nick from a nick!user@host source.
+func (m *message) nick() string {
+	nick, _, _ := strings.Cut(m.source, "!")
+	return nick
+}
+
+// param returns the ith parameter, or "" if there are not that many.
+func (m *message) param(i int) string {
+	if i >= len(m.params) {
+		return ""
+	}
+
+	return m.params[i]
+}
+
+// isChannel reports whether target names a channel rather than a user.
+func isChannel(target string) bool {
+	return target != "" && strings.ContainsRune("#&+!", rune(target[0]))
+}
@@ -0,0 +1,430 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/rs/zerolog/log"
+)
+
+func init() {
+	platform.Register(platform.Driver{
+		Name:    "irc",
+		Enabled: (*config.Config).IsIRCEnabled,
+		New: func(cfg *config.Config, handler platform.Handler) platform.Platform {
+			return New(cfg, handler)
+		},
+	})
+}
+
+const (
+	// servers ping idle clients every few minutes, a connection silent for
+	// longer than this is considered dead
+	readTimeout = 5 * time.Minute
+	dialTimeout = 30 * time.Second
+)
+
+// IRC is the platform adapter for an IRC server connection. A lost
+// connection is reported to the handler, which calls Connect again.
+type IRC struct {
+	ctx     context.Context
+	config  *config.Config
+	handler platform.Handler
+
+	// each line sent moves the flood clock forward by floodPenalty, lines
+	// are held back while it is more than floodBurst ahead
+	floodPenalty time.Duration
+	floodBurst   time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	nick   string
+	closed bool
+	done   chan struct{}
+
+	writeMu sync.Mutex
+	floodAt time.Time
+
+	// dispatch hands messages to the handler in order, channel by channel
+	dispatch platform.Dispatcher
+}
+
+func New(cfg *config.Config, handler platform.Handler) *IRC {
+	return &IRC{
+		config:       cfg,
+		handler:      handler,
+		floodPenalty: time.Second,
+		floodBurst:   5 * time.Second,
+	}
+}
+
+func (c *IRC) Name() string {
+	return "irc"
+}
+
+// Connect dials the server and registers in the background. It is called
+// again to reconnect once the connection was lost.
+func (c *IRC) Connect(ctx context.Context) error {
+	if c.config.GetIRCHost() == "" {
+		return fmt.Errorf("irc host is not configured")
+	}
+
+	c.mu.Lock()
+	c.ctx = ctx
+	c.mu.Unlock()
+
+	conn, err := c.dial(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to connect to irc server")
+		return err
+	}
+
+	done := make(chan struct{})
+	c.mu.Lock()
+	c.conn = conn
+	c.done = done
+	c.mu.Unlock()
+
+	go c.run(conn, done)
+
+	return nil
+}
+
+func (c *IRC) Disconnect() error {
+	c.mu.Lock()
+	c.closed = true
+	conn := c.conn
+	done := c.done
+	c.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	// best effort, the server drops us either way once the socket closes
+	_ = conn.SetWriteDeadline(time.Now().Add(time.Second))
+	_, _ = conn.Write([]byte("QUIT :goodbye\r\n"))
+
+	err := conn.Close()
+	<-done
+
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (c *IRC) dial(ctx context.Context) (net.Conn, error) {
+	addr := net.JoinHostPort(c.config.GetIRCHost(), strconv.Itoa(c.config.GetIRCPort()))
+	dialer := &net.Dialer{Timeout: dialTimeout}
+
+	if !c.config.GetIRCTLS() {
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+
+	tlsDialer := &tls.Dialer{
+		NetDialer: dialer,
+		Config: &tls.Config{
+			ServerName:         c.config.GetIRCHost(),
+			InsecureSkipVerify: c.config.GetIRCTLSSkipVerify(),
+		},
+	}
+
+	return tlsDialer.DialContext(ctx, "tcp", addr)
+}
+
+// run serves conn until it drops, and reports the lost connection unless
+// the bot disconnected.
+func (c *IRC) run(conn net.Conn, done chan struct{}) {
+	defer close(done)
+
+	err := c.serve(conn)
+	if c.isClosed() {
+		return
+	}
+
+	log.Warn().Err(err).Str("platform", "irc").Msg("irc connection lost")
+	c.handler.HandleState(c, platform.StateDisconnected, err)
+}
+
+func (c *IRC) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closed || c.ctx.Err() != nil
+}
+
+// context returns the context of the bot passed to Connect.
+func (c *IRC) context() context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ctx
+}
+
+// session is the registration state of a single connection.
+type session struct {
+	conn       net.Conn
+	nick       string
+	caps       []string
+	registered bool
+}
+
+// serve registers on conn and handles what the server sends until the
+// connection fails.
+func (c *IRC) serve(conn net.Conn) error {
+	if c.isClosed() {
+		conn.Close()
+		return errors.New("disconnected")
+	}
+
+	defer func() {
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+		conn.Close()
+	}()
+
+	s := &session{conn: conn, nick: c.config.GetIRCNick()}
+
+	// CAP LS holds registration until CAP END on servers that support it,
+	// others ignore it
+	for _, line := range []string{
+		"CAP LS 302",
+		"NICK " + s.nick,
+		"USER " + s.nick + " 0 * :" + c.config.GetBotName(),
+	} {
+		if err := c.writeLine(conn, line); err != nil {
+			return err
+		}
+	}
+
+	reader := bufio.NewReader(conn)
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(readTimeout)); err != nil {
+			return err
+		}
+
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			continue
+		}
+
+		msg, err := parseMessage(line)
+		if err != nil {
+			log.Debug().Err(err).Str("platform", "irc").Msg("ignoring malformed line")
+			continue
+		}
+
+		if err := c.handle(s, msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *IRC) handle(s *session, msg *message) error {
+	switch msg.command {
+	case "PING":
+		return c.writeLine(s.conn, "PONG :"+msg.param(0))
+
+	case "CAP":
+		return c.handleCap(s, msg)
+
+	case "AUTHENTICATE":
+		if msg.param(0) != "+" {
+			return nil
+		}
+		return c.authenticate(s)
+
+	case "903":
+		log.Info().Str("platform", "irc").Str("account", c.config.GetIRCAccount()).Msg("sasl authentication succeeded")
+		return c.writeLine(s.conn, "CAP END")
+
+	case "902", "904", "905", "906", "907", "908":
+		log.Error().Str("platform", "irc").Str("reply", msg.command).Str("error", msg.param(len(msg.params)-1)).Msg("sasl authentication failed")
+		return c.writeLine(s.conn, "CAP END")
+
+	case "433":
+		// nick in use, only ours to fix before registration
+		if s.registered {
+			return nil
+		}
+		s.nick += "_"
+		return c.writeLine(s.conn, "NICK "+s.nick)
+
+	case "001":
+		return c.welcome(s, msg)
+
+	case "NICK":
+		if msg.nick() == s.nick {
+			s.nick = msg.param(0)
+			c.setNick(s.nick)
+		}
+
+	case "PRIVMSG":
+		c.privmsg(s, msg)
+
+	case "ERROR":
+		return fmt.Errorf("server closed the connection: %s", msg.param(0))
+	}
+
+	return nil
+}
+
+// handleCap negotiates the capabilities gerry uses: sasl to log in and
+// account-tag to know the services account of whoever sends a command.
+func (c *IRC) handleCap(s *session, msg *message) error {
+	switch msg.param(1) {
+	case "LS":
+		// multiline replies have a "*" before the final parameter
+		more := len(msg.params) > 3 && msg.param(2) == "*"
+		s.caps = append(s.caps, strings.Fields(msg.param(len(msg.params)-1))...)
+		if more {
+			return nil
+		}
+
+		var want []string
+		for _, available := range s.caps {
+			name, _, _ := strings.Cut(available, "=")
+			if name == "account-tag" || (name == "sasl" && c.config.GetIRCAuth() == "sasl") {
+				want = append(want, name)
+			}
+		}
+
+		if len(want) == 0 {
+			if c.config.GetIRCAuth() == "sasl" {
+				log.Error().Str("platform", "irc").Msg("server does not support sasl")
+			}
+			return c.writeLine(s.conn, "CAP END")
+		}
+
+		return c.writeLine(s.conn, "CAP REQ :"+strings.Join(want, " "))
+
+	case "ACK":
+		for _, name := range strings.Fields(msg.param(2)) {
+			if name == "sasl" {
+				return c.writeLine(s.conn, "AUTHENTICATE PLAIN")
+			}
+		}
+		return c.writeLine(s.conn, "CAP END")
+
+	case "NAK":
+		log.Warn().Str("platform", "irc").Str("caps", msg.param(2)).Msg("server refused capabilities")
+		return c.writeLine(s.conn, "CAP END")
+	}
+
+	return nil
+}
+
+// authenticate sends the SASL PLAIN credentials, split into the 400 byte
+// chunks AUTHENTICATE allows.
+func (c *IRC) authenticate(s *session) error {
+	account := c.config.GetIRCAccount()
+	payload := base64.StdEncoding.EncodeToString([]byte(account + "\x00" + account + "\x00" + c.config.GetIRCPassword()))
+
+	for len(payload) >= 400 {
+		if err := c.writeLine(s.conn, "AUTHENTICATE "+payload[:400]); err != nil {
+			return err
+		}
+		payload = payload[400:]
+	}
+
+	if payload == "" {
+		payload = "+"
+	}
+
+	return c.writeLine(s.conn, "AUTHENTICATE "+payload)
+}
+
+// welcome finishes registration: identifies with NickServ if configured
+// and joins the configured channels.
+func (c *IRC) welcome(s *session, msg *message) error {
+	s.registered = true
+	s.nick = msg.param(0)
+	c.setNick(s.nick)
+
+	log.Info().
+		Str("platform", "irc").
+		Str("address", s.conn.RemoteAddr().String()).
+		Str("nick", s.nick).
+		Msg("connected to irc server")
+
+	if c.config.GetIRCAuth() == "nickserv" {
+		if err := c.writeLine(s.conn, "PRIVMSG NickServ :IDENTIFY "+c.config.GetIRCAccount()+" "+c.config.GetIRCPassword()); err != nil {
+			return err
+		}
+	}
+
+	if channels := c.config.GetIRCChannels(); len(channels) > 0 {
+		return c.writeLine(s.conn, "JOIN "+strings.Join(channels, ","))
+	}
+
+	return nil
+}
+
+func (c *IRC) privmsg(s *session, msg *message) {
+	target, text := msg.param(0), msg.param(1)
+	author := msg.nick()
+
+	// CTCP requests such as ACTION and VERSION are not commands
+	if author == "" || author == s.nick || strings.HasPrefix(text, "\x01") {
+		return
+	}
+
+	// private messages are answered privately
+	channel := target
+	if !isChannel(target) {
+		channel = author
+	}
+
+	// nicks can be taken by anyone, so only the services account the
+	// server vouches for identifies the author
+	ctx := c.context()
+	message := &models.Message{
+		Content:    text,
+		Author:     author,
+		AuthorID:   msg.tags["account"],
+		Channel:    channel,
+		ID:         msg.tags["msgid"],
+		RecievedAt: time.Now(),
+		Platform:   c.Name(),
+	}
+	c.dispatch.Dispatch(channel, func() {
+		c.handler.HandleMessage(ctx, c, message)
+	})
+}
+
+func (c *IRC) setNick(nick string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nick = nick
+}
+
+func (c *IRC) currentNick() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.nick == "" {
+		return c.config.GetIRCNick()
+	}
+
+	return c.nick
+}
@@ -0,0 +1,52 @@
+package irc
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// lineLimit is the longest line a server accepts, without the CRLF
+	lineLimit = 510
+	// hostReserve is room left for the user@host part of the source the
+	// server adds when relaying our messages, which we do not know exactly
+	hostReserve = 74
+)
+
+// maxText returns how many bytes of text fit in a PRIVMSG from nick to
+// target once the server has added our source to it.
+func maxText(nick, target string) int {
+	overhead := len(":"+nick+"!") + hostReserve + len(" PRIVMSG "+target+" :")
+	return lineLimit - overhead
+}
+
+// splitText breaks text into lines of at most limit bytes. Lines are split
+// on newlines first, then at the last space that fits, and only mid-word
+// when a word is longer than a line. Empty lines are dropped, as IRC cannot
+// send them.
+func splitText(text string, limit int) []string {
+	var lines []string
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \r")
+
+		for len(line) > limit {
+			cut := strings.LastIndexByte(line[:limit+1], ' ')
+			if cut <= 0 {
+				cut = limit
+				for cut > 0 && !utf8.RuneStart(line[cut]) {
+					cut--
+				}
+			}
+
+			lines = append(lines, strings.TrimRight(line[:cut], " "))
+			line = strings.TrimLeft(line[cut:], " ")
+		}
+
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,86 @@
+package matrix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// apiError is the error body the client-server API returns, see
+// https://spec.matrix.org/latest/client-server-api/#standard-error-response
+type apiError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"errcode"`
+	Message string `json:"error"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("matrix: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// request calls the client-server API. body is encoded as JSON unless it is
+// an io.Reader, in which case contentType is used. The JSON response is
+// decoded into out if it is not nil.
+func (m *Matrix) request(ctx context.Context, method, path string, query url.Values, body any, contentType string, out any) error {
+	endpoint := strings.TrimRight(m.config.GetMatrixHomeserver(), "/") + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		reader = b
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+m.config.GetMatrixAccessToken())
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &apiError{Status: resp.StatusCode}
+		_ = json.NewDecoder(resp.Body).Decode(apiErr)
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// clientPath builds a client API path, escaping each segment.
+func clientPath(segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = url.PathEscape(segment)
+	}
+
+	return "/_matrix/client/v3/" + strings.Join(escaped, "/")
+}
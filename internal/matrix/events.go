@@ -0,0 +1,70 @@
+package matrix
+
+import "encoding/json"
+
+// syncResponse is the part of a /sync response the adapter reads.
+type syncResponse struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Timeline struct {
+				Events []event `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+	} `json:"rooms"`
+}
+
+// event is a room event, with content decoded once its type is known.
+type event struct {
+	Type           string          `json:"type"`
+	EventID        string          `json:"event_id"`
+	Sender         string          `json:"sender"`
+	RoomID         string          `json:"room_id"`
+	OriginServerTS int64           `json:"origin_server_ts"`
+	Content        json.RawMessage `json:"content"`
+	// Redacts is the event an m.room.redaction removes, in room versions
+	// before 11
+	Redacts string `json:"redacts,omitempty"`
+}
+
+// messageContent is the content of an m.room.message event.
+type messageContent struct {
+	MsgType       string     `json:"msgtype"`
+	Body          string     `json:"body"`
+	Format        string     `json:"format,omitempty"`
+	FormattedBody string     `json:"formatted_body,omitempty"`
+	URL           string     `json:"url,omitempty"`
+	Info          *imageInfo `json:"info,omitempty"`
+	RelatesTo     *relation  `json:"m.relates_to,omitempty"`
+}
+
+type imageInfo struct {
+	MimeType string `json:"mimetype,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+}
+
+// relation links an event to another, for replies and reactions.
+type relation struct {
+	RelType   string     `json:"rel_type,omitempty"`
+	EventID   string     `json:"event_id,omitempty"`
+	Key       string     `json:"key,omitempty"`
+	InReplyTo *inReplyTo `json:"m.in_reply_to,omitempty"`
+}
+
+type inReplyTo struct {
+	EventID string `json:"event_id"`
+}
+
+// reactionContent is the content of an m.reaction event.
+type reactionContent struct {
+	RelatesTo relation `json:"m.relates_to"`
+}
+
+// redactionContent is the content of an m.room.redaction event. From room
+// version 11 the redacted event is named here instead of on the event.
+type redactionContent struct {
+	Redacts string `json:"redacts"`
+}
+
+// syncFilter keeps /sync responses to the room timelines the adapter reads.
+const syncFilter = `{"presence":{"not_types":["*"]},"account_data":{"not_types":["*"]},"room":{"ephemeral":{"not_types":["*"]},"state":{"lazy_load_members":true},"timeline":{"types":["m.room.message","m.reaction","m.room.redaction"]}}}`
@@ -0,0 +1,125 @@
+package matrix
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/distrobyte/gerry/internal/render"
+	"github.com/rs/zerolog/log"
+)
+
+// Send posts a response to a room as a notice with plain and HTML bodies.
+// Images on disk are uploaded and sent as image events after it.
+func (m *Matrix) Send(channel string, response *models.Response) error {
+	return m.send(channel, response, "")
+}
+
+// Reply responds to a message in its room, as a reply to it. Matrix has no
+// ephemeral messages, so ephemeral responses are refused.
+func (m *Matrix) Reply(message *models.Message, response *models.Response) error {
+	if response.Ephemeral {
+		return platform.ErrNotPrivate
+	}
+
+	return m.send(message.Channel, response, message.ID)
+}
+
+func (m *Matrix) React(message *models.Message, emoji string) error {
+	content := reactionContent{
+		RelatesTo: relation{RelType: "m.annotation", EventID: message.ID, Key: emoji},
+	}
+
+	err := m.sendEvent(message.Channel, "m.reaction", content)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to add reaction")
+	}
+
+	return err
+}
+
+// Typing shows the typing indicator in a room for up to ten seconds.
+func (m *Matrix) Typing(channel string) error {
+	body := map[string]any{"typing": true, "timeout": 10000}
+	return m.request(m.context(), http.MethodPut, clientPath("rooms", channel, "typing", m.user()), nil, body, "", nil)
+}
+
+func (m *Matrix) send(roomID string, response *models.Response, replyTo string) error {
+	var uploads []models.Image
+	text := *response
+	text.Images = nil
+	for _, image := range response.Images {
+		if image.Path != "" {
+			uploads = append(uploads, image)
+			continue
+		}
+		text.Images = append(text.Images, image)
+	}
+
+	if !text.IsEmpty() {
+		content := messageContent{
+			MsgType:       "m.notice",
+			Body:          render.Plain(&text),
+			Format:        "org.matrix.custom.html",
+			FormattedBody: render.HTML(&text, nil),
+		}
+		if replyTo != "" {
+			content.RelatesTo = &relation{InReplyTo: &inReplyTo{EventID: replyTo}}
+		}
+
+		if err := m.sendEvent(roomID, "m.room.message", content); err != nil {
+			log.Error().Err(err).Str("platform", "matrix").Msg("failed to send message")
+			return err
+		}
+	}
+
+	for _, image := range uploads {
+		if err := m.sendImage(roomID, image); err != nil {
+			log.Error().Err(err).Str("platform", "matrix").Str("image", image.Path).Msg("failed to send image")
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Matrix) sendEvent(roomID, eventType string, content any) error {
+	return m.request(m.context(), http.MethodPut, clientPath("rooms", roomID, "send", eventType, m.nextTxnID()), nil, content, "", nil)
+}
+
+// sendImage uploads an image to the media repository and posts it.
+func (m *Matrix) sendImage(roomID string, image models.Image) error {
+	data, err := os.ReadFile(image.Path)
+	if err != nil {
+		return err
+	}
+
+	mimeType := mime.TypeByExtension(filepath.Ext(image.Path))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	name := image.Name
+	if name == "" {
+		name = filepath.Base(image.Path)
+	}
+
+	var upload struct {
+		ContentURI string `json:"content_uri"`
+	}
+	if err := m.request(m.context(), http.MethodPost, "/_matrix/media/v3/upload", url.Values{"filename": {name}}, bytes.NewReader(data), mimeType, &upload); err != nil {
+		return err
+	}
+
+	return m.sendEvent(roomID, "m.room.message", messageContent{
+		MsgType: "m.image",
+		Body:    name,
+		URL:     upload.ContentURI,
+		Info:    &imageInfo{MimeType: mimeType, Size: int64(len(data))},
+	})
+}
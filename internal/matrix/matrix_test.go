@@ -0,0 +1,392 @@
+package matrix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/distrobyte/gerry/internal/testkit/platformtest"
+)
+
+const token = "secret"
+
+// homeserver is a stub of the client-server API endpoints the adapter uses.
+// Timeline events queued with push are returned by the next /sync.
+type homeserver struct {
+	t      *testing.T
+	server *httptest.Server
+	syncs  chan []map[string]any
+
+	mu      sync.Mutex
+	joined  []string
+	sent    []sentEvent
+	uploads int
+	events  map[string]map[string]any
+	// failSyncs is how many of the next incremental syncs fail
+	failSyncs int
+}
+
+type sentEvent struct {
+	Room    string
+	Type    string
+	Content map[string]any
+}
+
+func newHomeserver(t *testing.T) *homeserver {
+	t.Helper()
+
+	hs := &homeserver{
+		t:      t,
+		syncs:  make(chan []map[string]any, 4),
+		events: make(map[string]map[string]any),
+	}
+	hs.server = httptest.NewServer(http.HandlerFunc(hs.serve))
+	t.Cleanup(hs.server.Close)
+
+	return hs
+}
+
+func (hs *homeserver) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+token {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"errcode": "M_UNKNOWN_TOKEN", "error": "bad token"})
+		return
+	}
+
+	path := r.URL.EscapedPath()
+	segments := strings.Split(strings.TrimPrefix(path, "/_matrix/client/v3/"), "/")
+	for i := range segments {
+		segments[i], _ = url.PathUnescape(segments[i])
+	}
+
+	switch {
+	case path == "/_matrix/client/v3/account/whoami":
+		reply(w, map[string]string{"user_id": "@gerry:test"})
+
+	case segments[0] == "join":
+		hs.mu.Lock()
+		hs.joined = append(hs.joined, segments[1])
+		hs.mu.Unlock()
+		reply(w, map[string]string{"room_id": segments[1]})
+
+	case path == "/_matrix/client/v3/sync":
+		hs.sync(w, r)
+
+	case len(segments) == 4 && segments[0] == "rooms" && segments[2] == "event":
+		hs.mu.Lock()
+		ev, ok := hs.events[segments[3]]
+		hs.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		reply(w, ev)
+
+	case len(segments) == 5 && segments[0] == "rooms" && segments[2] == "send":
+		var content map[string]any
+		json.NewDecoder(r.Body).Decode(&content)
+		hs.mu.Lock()
+		hs.sent = append(hs.sent, sentEvent{Room: segments[1], Type: segments[3], Content: content})
+		hs.mu.Unlock()
+		reply(w, map[string]string{"event_id": "$sent"})
+
+	case len(segments) == 4 && segments[0] == "rooms" && segments[2] == "typing":
+		reply(w, map[string]string{})
+
+	case path == "/_matrix/media/v3/upload":
+		io.Copy(io.Discard, r.Body)
+		hs.mu.Lock()
+		hs.uploads++
+		hs.mu.Unlock()
+		reply(w, map[string]string{"content_uri": "mxc://test/graph"})
+
+	default:
+		hs.t.Errorf("unexpected request %s %s", r.Method, path)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// sync returns an old message on the initial sync, then whatever was pushed.
+func (hs *homeserver) sync(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("since") == "" {
+		reply(w, syncBody("s0", []map[string]any{
+			message("$old", "@alice:test", "m.text", ">old"),
+		}))
+		return
+	}
+
+	hs.mu.Lock()
+	fail := hs.failSyncs > 0
+	if fail {
+		hs.failSyncs--
+	}
+	hs.mu.Unlock()
+	if fail {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	select {
+	case events := <-hs.syncs:
+		reply(w, syncBody("s1", events))
+	case <-time.After(50 * time.Millisecond):
+		reply(w, syncBody("s1", nil))
+	case <-r.Context().Done():
+	}
+}
+
+func (hs *homeserver) push(events ...map[string]any) {
+	hs.syncs <- events
+}
+
+// waitSent waits until n events were sent and returns them.
+func (hs *homeserver) waitSent(n int) []sentEvent {
+	hs.t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		hs.mu.Lock()
+		sent := append([]sentEvent(nil), hs.sent...)
+		hs.mu.Unlock()
+
+		if len(sent) >= n {
+			return sent
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	hs.t.Fatalf("homeserver did not receive %d events", n)
+	return nil
+}
+
+func syncBody(batch string, events []map[string]any) map[string]any {
+	return map[string]any{
+		"next_batch": batch,
+		"rooms": map[string]any{
+			"join": map[string]any{
+				"!room:test": map[string]any{
+					"timeline": map[string]any{"events": events},
+				},
+			},
+		},
+	}
+}
+
+func message(id, sender, msgtype, body string) map[string]any {
+	return map[string]any{
+		"type":     "m.room.message",
+		"event_id": id,
+		"sender":   sender,
+		"content":  map[string]any{"msgtype": msgtype, "body": body},
+	}
+}
+
+func reply(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+func connect(t *testing.T, hs *homeserver, h *platformtest.Recorder) *Matrix {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Matrix.Homeserver = hs.server.URL
+	cfg.Matrix.AccessToken = token
+	cfg.Matrix.Rooms = []string{"#gerry:test"}
+
+	m := New(cfg, h)
+
+	if err := m.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Disconnect() })
+
+	return m
+}
+
+func TestMessageAndReply(t *testing.T) {
+	hs := newHomeserver(t)
+	h := platformtest.NewRecorder(t, &models.Response{Title: "Race results", Text: "a < b"})
+	connect(t, hs, h)
+
+	if len(hs.joined) != 1 || hs.joined[0] != "#gerry:test" {
+		t.Errorf("joined %v, want #gerry:test", hs.joined)
+	}
+
+	hs.push(
+		message("$own", "@gerry:test", "m.text", ">own"),
+		message("$notice", "@otherbot:test", "m.notice", ">notice"),
+		message("$ping", "@alice:test", "m.text", ">ping"),
+	)
+
+	got := h.Message()
+	if got.Content != ">ping" || got.Author != "@alice:test" || got.AuthorID != "@alice:test" || got.Channel != "!room:test" || got.ID != "$ping" || got.Platform != "matrix" {
+		t.Errorf("unexpected message %+v", got)
+	}
+
+	sent := hs.waitSent(1)[0]
+	if sent.Room != "!room:test" || sent.Type != "m.room.message" {
+		t.Errorf("sent %s to %s", sent.Type, sent.Room)
+	}
+	if sent.Content["msgtype"] != "m.notice" || sent.Content["body"] != "Race results\na < b" {
+		t.Errorf("unexpected content %v", sent.Content)
+	}
+	if sent.Content["format"] != "org.matrix.custom.html" || sent.Content["formatted_body"] != "<b>Race results</b><br>a &lt; b" {
+		t.Errorf("unexpected html %v", sent.Content)
+	}
+	relates, _ := sent.Content["m.relates_to"].(map[string]any)
+	inReplyTo, _ := relates["m.in_reply_to"].(map[string]any)
+	if inReplyTo["event_id"] != "$ping" {
+		t.Errorf("reply is not related to the message: %v", sent.Content)
+	}
+
+	h.NoMessage(100 * time.Millisecond)
+}
+
+func TestMessageOrder(t *testing.T) {
+	hs := newHomeserver(t)
+	h := platformtest.NewRecorder(t, nil)
+	connect(t, hs, h)
+
+	var events []map[string]any
+	for i := range 20 {
+		events = append(events, message(fmt.Sprintf("$%d", i), "@alice:test", "m.text", fmt.Sprintf("message %d", i)))
+	}
+	hs.push(events...)
+
+	// keyword reactions and the history rely on messages arriving in order
+	for i := range 20 {
+		if got, want := h.Message().Content, fmt.Sprintf("message %d", i); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSyncFailure(t *testing.T) {
+	hs := newHomeserver(t)
+	h := platformtest.NewRecorder(t, nil)
+	m := connect(t, hs, h)
+
+	hs.mu.Lock()
+	hs.failSyncs = 1
+	hs.mu.Unlock()
+
+	// the failed sync is left to the bot to connect again
+	if got := h.State(); got.State != platform.StateDisconnected || got.Err == nil {
+		t.Errorf("got state %s (%v), want disconnected with an error", got.State, got.Err)
+	}
+	if err := m.Connect(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	hs.push(message("$ping", "@alice:test", "m.text", ">ping"))
+	if got := h.Message(); got.ID != "$ping" {
+		t.Errorf("unexpected message %+v", got)
+	}
+}
+
+func TestReaction(t *testing.T) {
+	hs := newHomeserver(t)
+	hs.events["$joke"] = message("$joke", "@bob:test", "m.text", "a joke")
+	h := platformtest.NewRecorder(t, nil)
+	connect(t, hs, h)
+
+	hs.push(map[string]any{
+		"type":     "m.reaction",
+		"event_id": "$reaction",
+		"sender":   "@alice:test",
+		"content": map[string]any{
+			"m.relates_to": map[string]any{"rel_type": "m.annotation", "event_id": "$joke", "key": "😂"},
+		},
+	})
+
+	got := h.Reaction()
+	if got.Emoji != "😂" || got.Message.ID != "$joke" || got.Message.AuthorID != "@bob:test" || got.Message.Content != "a joke" || got.ReactorID != "@alice:test" || got.Removed {
+		t.Errorf("unexpected reaction %+v %+v", got, got.Message)
+	}
+
+	// redactions of anything else are not reactions being removed
+	hs.push(
+		map[string]any{"type": "m.room.redaction", "event_id": "$r1", "sender": "@alice:test", "redacts": "$joke", "content": map[string]any{}},
+		map[string]any{"type": "m.room.redaction", "event_id": "$r2", "sender": "@alice:test", "content": map[string]any{"redacts": "$reaction"}},
+	)
+
+	got = h.Reaction()
+	if got.Emoji != "😂" || got.Message.ID != "$joke" || got.ReactorID != "@alice:test" || !got.Removed {
+		t.Errorf("unexpected reaction %+v %+v", got, got.Message)
+	}
+
+	h.NoReaction(100 * time.Millisecond)
+}
+
+func TestReactAndImage(t *testing.T) {
+	hs := newHomeserver(t)
+	m := connect(t, hs, platformtest.NewRecorder(t, nil))
+
+	if err := m.React(&models.Message{Channel: "!room:test", ID: "$ping"}, "👍"); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "index.png")
+	if err := os.WriteFile(path, []byte("png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := m.Send("!room:test", &models.Response{
+		Title:  "Karting ELO history",
+		Images: []models.Image{{Name: "karting.png", Path: path}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := hs.waitSent(3)
+	if sent[0].Type != "m.reaction" {
+		t.Errorf("sent %s, want m.reaction", sent[0].Type)
+	}
+	if relates, _ := sent[0].Content["m.relates_to"].(map[string]any); relates["key"] != "👍" || relates["event_id"] != "$ping" {
+		t.Errorf("unexpected reaction %v", sent[0].Content)
+	}
+	if sent[1].Content["body"] != "Karting ELO history" {
+		t.Errorf("unexpected message %v", sent[1].Content)
+	}
+	if sent[2].Content["msgtype"] != "m.image" || sent[2].Content["url"] != "mxc://test/graph" || hs.uploads != 1 {
+		t.Errorf("unexpected image %v", sent[2].Content)
+	}
+}
+
+func TestBadToken(t *testing.T) {
+	hs := newHomeserver(t)
+
+	cfg := &config.Config{}
+	cfg.Matrix.Homeserver = hs.server.URL
+	cfg.Matrix.AccessToken = "wrong"
+
+	err := New(cfg, platformtest.NewRecorder(t, nil)).Connect(context.Background())
+	if apiErr, ok := err.(*apiError); !ok || apiErr.Code != "M_UNKNOWN_TOKEN" {
+		t.Errorf("Connect() = %v, want M_UNKNOWN_TOKEN", err)
+	}
+}
+
+func TestEphemeralRefused(t *testing.T) {
+	m := &Matrix{}
+
+	err := m.Reply(&models.Message{Channel: "!room:example.org", ID: "$ping"}, &models.Response{Text: "only you", Ephemeral: true})
+	if !errors.Is(err, platform.ErrNotPrivate) {
+		t.Errorf("ephemeral reply got %v, expected %v", err, platform.ErrNotPrivate)
+	}
+}
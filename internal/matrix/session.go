@@ -0,0 +1,356 @@
+package matrix
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/rs/zerolog/log"
+)
+
+func init() {
+	platform.Register(platform.Driver{
+		Name:    "matrix",
+		Enabled: (*config.Config).IsMatrixEnabled,
+		New: func(cfg *config.Config, handler platform.Handler) platform.Platform {
+			return New(cfg, handler)
+		},
+	})
+}
+
+// reactionsKept is how many reactions are remembered, so they can be
+// passed on again when they are redacted
+const reactionsKept = 1000
+
+// how long the homeserver may hold a /sync request open waiting for events
+const syncTimeout = 30 * time.Second
+
+// Matrix is the platform adapter for a Matrix homeserver, using the
+// client-server API with an access token. A failed sync is reported to the
+// handler as a lost connection, and the handler calls Connect again.
+type Matrix struct {
+	config  *config.Config
+	handler platform.Handler
+	client  *http.Client
+
+	txnID  atomic.Int64
+	txnRun int64
+
+	// mu guards the session started by the last Connect
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	userID string
+
+	reactionsMu   sync.Mutex
+	reactions     map[string]models.MessageReaction
+	reactionOrder []string
+
+	// dispatch hands events to the handler in order, room by room
+	dispatch platform.Dispatcher
+}
+
+func New(cfg *config.Config, handler platform.Handler) *Matrix {
+	return &Matrix{
+		config:    cfg,
+		handler:   handler,
+		reactions: make(map[string]models.MessageReaction),
+		client:    &http.Client{Timeout: syncTimeout + 30*time.Second},
+		txnRun:    time.Now().UnixNano(),
+	}
+}
+
+func (m *Matrix) Name() string {
+	return "matrix"
+}
+
+// Connect checks the access token, joins the configured rooms and starts
+// syncing in the background. Events from before Connect are skipped. It is
+// called again to reconnect once a sync failed.
+func (m *Matrix) Connect(ctx context.Context) error {
+	if m.config.GetMatrixHomeserver() == "" {
+		return fmt.Errorf("matrix homeserver is not configured")
+	}
+
+	var whoami struct {
+		UserID string `json:"user_id"`
+	}
+	if err := m.request(ctx, http.MethodGet, clientPath("account", "whoami"), nil, nil, "", &whoami); err != nil {
+		log.Error().Err(err).Msg("failed to log in to matrix")
+		return err
+	}
+
+	for _, room := range m.config.GetMatrixRooms() {
+		if err := m.request(ctx, http.MethodPost, clientPath("join", room), nil, struct{}{}, "", nil); err != nil {
+			log.Error().Err(err).Str("room", room).Msg("failed to join matrix room")
+		}
+	}
+
+	// the first sync only finds out where the timeline is, so commands
+	// sent while the bot was away are not run now
+	initial, err := m.sync(ctx, "", 0)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to sync with matrix homeserver")
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	m.mu.Lock()
+	if m.cancel != nil {
+		// the session of the connection lost, its sync has returned
+		m.cancel()
+	}
+	m.ctx, m.cancel, m.done = ctx, cancel, done
+	m.userID = whoami.UserID
+	m.mu.Unlock()
+
+	go m.run(ctx, initial.NextBatch, done)
+
+	log.Info().
+		Str("homeserver", m.config.GetMatrixHomeserver()).
+		Str("user_id", whoami.UserID).
+		Msg("connected to matrix")
+
+	return nil
+}
+
+func (m *Matrix) Disconnect() error {
+	m.mu.Lock()
+	cancel, done := m.cancel, m.done
+	m.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+	<-done
+
+	return nil
+}
+
+// context returns the context of the current session, cancelled by
+// Disconnect.
+func (m *Matrix) context() context.Context {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.ctx
+}
+
+// user returns the user ID the access token belongs to.
+func (m *Matrix) user() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.userID
+}
+
+func (m *Matrix) sync(ctx context.Context, since string, timeout time.Duration) (*syncResponse, error) {
+	query := url.Values{
+		"filter":  {syncFilter},
+		"timeout": {strconv.FormatInt(timeout.Milliseconds(), 10)},
+	}
+	if since != "" {
+		query.Set("since", since)
+	}
+
+	var resp syncResponse
+	if err := m.request(ctx, http.MethodGet, clientPath("sync"), query, nil, "", &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// run long-polls /sync until ctx is done, and reports the lost connection
+// when a sync fails.
+func (m *Matrix) run(ctx context.Context, since string, done chan struct{}) {
+	defer close(done)
+
+	for {
+		resp, err := m.sync(ctx, since, syncTimeout)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			log.Warn().Err(err).Str("platform", "matrix").Msg("matrix sync failed")
+			m.handler.HandleState(m, platform.StateDisconnected, err)
+			return
+		}
+
+		since = resp.NextBatch
+
+		for roomID, room := range resp.Rooms.Join {
+			for _, ev := range room.Timeline.Events {
+				ev.RoomID = roomID
+				m.handleEvent(ctx, ev)
+			}
+		}
+	}
+}
+
+func (m *Matrix) handleEvent(ctx context.Context, ev event) {
+	if ev.Sender == m.user() {
+		return
+	}
+
+	switch ev.Type {
+	case "m.room.message":
+		message, ok := m.message(ev)
+		if !ok {
+			return
+		}
+
+		m.dispatch.Dispatch(ev.RoomID, func() {
+			m.handler.HandleMessage(ctx, m, message)
+		})
+
+	case "m.reaction":
+		var content reactionContent
+		if err := json.Unmarshal(ev.Content, &content); err != nil || content.RelatesTo.RelType != "m.annotation" {
+			return
+		}
+
+		log.Info().
+			Str("platform", "matrix").
+			Str("event", "reaction").
+			Str("message_id", content.RelatesTo.EventID).
+			Str("user_id", ev.Sender).
+			Str("emoji", content.RelatesTo.Key).
+			Msg("reaction added")
+
+		m.dispatch.Dispatch(ev.RoomID, func() {
+			m.handleReaction(ctx, ev, content.RelatesTo)
+		})
+
+	case "m.room.redaction":
+		var content redactionContent
+		_ = json.Unmarshal(ev.Content, &content)
+
+		redacts := ev.Redacts
+		if redacts == "" {
+			redacts = content.Redacts
+		}
+
+		// reactions are taken back by redacting them, after the reaction
+		// was remembered as it is handled in order
+		m.dispatch.Dispatch(ev.RoomID, func() {
+			reaction, ok := m.forgetReaction(redacts)
+			if !ok {
+				return
+			}
+			reaction.Removed = true
+
+			log.Info().
+				Str("platform", "matrix").
+				Str("event", "reaction").
+				Str("message_id", reaction.Message.ID).
+				Str("user_id", reaction.ReactorID).
+				Str("emoji", reaction.Emoji).
+				Msg("reaction removed")
+
+			m.handler.HandleReaction(ctx, m, reaction)
+		})
+	}
+}
+
+// message converts a text message event. Notices are skipped, as bots send
+// them and answering would risk loops.
+func (m *Matrix) message(ev event) (*models.Message, bool) {
+	var content messageContent
+	if err := json.Unmarshal(ev.Content, &content); err != nil || content.MsgType != "m.text" {
+		return nil, false
+	}
+
+	return &models.Message{
+		Content:    content.Body,
+		Author:     ev.Sender,
+		AuthorID:   ev.Sender,
+		Channel:    ev.RoomID,
+		ID:         ev.EventID,
+		RecievedAt: time.Now(),
+		Platform:   m.Name(),
+	}, true
+}
+
+// handleReaction fetches the message reacted to and passes the reaction on.
+// The reaction is remembered, so it can be passed on again if it is
+// redacted.
+func (m *Matrix) handleReaction(ctx context.Context, ev event, relation relation) {
+	roomID := ev.RoomID
+
+	var target event
+	if err := m.request(ctx, http.MethodGet, clientPath("rooms", roomID, "event", relation.EventID), nil, nil, "", &target); err != nil {
+		log.Error().Err(err).Str("platform", "matrix").Msg("failed to get message")
+		return
+	}
+	target.RoomID = roomID
+
+	var content messageContent
+	_ = json.Unmarshal(target.Content, &content)
+
+	reaction := &models.MessageReaction{
+		Message: &models.Message{
+			Content:    content.Body,
+			Author:     target.Sender,
+			AuthorID:   target.Sender,
+			Channel:    roomID,
+			ID:         target.EventID,
+			RecievedAt: time.Now(),
+			Platform:   m.Name(),
+		},
+		Emoji:     relation.Key,
+		Reactor:   ev.Sender,
+		ReactorID: ev.Sender,
+	}
+	m.rememberReaction(ev.EventID, reaction)
+
+	m.handler.HandleReaction(ctx, m, reaction)
+}
+
+func (m *Matrix) rememberReaction(eventID string, reaction *models.MessageReaction) {
+	m.reactionsMu.Lock()
+	defer m.reactionsMu.Unlock()
+
+	m.reactions[eventID] = *reaction
+	m.reactionOrder = append(m.reactionOrder, eventID)
+
+	if len(m.reactionOrder) > reactionsKept {
+		delete(m.reactions, m.reactionOrder[0])
+		m.reactionOrder = m.reactionOrder[1:]
+	}
+}
+
+// forgetReaction returns a copy of the reaction sent as eventID, if it is
+// remembered.
+func (m *Matrix) forgetReaction(eventID string) (*models.MessageReaction, bool) {
+	m.reactionsMu.Lock()
+	defer m.reactionsMu.Unlock()
+
+	reaction, ok := m.reactions[eventID]
+	if !ok {
+		return nil, false
+	}
+	delete(m.reactions, eventID)
+
+	return &reaction, true
+}
+
+// nextTxnID returns a transaction ID unique to this run of the bot, so the
+// homeserver can drop retried sends.
+func (m *Matrix) nextTxnID() string {
+	return fmt.Sprintf("gerry.%d.%d", m.txnRun, m.txnID.Add(1))
+}
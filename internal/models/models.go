@@ -3,15 +3,28 @@ package models
 import "time"
 
 type Message struct {
-	Content    string
-	Author     string
-	Channel    string
-	ID         string
-	Platform   string
-	RecievedAt time.Time
+	Content string
+	Author  string
+	// AuthorID is the platform ID of the author, empty if the platform has
+	// no stable ID for them
+	AuthorID string
+	// AuthorRoles are the platform roles or groups the author belongs to
+	AuthorRoles []string
+	Channel     string
+	ID          string
+	Platform    string
+	RecievedAt  time.Time
 }
 
+// MessageReaction is a reaction being added to or removed from a message.
 type MessageReaction struct {
-	Message  *Message
-	Reaction string
+	// Message is the message reacted to. Its author is who the reaction is
+	// aimed at; the content is empty if the platform could not fetch it
+	Message *Message
+	// Emoji is the emoji as the platform names it, e.g. "😂" or "joy"
+	Emoji     string
+	Reactor   string
+	ReactorID string
+	// Removed is set when the reaction was taken back
+	Removed bool
 }
@@ -0,0 +1,98 @@
+package models
+
+// Response is what a command replies with. It describes the content, and
+// each platform renders it in its native format.
+type Response struct {
+	Title string
+	Text  string
+	// Markdown marks Text as discord flavoured markdown, converted to the
+	// markup of each platform. Text is plain otherwise.
+	Markdown bool
+	Tables   []Table
+	Code     []CodeBlock
+	Images   []Image
+	// Menu and Buttons are shown on platforms with interactive components
+	// and ignored elsewhere, so the text should not depend on them
+	Menu    *Menu
+	Buttons []Button
+	// Ephemeral responses are only shown to the user who ran the command,
+	// on platforms that support it
+	Ephemeral bool
+}
+
+// Table is a grid of text cells with a header row.
+type Table struct {
+	Columns []Column
+	Rows    [][]string
+}
+
+// Column is a table header. AlignRight is used for numeric columns.
+type Column struct {
+	Name       string
+	AlignRight bool
+}
+
+// CodeBlock is preformatted text shown in a monospace font.
+type CodeBlock struct {
+	Language string
+	Content  string
+}
+
+// Image is a picture attached to a response. Path points to the file on
+// disk, URL to where it is publicly served, if anywhere.
+type Image struct {
+	Name string
+	Path string
+	URL  string
+}
+
+// Menu lets the user pick one of a list of options. Picking one runs
+// Command with the option appended as the user who picked it, and the
+// response replaces the message the menu was on.
+type Menu struct {
+	Placeholder string
+	Options     []string
+	// Command is an invocation without the prefix, e.g. "karting enter"
+	Command string
+}
+
+// Button runs Command as the user who clicked it, and the response
+// replaces the message the button was on. A button without a command
+// dismisses the message.
+type Button struct {
+	Label string
+	Style ButtonStyle
+	// Command is an invocation without the prefix, e.g. "karting race
+	// alice bob"
+	Command string
+	// Prompt, if set, asks the user for a value that is appended to
+	// Command
+	Prompt string
+}
+
+// ButtonStyle is how prominent a button is.
+type ButtonStyle int
+
+const (
+	ButtonSecondary ButtonStyle = iota
+	ButtonPrimary
+	ButtonDanger
+)
+
+// NewTextResponse returns a response containing only text.
+func NewTextResponse(text string) *Response {
+	return &Response{Text: text}
+}
+
+// IsEmpty reports whether the response has nothing to send.
+func (r *Response) IsEmpty() bool {
+	return r == nil || (r.Title == "" && r.Text == "" && len(r.Tables) == 0 && len(r.Code) == 0 && len(r.Images) == 0 &&
+		r.Menu == nil && len(r.Buttons) == 0)
+}
+
+// IsPlainText reports whether the response is text only and can be sent
+// as a regular message without any rich formatting.
+func (r *Response) IsPlainText() bool {
+	return r.Title == "" && len(r.Tables) == 0 && len(r.Code) == 0 && len(r.Images) == 0 &&
+		r.Menu == nil && len(r.Buttons) == 0
+}
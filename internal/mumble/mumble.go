@@ -1,22 +1,101 @@
 package mumble
 
 import (
-	"strings"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"mime"
+	"os"
+	"path/filepath"
+	"strconv"
 
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/distrobyte/gerry/internal/render"
 	"github.com/rs/zerolog/log"
 )
 
-func SendMessage(channelID uint32, message string) {
+// murmur rejects messages carrying images larger than this by default
+// (imagemessagelength in murmur.ini)
+const maxImageMessageLength = 128 * 1024
 
-	channel := MumbleSession.Channels[channelID]
+func (m *Mumble) Send(channelID string, response *models.Response) error {
+	id, err := strconv.ParseUint(channelID, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid mumble channel id %q: %w", channelID, err)
+	}
+
+	client := m.session()
+	if client == nil {
+		return fmt.Errorf("mumble is not connected")
+	}
+
+	channel := client.Channels[uint32(id)]
 	if channel == nil {
 		log.Warn().Str("platform", "mumble").Msg("channel not found")
-		return
+		return fmt.Errorf("mumble channel %d not found", id)
 	}
 
-	if strings.Contains(message, "\n") {
-		message = strings.ReplaceAll(message, "\n", "<br>")
+	channel.Send(renderHTML(response), false)
+	return nil
+}
+
+// Reply responds in the channel the message came from. Ephemeral responses
+// are sent to the author as a private message, if they are still online.
+// Names can be taken by anyone, so the author is found by their user ID or
+// certificate, and users with neither cannot be replied to privately.
+func (m *Mumble) Reply(message *models.Message, response *models.Response) error {
+	if response.Ephemeral {
+		client := m.session()
+		if client == nil {
+			return fmt.Errorf("mumble is not connected")
+		}
+
+		if message.AuthorID == "" {
+			return platform.ErrNotPrivate
+		}
+
+		sent := false
+		for _, user := range client.Users {
+			if authorID(user) == message.AuthorID {
+				user.Send(renderHTML(response))
+				sent = true
+			}
+		}
+		if !sent {
+			return platform.ErrNotPrivate
+		}
+
+		return nil
 	}
 
-	channel.Send(message, false)
+	return m.Send(message.Channel, response)
+}
+
+func (m *Mumble) React(message *models.Message, emoji string) error {
+	return platform.ErrNotSupported
+}
+
+// renderHTML renders a response as mumble HTML, inlining images as data
+// URIs since clients do not load remote images.
+func renderHTML(response *models.Response) string {
+	return render.HTML(response, func(image models.Image) string {
+		if image.Path != "" {
+			data, err := os.ReadFile(image.Path)
+			if err == nil && base64.StdEncoding.EncodedLen(len(data)) < maxImageMessageLength {
+				return fmt.Sprintf("<br><img src=\"data:%s;base64,%s\">",
+					mime.TypeByExtension(filepath.Ext(image.Path)), base64.StdEncoding.EncodeToString(data))
+			}
+
+			if err != nil {
+				log.Warn().Err(err).Str("file", image.Path).Msg("failed to read image")
+			}
+		}
+
+		if image.URL != "" {
+			return fmt.Sprintf("<br><a href=\"%s\">%s</a>", html.EscapeString(image.URL), html.EscapeString(image.Name))
+		}
+
+		return ""
+	})
 }
@@ -1,62 +1,209 @@
 package mumble
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"net"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/distrobyte/gerry/internal/config"
-	"github.com/distrobyte/gerry/internal/handlers"
 	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
 	"github.com/rs/zerolog/log"
 	"layeh.com/gumble/gumble"
+	"layeh.com/gumble/gumbleutil"
 )
 
-var MumbleSession *gumble.Client
-var MumbleConfig *gumble.Config
+// dialTimeout bounds connecting to the server, so a reconnect attempt
+// against a server that is down does not hang
+const dialTimeout = 30 * time.Second
 
-func InitSession() {
-	if config.GetMumbleHost() != "" {
-		var tlsConfig tls.Config
-		if !config.GetMumbleTLS() {
-			tlsConfig.InsecureSkipVerify = true
-		}
+func init() {
+	platform.Register(platform.Driver{
+		Name:    "mumble",
+		Enabled: (*config.Config).IsMumbleEnabled,
+		New: func(cfg *config.Config, handler platform.Handler) platform.Platform {
+			return New(cfg, handler)
+		},
+	})
+}
 
-		MumbleConfig = gumble.NewConfig()
-		MumbleConfig.Username = config.GetMumbleUsername()
+// Mumble is the platform adapter for a mumble server connection. A lost
+// connection is reported to the handler, which calls Connect again.
+type Mumble struct {
+	ctx     context.Context
+	config  *config.Config
+	handler platform.Handler
 
-		var err error
-		MumbleSession, err = gumble.DialWithDialer(new(net.Dialer),
-			fmt.Sprintf("%s:%v", config.GetMumbleHost(), config.GetMumblePort()),
-			MumbleConfig,
-			&tlsConfig)
-		if err != nil {
-			log.Fatal().Err(err).Msg("failed to create mumble session")
-		}
+	mu     sync.Mutex
+	client *gumble.Client
+	// closing is set by Disconnect, so the disconnect it causes is not
+	// reported as a lost connection
+	closing bool
+
+	// groups maps registered user IDs to the ACL groups of the root channel
+	// they are in, used for permission checks
+	groupsMu sync.RWMutex
+	groups   map[uint32][]string
+}
+
+func New(cfg *config.Config, handler platform.Handler) *Mumble {
+	return &Mumble{config: cfg, handler: handler}
+}
 
-		log.Info().
-			Str("host", config.GetMumbleHost()).
-			Int("port", config.GetMumblePort()).
-			Msg("mumble session created")
+func (m *Mumble) Name() string {
+	return "mumble"
+}
+
+// Connect dials the server with the listeners attached. It is called
+// again to reconnect once the connection was lost.
+func (m *Mumble) Connect(ctx context.Context) error {
+	m.ctx = ctx
+
+	if m.config.GetMumbleHost() == "" {
+		return fmt.Errorf("mumble host is not configured")
 	}
+
+	var tlsConfig tls.Config
+	if !m.config.GetMumbleTLS() {
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	gumbleConfig := gumble.NewConfig()
+	gumbleConfig.Username = m.config.GetMumbleUsername()
+	gumbleConfig.Attach(gumbleutil.Listener{
+		Connect:     m.readyHandler,
+		Disconnect:  m.disconnectHandler,
+		TextMessage: m.messageCreateHandler,
+		ACL:         m.aclHandler,
+	})
+
+	client, err := gumble.DialWithDialer(&net.Dialer{Timeout: dialTimeout},
+		fmt.Sprintf("%s:%v", m.config.GetMumbleHost(), m.config.GetMumblePort()),
+		gumbleConfig,
+		&tlsConfig)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create mumble session")
+		return err
+	}
+
+	m.mu.Lock()
+	m.client = client
+	m.closing = false
+	m.mu.Unlock()
+
+	log.Info().
+		Str("host", m.config.GetMumbleHost()).
+		Int("port", m.config.GetMumblePort()).
+		Msg("mumble session created")
+
+	return nil
+}
+
+func (m *Mumble) Disconnect() error {
+	m.mu.Lock()
+	m.closing = true
+	client := m.client
+	m.mu.Unlock()
+
+	if client == nil {
+		return nil
+	}
+
+	return client.Disconnect()
+}
+
+// session returns the client of the current connection.
+func (m *Mumble) session() *gumble.Client {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.client
 }
 
-func ReadyHandler(event *gumble.ConnectEvent) {
+func (m *Mumble) readyHandler(event *gumble.ConnectEvent) {
 	log.Info().
 		Str("address", event.Client.Conn.RemoteAddr().String()).
 		Msg("connected to mumble server")
+
+	// the server only answers if the bot may edit the root channel ACL,
+	// without that ACL groups cannot be used for permissions
+	if root := event.Client.Channels[0]; root != nil {
+		root.RequestACL()
+	}
+}
+
+func (m *Mumble) aclHandler(event *gumble.ACLEvent) {
+	if event.ACL.Channel == nil || !event.ACL.Channel.IsRoot() {
+		return
+	}
+
+	groups := make(map[uint32][]string)
+	for _, group := range event.ACL.Groups {
+		for userID := range group.UsersAdd {
+			groups[userID] = append(groups[userID], group.Name)
+		}
+		for userID := range group.UsersInherited {
+			if _, removed := group.UsersRemove[userID]; !removed {
+				groups[userID] = append(groups[userID], group.Name)
+			}
+		}
+	}
+
+	m.groupsMu.Lock()
+	m.groups = groups
+	m.groupsMu.Unlock()
+
+	log.Debug().Int("users", len(groups)).Msg("loaded mumble ACL groups")
+}
+
+func (m *Mumble) userGroups(userID uint32) []string {
+	m.groupsMu.RLock()
+	defer m.groupsMu.RUnlock()
+
+	return m.groups[userID]
 }
 
-func DisconnectHandler(event *gumble.DisconnectEvent) {
-	log.Warn().Msg("disconnected from mumble server, retrying connection...")
+func (m *Mumble) disconnectHandler(event *gumble.DisconnectEvent) {
+	m.mu.Lock()
+	closing := m.closing
+	m.mu.Unlock()
 
-	InitSession()
+	if closing || event.Type == gumble.DisconnectUser {
+		return
+	}
+
+	err := fmt.Errorf("disconnected from mumble server: %s", disconnectReason(event))
+	log.Warn().Err(err).Str("platform", "mumble").Msg("mumble connection lost")
+	m.handler.HandleState(m, platform.StateDisconnected, err)
 }
 
-func MessageCreateHandler(event *gumble.TextMessageEvent) {
+func disconnectReason(event *gumble.DisconnectEvent) string {
+	switch event.Type {
+	case gumble.DisconnectKicked:
+		return "kicked: " + event.String
+	case gumble.DisconnectBanned:
+		return "banned: " + event.String
+	default:
+		return "connection error"
+	}
+}
+
+// authorID identifies a user: registered users by their user ID, other
+// users by the hash of their certificate, or "" if they have none.
+func authorID(user *gumble.User) string {
+	if user.IsRegistered() {
+		return strconv.FormatUint(uint64(user.UserID), 10)
+	}
+
+	return user.Hash
+}
+
+func (m *Mumble) messageCreateHandler(event *gumble.TextMessageEvent) {
 	if event.Sender == nil || event.Sender.Name == "" {
 		return
 	}
@@ -71,17 +218,20 @@ func MessageCreateHandler(event *gumble.TextMessageEvent) {
 		event.Message = strings.ReplaceAll(event.Message, v, k)
 	}
 
-	message := &models.Message{
-		Content:  event.Message,
-		Author:   event.Sender.Name,
-		Channel:  strconv.FormatUint(uint64(event.Sender.Channel.ID), 10),
-		ID:       strconv.FormatInt(time.Now().UnixNano(), 10),
-		Platform: "mumble",
+	// registered users can be in ACL groups
+	var groups []string
+	if event.Sender.IsRegistered() {
+		groups = m.userGroups(event.Sender.UserID)
 	}
 
-	response, err := handlers.HandleMessage(message)
-	if err == nil && response != "" {
-		channelIDInt, _ := strconv.ParseInt(message.Channel, 10, 32)
-		SendMessage(uint32(channelIDInt), response)
-	}
+	m.handler.HandleMessage(m.ctx, m, &models.Message{
+		Content:     event.Message,
+		Author:      event.Sender.Name,
+		AuthorID:    authorID(event.Sender),
+		AuthorRoles: groups,
+		Channel:     strconv.FormatUint(uint64(event.Sender.Channel.ID), 10),
+		ID:          strconv.FormatInt(time.Now().UnixNano(), 10),
+		RecievedAt:  time.Now(),
+		Platform:    m.Name(),
+	})
 }
@@ -0,0 +1,45 @@
+package platform
+
+import "sync"
+
+// Dispatcher runs the events of a platform one channel at a time, in the
+// order they arrived, without holding up the connection they are read
+// from. Features such as keyword reactions and the message history depend
+// on that order. The zero value is ready to use.
+type Dispatcher struct {
+	mu     sync.Mutex
+	queues map[string][]func()
+}
+
+// Dispatch queues fn behind the events of channel that have not run yet.
+func (d *Dispatcher) Dispatch(channel string, fn func()) {
+	d.mu.Lock()
+	if d.queues == nil {
+		d.queues = make(map[string][]func())
+	}
+	queue, running := d.queues[channel]
+	d.queues[channel] = append(queue, fn)
+	d.mu.Unlock()
+
+	if !running {
+		go d.run(channel)
+	}
+}
+
+// run runs the events of channel until its queue is empty.
+func (d *Dispatcher) run(channel string) {
+	for {
+		d.mu.Lock()
+		queue := d.queues[channel]
+		if len(queue) == 0 {
+			delete(d.queues, channel)
+			d.mu.Unlock()
+			return
+		}
+		fn := queue[0]
+		d.queues[channel] = queue[1:]
+		d.mu.Unlock()
+
+		fn()
+	}
+}
@@ -0,0 +1,56 @@
+package platform
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatcher(t *testing.T) {
+	var d Dispatcher
+
+	var mu sync.Mutex
+	got := make(map[string][]int)
+	block := make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(20)
+	for i := range 10 {
+		for _, channel := range []string{"#a", "#b"} {
+			d.Dispatch(channel, func() {
+				defer wg.Done()
+				// a slow event in #a does not hold up #b
+				if channel == "#a" && i == 0 {
+					<-block
+				}
+				mu.Lock()
+				got[channel] = append(got[channel], i)
+				mu.Unlock()
+			})
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(got["#b"])
+		mu.Unlock()
+		if n == 10 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("#b was held up by #a")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(block)
+	wg.Wait()
+
+	for channel, order := range got {
+		for i, n := range order {
+			if n != i {
+				t.Fatalf("%s ran in order %v", channel, order)
+			}
+		}
+	}
+}
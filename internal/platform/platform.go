@@ -0,0 +1,106 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/models"
+)
+
+// ErrNotSupported is returned by adapters for actions their platform has no
+// equivalent for, such as reactions on mumble.
+var ErrNotSupported = errors.New("not supported by platform")
+
+// ErrNotPrivate is returned by Reply for ephemeral responses the platform
+// cannot show to the author alone. They are never posted in the channel
+// instead, as they may hold something meant only for the author.
+var ErrNotPrivate = errors.New("cannot reply privately on this platform")
+
+// Platform is a chat service gerry can connect to.
+type Platform interface {
+	// Name returns the identifier used in models.Message.Platform
+	Name() string
+	// Connect opens the connection. ctx lives as long as the bot runs and
+	// is the parent of the context passed along with every event.
+	Connect(ctx context.Context) error
+	Disconnect() error
+	// Send posts a response to a channel
+	Send(channel string, response *models.Response) error
+	// Reply responds to a message in the channel it came from. Ephemeral
+	// responses go to the author alone, or fail with ErrNotPrivate.
+	Reply(message *models.Message, response *models.Response) error
+	// React adds an emoji reaction to a message
+	React(message *models.Message, emoji string) error
+}
+
+// Typer is implemented by platforms that can show a typing indicator while
+// a command runs.
+type Typer interface {
+	// Typing shows the indicator in channel. It may expire on its own, so
+	// it is sent again for as long as the command runs.
+	Typing(channel string) error
+}
+
+// Webhook is implemented by platforms that receive events over the HTTP
+// endpoint of the bot rather than a connection of their own.
+type Webhook interface {
+	// WebhookPath returns the path the platform is served at, or "" if it
+	// does not need the HTTP endpoint
+	WebhookPath() string
+	http.Handler
+}
+
+// Reloader is implemented by platforms that show config values outside of
+// responses, such as the discord status, so they can be updated when the
+// config is reloaded.
+type Reloader interface {
+	// Reload applies the current config
+	Reload() error
+}
+
+// Handler receives the events a platform produces.
+type Handler interface {
+	HandleMessage(ctx context.Context, p Platform, message *models.Message)
+	HandleReaction(ctx context.Context, p Platform, reaction *models.MessageReaction)
+	// HandleState is told when the connection of a platform changes state
+	// after Connect returned. err is why it was lost.
+	HandleState(p Platform, state State, err error)
+}
+
+// Driver describes a platform adapter that can be enabled in config.
+type Driver struct {
+	Name    string
+	Enabled func(cfg *config.Config) bool
+	New     func(cfg *config.Config, handler Handler) Platform
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   []Driver
+)
+
+// Register makes a platform driver available to the bot. It is meant to be
+// called from the init function of the adapter package.
+func Register(driver Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	for _, d := range drivers {
+		if d.Name == driver.Name {
+			panic("platform: driver " + driver.Name + " registered twice")
+		}
+	}
+
+	drivers = append(drivers, driver)
+}
+
+// Drivers returns every registered platform driver in registration order.
+func Drivers() []Driver {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	return append([]Driver(nil), drivers...)
+}
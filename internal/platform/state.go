@@ -0,0 +1,83 @@
+package platform
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// State is how the connection of a platform is doing.
+type State int
+
+const (
+	// StateConnecting is the state until the first connection succeeds
+	StateConnecting State = iota
+	StateConnected
+	// StateReconnecting means the connection dropped and the platform is
+	// re-establishing it by itself
+	StateReconnecting
+	// StateDisconnected means the connection dropped and the platform
+	// waits for Connect to be called again
+	StateDisconnected
+)
+
+func (s State) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateDisconnected:
+		return "disconnected"
+	default:
+		return "unknown"
+	}
+}
+
+// Status is the last known state of the connection of a platform.
+type Status struct {
+	Platform string
+	State    State
+	// Since is when the platform entered the state
+	Since time.Time
+	// Err is why the connection was lost or could not be re-established
+	Err error
+	// Reconnects counts the connections re-established since startup
+	Reconnects int
+}
+
+// Backoff is an exponential backoff with jitter for reconnect attempts, so
+// platforms that drop at the same time do not retry in lockstep. The zero
+// value waits up to 5 minutes, starting from a second.
+type Backoff struct {
+	Min time.Duration
+	Max time.Duration
+
+	attempt int
+}
+
+// Next returns how long to wait before the next attempt: a random time
+// between half and all of Min doubled for every attempt so far, up to Max.
+func (b *Backoff) Next() time.Duration {
+	lo, hi := b.Min, b.Max
+	if lo <= 0 {
+		lo = time.Second
+	}
+	if hi <= 0 {
+		hi = 5 * time.Minute
+	}
+
+	d := hi
+	if b.attempt < 32 && lo<<b.attempt < hi {
+		d = lo << b.attempt
+		b.attempt++
+	}
+
+	return d/2 + rand.N(d/2+1)
+}
+
+// Reset starts the backoff over, once an attempt succeeded.
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
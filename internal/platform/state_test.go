@@ -0,0 +1,22 @@
+package platform
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Min: time.Second, Max: 10 * time.Second}
+
+	for _, want := range []time.Duration{1, 2, 4, 8, 10, 10} {
+		want *= time.Second
+		if got := b.Next(); got < want/2 || got > want {
+			t.Errorf("got %s, want between %s and %s", got, want/2, want)
+		}
+	}
+
+	b.Reset()
+	if got := b.Next(); got > time.Second {
+		t.Errorf("got %s after reset, want at most 1s", got)
+	}
+}
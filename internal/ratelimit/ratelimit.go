@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// sweep expired cooldowns once this many are being tracked
+const sweepThreshold = 1024
+
+// Limiter enforces keyed cooldowns and a global token bucket.
+type Limiter struct {
+	mu        sync.Mutex
+	cooldowns map[string]time.Time
+	bucket    *Bucket
+	now       func() time.Time
+}
+
+// New returns a limiter whose global bucket refills at rate tokens per
+// second and holds at most burst tokens. A rate of 0 disables the bucket.
+func New(rate float64, burst int) *Limiter {
+	l := &Limiter{
+		cooldowns: make(map[string]time.Time),
+		now:       time.Now,
+	}
+
+	if rate > 0 {
+		l.bucket = NewBucket(rate, burst)
+	}
+
+	return l
+}
+
+// Allow takes a token from the global bucket, reporting whether one was
+// available.
+func (l *Limiter) Allow() bool {
+	l.mu.Lock()
+	bucket := l.bucket
+	l.mu.Unlock()
+
+	if bucket == nil {
+		return true
+	}
+
+	return bucket.Allow()
+}
+
+// SetRate replaces the global bucket with a full one refilling at rate, as
+// New does. Running cooldowns are kept.
+func (l *Limiter) SetRate(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.bucket = nil
+	if rate > 0 {
+		l.bucket = NewBucket(rate, burst)
+	}
+}
+
+// Acquire starts the cooldowns given as key and duration pairs. If any of
+// them is still running, none are started and the longest time left is
+// returned instead.
+func (l *Limiter) Acquire(cooldowns map[string]time.Duration) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	var remaining time.Duration
+	for key, duration := range cooldowns {
+		if duration <= 0 {
+			continue
+		}
+
+		if until, ok := l.cooldowns[key]; ok && until.After(now) {
+			remaining = max(remaining, until.Sub(now))
+		}
+	}
+
+	if remaining > 0 {
+		return remaining, false
+	}
+
+	if len(l.cooldowns) >= sweepThreshold {
+		l.sweep(now)
+	}
+
+	for key, duration := range cooldowns {
+		if duration > 0 {
+			l.cooldowns[key] = now.Add(duration)
+		}
+	}
+
+	return 0, true
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	for key, until := range l.cooldowns {
+		if !until.After(now) {
+			delete(l.cooldowns, key)
+		}
+	}
+}
+
+// Bucket is a token bucket: it holds up to burst tokens and refills at a
+// fixed rate.
+type Bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewBucket returns a full bucket refilling at rate tokens per second.
+func NewBucket(rate float64, burst int) *Bucket {
+	burst = max(burst, 1)
+
+	return &Bucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// Allow takes a token from the bucket, reporting whether one was available.
+func (b *Bucket) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
@@ -0,0 +1,219 @@
+package render
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	codeBlockPattern  = regexp.MustCompile("(?s)```(?:[a-zA-Z0-9+-]*\n)?(.*?)```")
+	inlineCodePattern = regexp.MustCompile("`([^`\n]+)`")
+	linkPattern       = regexp.MustCompile(`\[([^\]\n]+)\]\((https?://[^)\s]+)\)`)
+	boldPattern       = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	underlinePattern  = regexp.MustCompile(`__(.+?)__`)
+	strikePattern     = regexp.MustCompile(`~~(.+?)~~`)
+	italicPattern     = regexp.MustCompile(`\*([^*\s](?:[^*]*[^*\s])?)\*`)
+	underscorePattern = regexp.MustCompile(`\b_([^_\s](?:[^_]*[^_\s])?)_\b`)
+	escapedPattern    = regexp.MustCompile("\\\\([*_~`|\\\\])")
+
+	tagPattern  = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9]*)([^<>]*)>`)
+	hrefPattern = regexp.MustCompile(`href\s*=\s*["']([^"']*)["']`)
+)
+
+// escapedBase moves characters escaped with a backslash into the private
+// use area while the rest of the markdown is converted, so they are not
+// taken for markup
+const escapedBase = 0xE000
+
+// MarkdownHTML converts discord flavoured markdown to an HTML fragment.
+// Bold, italics, underline, strikethrough, code and links are converted,
+// everything else is escaped as text.
+func MarkdownHTML(text string) string {
+	return convertMarkdown(text, func(code string, block bool) string {
+		if block {
+			return "<pre>" + html.EscapeString(code) + "</pre>"
+		}
+		return "<code>" + html.EscapeString(code) + "</code>"
+	}, func(text string) string {
+		text = html.EscapeString(text)
+		text = linkPattern.ReplaceAllString(text, `<a href="$2">$1</a>`)
+		text = boldPattern.ReplaceAllString(text, "<b>$1</b>")
+		text = underlinePattern.ReplaceAllString(text, "<u>$1</u>")
+		text = strikePattern.ReplaceAllString(text, "<s>$1</s>")
+		text = italicPattern.ReplaceAllString(text, "<i>$1</i>")
+		text = underscorePattern.ReplaceAllString(text, "<i>$1</i>")
+		return strings.ReplaceAll(text, "\n", "<br>")
+	})
+}
+
+// StripMarkdown converts discord flavoured markdown to plain text, for
+// platforms without any markup. Links keep their URL.
+func StripMarkdown(text string) string {
+	return convertMarkdown(text, func(code string, block bool) string {
+		return code
+	}, func(text string) string {
+		text = linkPattern.ReplaceAllString(text, "$1 ($2)")
+		for _, pattern := range []*regexp.Regexp{boldPattern, underlinePattern, strikePattern, italicPattern, underscorePattern} {
+			text = pattern.ReplaceAllString(text, "$1")
+		}
+		return text
+	})
+}
+
+// convertMarkdown converts the code in text with code and everything
+// around it with other, keeping backslash escaped characters as they are.
+func convertMarkdown(text string, code func(code string, block bool) string, other func(text string) string) string {
+	text = escapedPattern.ReplaceAllStringFunc(text, func(escaped string) string {
+		return string(rune(escapedBase + int(escaped[1])))
+	})
+
+	var b strings.Builder
+	convertCode := func(text string, pattern *regexp.Regexp, block bool, rest func(string)) {
+		last := 0
+		for _, m := range pattern.FindAllStringSubmatchIndex(text, -1) {
+			rest(text[last:m[0]])
+			b.WriteString(code(unescapeMarkdown(text[m[2]:m[3]], true), block))
+			last = m[1]
+		}
+		rest(text[last:])
+	}
+
+	convertCode(text, codeBlockPattern, true, func(text string) {
+		convertCode(text, inlineCodePattern, false, func(text string) {
+			b.WriteString(unescapeMarkdown(other(text), false))
+		})
+	})
+
+	return b.String()
+}
+
+// unescapeMarkdown puts back the characters escaped with a backslash,
+// with the backslash in code where escapes do not apply.
+func unescapeMarkdown(text string, code bool) string {
+	var b strings.Builder
+	for _, r := range text {
+		if r >= escapedBase && r < escapedBase+128 {
+			if code {
+				b.WriteByte('\\')
+			}
+			r -= escapedBase
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// HTMLMarkdown converts an HTML fragment, as sent by mumble clients, to
+// discord flavoured markdown. Formatting, code, links and line breaks are
+// kept, other tags are dropped and images are replaced by a placeholder.
+func HTMLMarkdown(fragment string) string {
+	// link is an <a> tag being converted, start is where its text begins
+	type link struct {
+		href  string
+		start int
+	}
+
+	var b strings.Builder
+	var links []link
+	code := 0
+
+	writeText := func(text string) {
+		text = html.UnescapeString(text)
+		if code > 0 {
+			b.WriteString(text)
+			return
+		}
+		b.WriteString(EscapeMarkdown(text))
+	}
+
+	last := 0
+	for _, m := range tagPattern.FindAllStringSubmatchIndex(fragment, -1) {
+		writeText(fragment[last:m[0]])
+		last = m[1]
+
+		closing := m[3] > m[2]
+		name := strings.ToLower(fragment[m[4]:m[5]])
+		attrs := fragment[m[6]:m[7]]
+
+		switch name {
+		case "b", "strong":
+			b.WriteString("**")
+		case "i", "em":
+			b.WriteString("*")
+		case "u":
+			b.WriteString("__")
+		case "s", "strike", "del":
+			b.WriteString("~~")
+		case "code", "tt":
+			b.WriteString("`")
+			if closing {
+				code--
+			} else {
+				code++
+			}
+		case "pre":
+			if closing {
+				b.WriteString("\n```")
+				code--
+			} else {
+				b.WriteString("```\n")
+				code++
+			}
+		case "br":
+			b.WriteString("\n")
+		case "p", "div", "li", "tr":
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+				b.WriteString("\n")
+			}
+		case "img":
+			b.WriteString("[image]")
+		case "a":
+			if !closing {
+				href := ""
+				if match := hrefPattern.FindStringSubmatch(attrs); match != nil {
+					href = html.UnescapeString(match[1])
+				}
+				links = append(links, link{href: href, start: b.Len()})
+				continue
+			}
+			if len(links) == 0 {
+				continue
+			}
+
+			open := links[len(links)-1]
+			links = links[:len(links)-1]
+			if open.href == "" {
+				continue
+			}
+
+			// links showing their own URL are left for discord to link
+			text := b.String()[open.start:]
+			converted := b.String()[:open.start] + "[" + text + "](" + open.href + ")"
+			if text == EscapeMarkdown(open.href) || text == open.href {
+				converted = b.String()[:open.start] + open.href
+			}
+			b.Reset()
+			b.WriteString(converted)
+		}
+	}
+	writeText(fragment[last:])
+
+	return strings.TrimSpace(b.String())
+}
+
+// EscapeMarkdown escapes the characters of text that discord would take
+// for markdown.
+func EscapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"~", `\~`,
+	"`", "\\`",
+	"|", `\|`,
+)
@@ -0,0 +1,54 @@
+package render
+
+import "testing"
+
+func TestMarkdownHTML(t *testing.T) {
+	tests := []struct {
+		markdown string
+		html     string
+	}{
+		{"plain & <simple>", "plain &amp; &lt;simple&gt;"},
+		{"**bold** *italic* _also italic_ __underline__ ~~gone~~", "<b>bold</b> <i>italic</i> <i>also italic</i> <u>underline</u> <s>gone</s>"},
+		{"snake_case_name and 2 * 3 * 4", "snake_case_name and 2 * 3 * 4"},
+		{"`**not bold**` and\n```go\nx := 1 < 2\n```", "<code>**not bold**</code> and<br><pre>x := 1 &lt; 2\n</pre>"},
+		{"see [the docs](https://example.com/?a=1&b=2)", `see <a href="https://example.com/?a=1&amp;b=2">the docs</a>`},
+		{`\*not italic\* and \\`, `*not italic* and \`},
+		{"line one\nline two", "line one<br>line two"},
+	}
+
+	for _, tt := range tests {
+		if got := MarkdownHTML(tt.markdown); got != tt.html {
+			t.Errorf("MarkdownHTML(%q) = %q, want %q", tt.markdown, got, tt.html)
+		}
+	}
+}
+
+func TestStripMarkdown(t *testing.T) {
+	got := StripMarkdown("**[discord] alice:** *hi* see [this](https://example.com) `a*b*c` \\*")
+	want := "[discord] alice: hi see this (https://example.com) a*b*c *"
+	if got != want {
+		t.Errorf("StripMarkdown = %q, want %q", got, want)
+	}
+}
+
+func TestHTMLMarkdown(t *testing.T) {
+	tests := []struct {
+		html     string
+		markdown string
+	}{
+		{"plain &amp; simple", "plain & simple"},
+		{"<b>bold</b> <i>italic</i> <u>under</u> <s>gone</s>", "**bold** *italic* __under__ ~~gone~~"},
+		{"2 * 3 = 6_ish", `2 \* 3 = 6\_ish`},
+		{"one<br />two<p>three</p>", "one\ntwo\nthree"},
+		{`<a href="https://example.com/a_b">https://example.com/a_b</a>`, "https://example.com/a_b"},
+		{`<a href="https://example.com">the <b>docs</b></a>`, "[the **docs**](https://example.com)"},
+		{"<code>a*b</code> <pre>x &lt; y</pre>", "`a*b` ```\nx < y\n```"},
+		{`<span style="color:red">red</span><img src="data:image/png;base64,AAAA">`, "red[image]"},
+	}
+
+	for _, tt := range tests {
+		if got := HTMLMarkdown(tt.html); got != tt.markdown {
+			t.Errorf("HTMLMarkdown(%q) = %q, want %q", tt.html, got, tt.markdown)
+		}
+	}
+}
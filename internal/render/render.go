@@ -0,0 +1,200 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/distrobyte/gerry/internal/models"
+)
+
+// Plain renders a response as plain text, for platforms without any markup.
+func Plain(response *models.Response) string {
+	var parts []string
+
+	if response.Title != "" {
+		parts = append(parts, response.Title)
+	}
+
+	if response.Text != "" && response.Markdown {
+		parts = append(parts, StripMarkdown(response.Text))
+	} else if response.Text != "" {
+		parts = append(parts, response.Text)
+	}
+
+	for _, table := range response.Tables {
+		parts = append(parts, Table(table))
+	}
+
+	for _, code := range response.Code {
+		parts = append(parts, code.Content)
+	}
+
+	for _, image := range response.Images {
+		parts = append(parts, imageLink(image))
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// Markdown renders a response as discord flavoured markdown. Tables become
+// code blocks so their columns line up.
+func Markdown(response *models.Response) string {
+	var parts []string
+
+	if response.Title != "" {
+		parts = append(parts, "# "+response.Title)
+	}
+
+	if response.Text != "" {
+		parts = append(parts, response.Text)
+	}
+
+	for _, table := range response.Tables {
+		parts = append(parts, "```\n"+Table(table)+"\n```")
+	}
+
+	for _, code := range response.Code {
+		parts = append(parts, "```"+code.Language+"\n"+code.Content+"\n```")
+	}
+
+	for _, image := range response.Images {
+		if image.URL != "" {
+			parts = append(parts, image.URL)
+		}
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// HTML renders a response as an HTML fragment. image renders each attached
+// image; if nil, images are rendered as links.
+func HTML(response *models.Response, image func(models.Image) string) string {
+	var b strings.Builder
+
+	if response.Title != "" {
+		fmt.Fprintf(&b, "<b>%s</b><br>", html.EscapeString(response.Title))
+	}
+
+	if response.Text != "" && response.Markdown {
+		b.WriteString(MarkdownHTML(response.Text))
+	} else if response.Text != "" {
+		b.WriteString(Escape(response.Text))
+	}
+
+	for _, table := range response.Tables {
+		b.WriteString(HTMLTable(table))
+	}
+
+	for _, code := range response.Code {
+		fmt.Fprintf(&b, "<pre>%s</pre>", html.EscapeString(code.Content))
+	}
+
+	for _, img := range response.Images {
+		if image != nil {
+			b.WriteString(image(img))
+			continue
+		}
+
+		if img.URL != "" {
+			fmt.Fprintf(&b, "<br><a href=\"%s\">%s</a>", html.EscapeString(img.URL), html.EscapeString(img.Name))
+		}
+	}
+
+	return b.String()
+}
+
+// Escape converts plain text to HTML, keeping line breaks.
+func Escape(text string) string {
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")
+}
+
+// HTMLTable renders a table as an HTML <table>.
+func HTMLTable(table models.Table) string {
+	var b strings.Builder
+
+	b.WriteString("<table>")
+	if len(table.Columns) > 0 {
+		b.WriteString("<tr>")
+		for _, column := range table.Columns {
+			fmt.Fprintf(&b, "<th>%s</th>", html.EscapeString(column.Name))
+		}
+		b.WriteString("</tr>")
+	}
+
+	for _, row := range table.Rows {
+		b.WriteString("<tr>")
+		for i, cell := range row {
+			if i < len(table.Columns) && table.Columns[i].AlignRight {
+				fmt.Fprintf(&b, "<td align=\"right\">%s</td>", html.EscapeString(cell))
+				continue
+			}
+			fmt.Fprintf(&b, "<td>%s</td>", html.EscapeString(cell))
+		}
+		b.WriteString("</tr>")
+	}
+	b.WriteString("</table>")
+
+	return b.String()
+}
+
+// Table renders a table as aligned plain text columns separated by pipes.
+func Table(table models.Table) string {
+	widths := make([]int, len(table.Columns))
+	for i, column := range table.Columns {
+		widths[i] = utf8.RuneCountInString(column.Name)
+	}
+
+	for _, row := range table.Rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+
+	var lines []string
+	if len(table.Columns) > 0 {
+		header := make([]string, len(table.Columns))
+		separator := make([]string, len(table.Columns))
+		for i, column := range table.Columns {
+			header[i] = pad(column.Name, widths[i], column.AlignRight)
+			separator[i] = strings.Repeat("-", widths[i])
+		}
+		lines = append(lines, joinRow(header), joinRow(separator))
+	}
+
+	for _, row := range table.Rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			alignRight := i < len(table.Columns) && table.Columns[i].AlignRight
+			cells[i] = pad(cell, widths[i], alignRight)
+		}
+		lines = append(lines, joinRow(cells))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func joinRow(cells []string) string {
+	return strings.TrimRight(strings.Join(cells, " | "), " ")
+}
+
+func pad(text string, width int, alignRight bool) string {
+	padding := strings.Repeat(" ", max(0, width-utf8.RuneCountInString(text)))
+	if alignRight {
+		return padding + text
+	}
+
+	return text + padding
+}
+
+func imageLink(image models.Image) string {
+	if image.URL != "" {
+		return image.URL
+	}
+
+	return image.Name
+}
@@ -0,0 +1,139 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiError is a Web API call Slack refused, see
+// https://api.slack.com/web#evaluating_responses
+type apiError struct {
+	Method string
+	Code   string
+	// RetryAfter is set when the bot is being rate limited
+	RetryAfter time.Duration
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("slack: %s: %s", e.Method, e.Code)
+}
+
+// apiResponse holds the fields every Web API response has.
+type apiResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+func (r *apiResponse) result() *apiResponse {
+	return r
+}
+
+// response is implemented by the result types of Web API methods.
+type response interface {
+	result() *apiResponse
+}
+
+// call invokes a Web API method with params as JSON, authenticated with
+// token, and decodes the response into out.
+func (s *Slack) call(ctx context.Context, token, method string, params any, out response) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.apiURL+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack: %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return &apiError{Method: method, Code: "ratelimited", RetryAfter: time.Duration(seconds) * time.Second}
+	}
+
+	if out == nil {
+		out = &apiResponse{}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("slack: %s: decoding response: %w", method, err)
+	}
+
+	if result := out.result(); !result.OK {
+		return &apiError{Method: method, Code: result.Error}
+	}
+
+	return nil
+}
+
+// uploadFile uploads a file with the external upload flow and shares it in
+// a channel, see https://api.slack.com/messaging/files#uploading_files
+func (s *Slack) uploadFile(ctx context.Context, channel, thread, name, title string, data []byte) error {
+	var upload struct {
+		apiResponse
+		UploadURL string `json:"upload_url"`
+		FileID    string `json:"file_id"`
+	}
+
+	// unlike most methods, this one does not take JSON
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.apiURL+"files.getUploadURLExternal",
+		strings.NewReader(url.Values{"filename": {name}, "length": {strconv.Itoa(len(data))}}.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.config.GetSlackBotToken())
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack: files.getUploadURLExternal: %w", err)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&upload)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if !upload.OK {
+		return &apiError{Method: "files.getUploadURLExternal", Code: upload.Error}
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, upload.UploadURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp, err = s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack: uploading file: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack: uploading file: %s", resp.Status)
+	}
+
+	params := map[string]any{
+		"files":      []map[string]string{{"id": upload.FileID, "title": title}},
+		"channel_id": channel,
+	}
+	if thread != "" {
+		params["thread_ts"] = thread
+	}
+
+	return s.call(ctx, s.config.GetSlackBotToken(), "files.completeUploadExternal", params, nil)
+}
@@ -0,0 +1,417 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+func init() {
+	platform.Register(platform.Driver{
+		Name:    "slack",
+		Enabled: (*config.Config).IsSlackEnabled,
+		New: func(cfg *config.Config, handler platform.Handler) platform.Platform {
+			return New(cfg, handler)
+		},
+	})
+}
+
+// threadsKept is how many message threads are remembered, so replies to
+// messages in a thread stay in it
+const threadsKept = 1000
+
+// Slack is the platform adapter for a Slack workspace. Events arrive over
+// a Socket Mode websocket and responses are sent with the Web API. A
+// connection that cannot be opened again is reported to the handler as
+// lost, and the handler calls Connect again.
+type Slack struct {
+	config  *config.Config
+	handler platform.Handler
+	client  *http.Client
+	apiURL  string
+
+	// sessionMu guards the session started by the last Connect
+	sessionMu sync.Mutex
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      chan struct{}
+	userID    string
+
+	connMu sync.Mutex
+	conn   *websocket.Conn
+
+	mu      sync.Mutex
+	names   map[string]string
+	threads map[string]string
+	order   []string
+
+	// dispatch hands events to the handler in order, channel by channel
+	dispatch platform.Dispatcher
+}
+
+func New(cfg *config.Config, handler platform.Handler) *Slack {
+	return &Slack{
+		config:  cfg,
+		handler: handler,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		apiURL:  "https://slack.com/api/",
+		names:   make(map[string]string),
+		threads: make(map[string]string),
+	}
+}
+
+func (s *Slack) Name() string {
+	return "slack"
+}
+
+// Connect checks the bot token and opens the Socket Mode connection. The
+// connection is refreshed in the background whenever Slack asks or it
+// drops, and Connect is called again once that failed.
+func (s *Slack) Connect(ctx context.Context) error {
+	if s.config.GetSlackAppToken() == "" || s.config.GetSlackBotToken() == "" {
+		return fmt.Errorf("slack app and bot tokens are not configured")
+	}
+
+	var auth struct {
+		apiResponse
+		UserID string `json:"user_id"`
+		Team   string `json:"team"`
+	}
+	if err := s.call(ctx, s.config.GetSlackBotToken(), "auth.test", struct{}{}, &auth); err != nil {
+		log.Error().Err(err).Msg("failed to log in to slack")
+		return err
+	}
+
+	conn, err := s.open(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to open slack socket mode connection")
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	s.sessionMu.Lock()
+	if s.cancel != nil {
+		// the session of the connection lost, its run has returned
+		s.cancel()
+	}
+	s.ctx, s.cancel, s.done = ctx, cancel, done
+	s.userID = auth.UserID
+	s.sessionMu.Unlock()
+
+	go s.run(ctx, conn, done)
+
+	log.Info().Str("team", auth.Team).Str("user_id", auth.UserID).Msg("connected to slack")
+
+	return nil
+}
+
+func (s *Slack) Disconnect() error {
+	s.sessionMu.Lock()
+	cancel, done := s.cancel, s.done
+	s.sessionMu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+
+	s.connMu.Lock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.connMu.Unlock()
+
+	<-done
+	return nil
+}
+
+// context returns the context of the current session, cancelled by
+// Disconnect, or nil before Connect.
+func (s *Slack) context() context.Context {
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+
+	return s.ctx
+}
+
+// user returns the ID of the bot user.
+func (s *Slack) user() string {
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+
+	return s.userID
+}
+
+// open asks for a Socket Mode URL and dials it.
+func (s *Slack) open(ctx context.Context) (*websocket.Conn, error) {
+	var open struct {
+		apiResponse
+		URL string `json:"url"`
+	}
+	if err := s.call(ctx, s.config.GetSlackAppToken(), "apps.connections.open", struct{}{}, &open); err != nil {
+		return nil, err
+	}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, open.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("slack: dialing socket mode: %w", err)
+	}
+
+	return conn, nil
+}
+
+// run reads from conn and opens a new connection whenever it closes, until
+// ctx is done. A connection that cannot be opened again is reported lost.
+func (s *Slack) run(ctx context.Context, conn *websocket.Conn, done chan struct{}) {
+	defer close(done)
+
+	for {
+		err := s.serve(ctx, conn)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// slack asks for refreshes routinely, so the connection is opened
+		// again straight away and only reported if that fails
+		log.Info().Err(err).Str("platform", "slack").Msg("slack connection closed, reconnecting")
+
+		conn, err = s.open(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("platform", "slack").Msg("failed to reconnect to slack")
+			s.handler.HandleState(s, platform.StateDisconnected, err)
+			return
+		}
+	}
+}
+
+// envelope wraps everything Slack sends over Socket Mode, see
+// https://api.slack.com/apis/socket-mode#events
+type envelope struct {
+	Type       string `json:"type"`
+	EnvelopeID string `json:"envelope_id"`
+	Reason     string `json:"reason"`
+	Payload    struct {
+		Event json.RawMessage `json:"event"`
+	} `json:"payload"`
+}
+
+type event struct {
+	Type     string `json:"type"`
+	Subtype  string `json:"subtype"`
+	User     string `json:"user"`
+	BotID    string `json:"bot_id"`
+	Text     string `json:"text"`
+	Channel  string `json:"channel"`
+	TS       string `json:"ts"`
+	ThreadTS string `json:"thread_ts"`
+
+	// reaction_added and reaction_removed
+	Reaction string `json:"reaction"`
+	ItemUser string `json:"item_user"`
+	Item     struct {
+		Type    string `json:"type"`
+		Channel string `json:"channel"`
+		TS      string `json:"ts"`
+	} `json:"item"`
+}
+
+// serve handles the envelopes on conn until it closes or Slack asks for a
+// new connection.
+func (s *Slack) serve(ctx context.Context, conn *websocket.Conn) error {
+	s.connMu.Lock()
+	s.conn = conn
+	s.connMu.Unlock()
+	defer conn.Close()
+
+	for {
+		var env envelope
+		if err := conn.ReadJSON(&env); err != nil {
+			return err
+		}
+
+		// every envelope must be acknowledged, or Slack sends it again
+		if env.EnvelopeID != "" {
+			if err := s.ack(conn, env.EnvelopeID); err != nil {
+				return err
+			}
+		}
+
+		switch env.Type {
+		case "hello":
+			log.Debug().Str("platform", "slack").Msg("slack socket mode connection ready")
+
+		case "disconnect":
+			return fmt.Errorf("slack asked to reconnect: %s", env.Reason)
+
+		case "events_api":
+			var ev event
+			if err := json.Unmarshal(env.Payload.Event, &ev); err != nil {
+				log.Warn().Err(err).Str("platform", "slack").Msg("ignoring malformed event")
+				continue
+			}
+			s.handleEvent(ctx, &ev)
+		}
+	}
+}
+
+func (s *Slack) ack(conn *websocket.Conn, envelopeID string) error {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	return conn.WriteJSON(map[string]string{"envelope_id": envelopeID})
+}
+
+func (s *Slack) handleEvent(ctx context.Context, ev *event) {
+	self := s.user()
+
+	switch ev.Type {
+	case "message":
+		// edits, joins and messages from bots, including ours, have a
+		// subtype or a bot ID
+		if ev.Subtype != "" || ev.BotID != "" || ev.User == "" || ev.User == self {
+			return
+		}
+
+		s.rememberThread(ev.Channel, ev.TS, ev.ThreadTS)
+
+		message := &models.Message{
+			Content:    unescape(ev.Text),
+			Author:     s.userName(ev.User),
+			AuthorID:   ev.User,
+			Channel:    ev.Channel,
+			ID:         ev.TS,
+			RecievedAt: time.Now(),
+			Platform:   s.Name(),
+		}
+		s.dispatch.Dispatch(ev.Channel, func() {
+			s.handler.HandleMessage(ctx, s, message)
+		})
+
+	case "reaction_added", "reaction_removed":
+		if ev.Item.Type != "message" || ev.User == self {
+			return
+		}
+
+		log.Info().
+			Str("platform", "slack").
+			Str("event", "reaction").
+			Str("message_id", ev.Item.TS).
+			Str("user_id", ev.User).
+			Str("emoji", ev.Reaction).
+			Bool("removed", ev.Type == "reaction_removed").
+			Msg("reaction")
+
+		reaction := &models.MessageReaction{
+			Message: &models.Message{
+				Author:     s.userName(ev.ItemUser),
+				AuthorID:   ev.ItemUser,
+				Channel:    ev.Item.Channel,
+				ID:         ev.Item.TS,
+				RecievedAt: time.Now(),
+				Platform:   s.Name(),
+			},
+			Emoji:     ev.Reaction,
+			Reactor:   s.userName(ev.User),
+			ReactorID: ev.User,
+			Removed:   ev.Type == "reaction_removed",
+		}
+		s.dispatch.Dispatch(ev.Item.Channel, func() {
+			s.handler.HandleReaction(ctx, s, reaction)
+		})
+	}
+}
+
+// unescape undoes the escaping Slack applies to message text, see
+// https://api.slack.com/reference/surfaces/formatting#escaping
+func unescape(text string) string {
+	return html.UnescapeString(text)
+}
+
+// userName returns the display name of a user, looking it up once and
+// falling back to the ID.
+func (s *Slack) userName(id string) string {
+	if id == "" {
+		return ""
+	}
+
+	s.mu.Lock()
+	name, ok := s.names[id]
+	s.mu.Unlock()
+	if ok {
+		return name
+	}
+
+	var info struct {
+		apiResponse
+		User struct {
+			Name    string `json:"name"`
+			Profile struct {
+				DisplayName string `json:"display_name"`
+				RealName    string `json:"real_name"`
+			} `json:"profile"`
+		} `json:"user"`
+	}
+
+	name = id
+	if err := s.call(s.context(), s.config.GetSlackBotToken(), "users.info", map[string]string{"user": id}, &info); err != nil {
+		log.Warn().Err(err).Str("platform", "slack").Str("user_id", id).Msg("failed to look up slack user")
+	} else {
+		switch {
+		case info.User.Profile.DisplayName != "":
+			name = info.User.Profile.DisplayName
+		case info.User.Profile.RealName != "":
+			name = info.User.Profile.RealName
+		case info.User.Name != "":
+			name = info.User.Name
+		}
+	}
+
+	s.mu.Lock()
+	s.names[id] = name
+	s.mu.Unlock()
+
+	return name
+}
+
+// rememberThread records the thread a message was sent in, if any.
+func (s *Slack) rememberThread(channel, ts, thread string) {
+	if thread == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := channel + "/" + ts
+	s.threads[key] = thread
+	s.order = append(s.order, key)
+
+	if len(s.order) > threadsKept {
+		delete(s.threads, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// thread returns the thread a message was sent in, or "" if it was sent
+// in the channel.
+func (s *Slack) thread(channel, ts string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.threads[channel+"/"+ts]
+}
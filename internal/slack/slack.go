@@ -0,0 +1,232 @@
+package slack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/distrobyte/gerry/internal/render"
+	"github.com/rs/zerolog/log"
+)
+
+// Send posts a response to a channel as Block Kit blocks.
+func (s *Slack) Send(channel string, response *models.Response) error {
+	return s.send(channel, "", "", response)
+}
+
+// Reply responds to a message in its channel, in the thread it was sent
+// in if any. Ephemeral responses are only shown to the author.
+func (s *Slack) Reply(message *models.Message, response *models.Response) error {
+	thread := s.thread(message.Channel, message.ID)
+
+	if response.Ephemeral {
+		if message.AuthorID == "" {
+			return platform.ErrNotPrivate
+		}
+		return s.send(message.Channel, thread, message.AuthorID, response)
+	}
+
+	return s.send(message.Channel, thread, "", response)
+}
+
+// React adds a reaction to a message. emoji is the name of a Slack emoji,
+// with or without colons.
+func (s *Slack) React(message *models.Message, emoji string) error {
+	params := map[string]string{
+		"channel":   message.Channel,
+		"timestamp": message.ID,
+		"name":      strings.Trim(emoji, ":"),
+	}
+
+	err := s.call(s.context(), s.config.GetSlackBotToken(), "reactions.add", params, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to add reaction")
+	}
+
+	return err
+}
+
+// send posts the response in a channel or thread. A message can only hold
+// one table, so every table after the first starts a new message. When
+// user is set the message is ephemeral and only shown to them. Images on
+// disk are uploaded after the messages.
+func (s *Slack) send(channel, thread, user string, response *models.Response) error {
+	method := "chat.postMessage"
+	if user != "" {
+		method = "chat.postEphemeral"
+	}
+
+	for _, message := range messages(response) {
+		params := map[string]any{
+			"channel": channel,
+			"text":    message.text,
+			"blocks":  message.blocks,
+		}
+		if thread != "" {
+			params["thread_ts"] = thread
+		}
+		if user != "" {
+			params["user"] = user
+		}
+
+		if err := s.call(s.context(), s.config.GetSlackBotToken(), method, params, nil); err != nil {
+			log.Error().Err(err).Str("platform", "slack").Msg("failed to send message")
+			return err
+		}
+	}
+
+	// files cannot be shared ephemerally
+	if user != "" {
+		return nil
+	}
+
+	for _, image := range response.Images {
+		if image.Path == "" {
+			continue
+		}
+
+		if err := s.sendImage(channel, thread, response.Title, image); err != nil {
+			log.Error().Err(err).Str("platform", "slack").Str("image", image.Path).Msg("failed to send image")
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Slack) sendImage(channel, thread, title string, image models.Image) error {
+	data, err := os.ReadFile(image.Path)
+	if err != nil {
+		return err
+	}
+
+	name := image.Name
+	if name == "" {
+		name = filepath.Base(image.Path)
+	}
+	if title == "" {
+		title = name
+	}
+
+	return s.uploadFile(s.context(), channel, thread, name, title, data)
+}
+
+// message is the text and blocks of a single chat.postMessage call. text
+// is shown in notifications and by clients that cannot show blocks.
+type message struct {
+	text   string
+	blocks []block
+}
+
+type block map[string]any
+
+// messages renders a response as Block Kit messages, see
+// https://api.slack.com/reference/block-kit/blocks
+func messages(response *models.Response) []message {
+	var blocks []block
+
+	if response.Title != "" {
+		blocks = append(blocks, block{
+			"type": "header",
+			"text": block{"type": "plain_text", "text": truncate(response.Title, 150)},
+		})
+	}
+
+	if response.Text != "" {
+		blocks = append(blocks, block{
+			"type": "section",
+			"text": block{"type": "mrkdwn", "text": truncate(escape(response.Text), 3000)},
+		})
+	}
+
+	for _, code := range response.Code {
+		blocks = append(blocks, block{
+			"type": "rich_text",
+			"elements": []block{{
+				"type":     "rich_text_preformatted",
+				"elements": []block{{"type": "text", "text": code.Content}},
+			}},
+		})
+	}
+
+	for _, image := range response.Images {
+		if image.Path == "" && image.URL != "" {
+			blocks = append(blocks, block{"type": "image", "image_url": image.URL, "alt_text": image.Name})
+		}
+	}
+
+	text := *response
+	text.Images = nil
+	fallback := truncate(render.Plain(&text), 4000)
+
+	if len(response.Tables) == 0 {
+		return []message{{text: fallback, blocks: blocks}}
+	}
+
+	var msgs []message
+	for i, table := range response.Tables {
+		if i == 0 {
+			msgs = append(msgs, message{text: fallback, blocks: append(blocks, tableBlock(table))})
+			continue
+		}
+		msgs = append(msgs, message{text: truncate(render.Table(table), 4000), blocks: []block{tableBlock(table)}})
+	}
+
+	return msgs
+}
+
+// tableBlock renders a table as a table block, with the header as its
+// first row.
+func tableBlock(table models.Table) block {
+	cell := func(text string) block {
+		return block{"type": "raw_text", "text": text}
+	}
+
+	var rows [][]block
+	settings := make([]block, len(table.Columns))
+
+	if len(table.Columns) > 0 {
+		header := make([]block, len(table.Columns))
+		for i, column := range table.Columns {
+			header[i] = cell(column.Name)
+			settings[i] = block{"align": "left"}
+			if column.AlignRight {
+				settings[i] = block{"align": "right"}
+			}
+		}
+		rows = append(rows, header)
+	}
+
+	for _, row := range table.Rows {
+		cells := make([]block, len(row))
+		for i, text := range row {
+			cells[i] = cell(text)
+		}
+		rows = append(rows, cells)
+	}
+
+	tb := block{"type": "table", "rows": rows}
+	if len(settings) > 0 {
+		tb["column_settings"] = settings
+	}
+
+	return tb
+}
+
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// escape escapes the characters Slack reserves for links and mentions.
+func escape(text string) string {
+	return escaper.Replace(text)
+}
+
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+
+	return string(runes[:limit-1]) + "…"
+}
@@ -0,0 +1,475 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/distrobyte/gerry/internal/testkit/platformtest"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	appToken = "xapp-test"
+	botToken = "xoxb-test"
+)
+
+// slackAPI is a stub of the Web API methods the adapter uses and of the
+// Socket Mode websocket. Envelopes queued with push are sent on the
+// current connection.
+type slackAPI struct {
+	t      *testing.T
+	server *httptest.Server
+	events chan map[string]any
+	// conns receives every Socket Mode connection opened
+	conns chan *websocket.Conn
+
+	mu    sync.Mutex
+	calls []call
+	acks  []string
+	// failOpens is how many of the next apps.connections.open fail
+	failOpens int
+}
+
+type call struct {
+	Method string
+	Token  string
+	Params map[string]any
+	// File is the content of an uploaded file
+	File string
+}
+
+func newSlackAPI(t *testing.T) *slackAPI {
+	t.Helper()
+
+	api := &slackAPI{t: t, events: make(chan map[string]any, 4), conns: make(chan *websocket.Conn, 4)}
+	api.server = httptest.NewServer(http.HandlerFunc(api.serve))
+	t.Cleanup(api.server.Close)
+
+	return api
+}
+
+func (api *slackAPI) serve(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/ws":
+		api.socket(w, r)
+		return
+	case "/upload":
+		data, _ := io.ReadAll(r.Body)
+		api.record(call{Method: "upload", File: string(data)})
+		return
+	}
+
+	method, _ := strings.CutPrefix(r.URL.Path, "/api/")
+	c := call{
+		Method: method,
+		Token:  strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "),
+		Params: make(map[string]any),
+	}
+	if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+		r.ParseForm()
+		for key := range r.PostForm {
+			c.Params[key] = r.PostForm.Get(key)
+		}
+	} else {
+		json.NewDecoder(r.Body).Decode(&c.Params)
+	}
+
+	want := botToken
+	if method == "apps.connections.open" {
+		want = appToken
+	}
+	if c.Token != want {
+		json.NewEncoder(w).Encode(map[string]any{"ok": false, "error": "invalid_auth"})
+		return
+	}
+
+	if method == "apps.connections.open" {
+		api.mu.Lock()
+		fail := api.failOpens > 0
+		if fail {
+			api.failOpens--
+		}
+		api.mu.Unlock()
+		if fail {
+			json.NewEncoder(w).Encode(map[string]any{"ok": false, "error": "internal_error"})
+			return
+		}
+	}
+
+	result := map[string]any{"ok": true}
+	switch method {
+	case "auth.test":
+		result["user_id"] = "UBOT"
+		result["team"] = "gerry"
+	case "apps.connections.open":
+		result["url"] = "ws" + strings.TrimPrefix(api.server.URL, "http") + "/ws"
+	case "users.info":
+		result["user"] = map[string]any{"name": "alice", "profile": map[string]any{"display_name": "Alice"}}
+	case "files.getUploadURLExternal":
+		result["upload_url"] = api.server.URL + "/upload"
+		result["file_id"] = "F1"
+	}
+
+	api.record(c)
+	json.NewEncoder(w).Encode(result)
+}
+
+func (api *slackAPI) socket(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	api.conns <- conn
+	conn.WriteJSON(map[string]any{"type": "hello"})
+
+	go func() {
+		for {
+			var ack struct {
+				EnvelopeID string `json:"envelope_id"`
+			}
+			if err := conn.ReadJSON(&ack); err != nil {
+				return
+			}
+			api.mu.Lock()
+			api.acks = append(api.acks, ack.EnvelopeID)
+			api.mu.Unlock()
+		}
+	}()
+
+	for {
+		select {
+		case envelope := <-api.events:
+			if err := conn.WriteJSON(envelope); err != nil {
+				return
+			}
+			if envelope["type"] == "disconnect" {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (api *slackAPI) record(c call) {
+	api.mu.Lock()
+	api.calls = append(api.calls, c)
+	api.mu.Unlock()
+}
+
+// push sends an event in an events_api envelope.
+func (api *slackAPI) push(id string, event map[string]any) {
+	api.events <- map[string]any{
+		"type":        "events_api",
+		"envelope_id": id,
+		"payload":     map[string]any{"event": event},
+	}
+}
+
+// waitCalls waits until method was called n times and returns those calls.
+func (api *slackAPI) waitCalls(method string, n int) []call {
+	api.t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var calls []call
+		api.mu.Lock()
+		for _, c := range api.calls {
+			if c.Method == method {
+				calls = append(calls, c)
+			}
+		}
+		api.mu.Unlock()
+
+		if len(calls) >= n {
+			return calls
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	api.t.Fatalf("%s was not called %d times", method, n)
+	return nil
+}
+
+func (api *slackAPI) waitAck(id string) {
+	api.t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		api.mu.Lock()
+		for _, ack := range api.acks {
+			if ack == id {
+				api.mu.Unlock()
+				return
+			}
+		}
+		api.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	api.t.Fatalf("envelope %s was not acknowledged", id)
+}
+
+func textMessage(user, text, ts, thread string) map[string]any {
+	return map[string]any{
+		"type":      "message",
+		"user":      user,
+		"text":      text,
+		"channel":   "C1",
+		"ts":        ts,
+		"thread_ts": thread,
+	}
+}
+
+func connect(t *testing.T, api *slackAPI, h *platformtest.Recorder) *Slack {
+	t.Helper()
+
+	cfg := &config.Config{Prefix: ">"}
+	cfg.Slack.AppToken = appToken
+	cfg.Slack.BotToken = botToken
+
+	s := New(cfg, h)
+	s.apiURL = api.server.URL + "/api/"
+
+	if err := s.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Disconnect() })
+
+	return s
+}
+
+func TestMessageAndReply(t *testing.T) {
+	api := newSlackAPI(t)
+	h := platformtest.NewRecorder(t, &models.Response{Title: "Race results", Text: "a < b"})
+	connect(t, api, h)
+
+	api.push("e1", map[string]any{"type": "message", "user": "UBOT", "text": ">own", "channel": "C1", "ts": "1.0"})
+	api.push("e2", map[string]any{"type": "message", "subtype": "bot_message", "bot_id": "B1", "text": ">bot", "channel": "C1", "ts": "1.1"})
+	api.push("e3", textMessage("U1", "&gt;karting stats &amp; more", "1.2", "0.9"))
+
+	message := h.Message()
+	if message.Content != ">karting stats & more" || message.Author != "Alice" || message.AuthorID != "U1" || message.Channel != "C1" || message.ID != "1.2" || message.Platform != "slack" {
+		t.Errorf("unexpected message %+v", message)
+	}
+
+	for _, id := range []string{"e1", "e2", "e3"} {
+		api.waitAck(id)
+	}
+
+	sent := api.waitCalls("chat.postMessage", 1)[0].Params
+	if sent["channel"] != "C1" || sent["thread_ts"] != "0.9" || sent["text"] != "Race results\na < b" {
+		t.Errorf("unexpected message sent %v", sent)
+	}
+
+	blocks, _ := json.Marshal(sent["blocks"])
+	want := `[{"text":{"text":"Race results","type":"plain_text"},"type":"header"},{"text":{"text":"a \u0026lt; b","type":"mrkdwn"},"type":"section"}]`
+	if string(blocks) != want {
+		t.Errorf("blocks = %s, want %s", blocks, want)
+	}
+
+	h.NoMessage(100 * time.Millisecond)
+}
+
+func TestMessageOrder(t *testing.T) {
+	api := newSlackAPI(t)
+	h := platformtest.NewRecorder(t, nil)
+	connect(t, api, h)
+
+	for i := range 20 {
+		api.push(fmt.Sprintf("e%d", i), textMessage("U1", fmt.Sprintf("message %d", i), fmt.Sprintf("1.%d", i), ""))
+	}
+
+	// keyword reactions and the history rely on messages arriving in order
+	for i := range 20 {
+		if got, want := h.Message().Content, fmt.Sprintf("message %d", i); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestTables(t *testing.T) {
+	api := newSlackAPI(t)
+	s := connect(t, api, platformtest.NewRecorder(t, nil))
+
+	table := models.Table{
+		Columns: []models.Column{{Name: "Driver"}, {Name: "ELO", AlignRight: true}},
+		Rows:    [][]string{{"alice", "1016"}},
+	}
+	err := s.Send("C1", &models.Response{Title: "Karting stats", Tables: []models.Table{table, table}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := api.waitCalls("chat.postMessage", 2)
+	if len(calls) != 2 {
+		t.Fatalf("sent %d messages, want one per table", len(calls))
+	}
+
+	blocks, _ := json.Marshal(calls[1].Params["blocks"])
+	want := `[{"column_settings":[{"align":"left"},{"align":"right"}],"rows":[[{"text":"Driver","type":"raw_text"},{"text":"ELO","type":"raw_text"}],[{"text":"alice","type":"raw_text"},{"text":"1016","type":"raw_text"}]],"type":"table"}]`
+	if string(blocks) != want {
+		t.Errorf("blocks = %s, want %s", blocks, want)
+	}
+}
+
+func TestEphemeral(t *testing.T) {
+	api := newSlackAPI(t)
+	s := connect(t, api, platformtest.NewRecorder(t, nil))
+
+	err := s.Reply(&models.Message{Channel: "C1", ID: "1.0", AuthorID: "U1"}, &models.Response{Text: "only you", Ephemeral: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := api.waitCalls("chat.postEphemeral", 1)[0].Params
+	if sent["channel"] != "C1" || sent["user"] != "U1" || sent["thread_ts"] != nil {
+		t.Errorf("unexpected message sent %v", sent)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	api := newSlackAPI(t)
+	s := connect(t, api, platformtest.NewRecorder(t, nil))
+
+	path := filepath.Join(t.TempDir(), "index.png")
+	if err := os.WriteFile(path, []byte("png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := s.Send("C1", &models.Response{
+		Title:  "Karting ELO history",
+		Images: []models.Image{{Name: "karting.png", Path: path}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if params := api.waitCalls("files.getUploadURLExternal", 1)[0].Params; params["filename"] != "karting.png" || params["length"] != "3" {
+		t.Errorf("unexpected upload %v", params)
+	}
+	if upload := api.waitCalls("upload", 1)[0]; upload.File != "png" {
+		t.Errorf("uploaded %q", upload.File)
+	}
+
+	params := api.waitCalls("files.completeUploadExternal", 1)[0].Params
+	files, _ := json.Marshal(params["files"])
+	if params["channel_id"] != "C1" || string(files) != `[{"id":"F1","title":"Karting ELO history"}]` {
+		t.Errorf("unexpected upload %v", params)
+	}
+}
+
+func TestReaction(t *testing.T) {
+	api := newSlackAPI(t)
+	h := platformtest.NewRecorder(t, nil)
+	s := connect(t, api, h)
+
+	api.push("e1", map[string]any{
+		"type":      "reaction_added",
+		"user":      "U2",
+		"reaction":  "joy",
+		"item_user": "U1",
+		"item":      map[string]any{"type": "message", "channel": "C1", "ts": "1.0"},
+	})
+
+	reaction := h.Reaction()
+	if reaction.Emoji != "joy" || reaction.Message.ID != "1.0" || reaction.Message.AuthorID != "U1" || reaction.Message.Author != "Alice" || reaction.ReactorID != "U2" || reaction.Removed {
+		t.Errorf("unexpected reaction %+v %+v", reaction, reaction.Message)
+	}
+
+	api.push("e2", map[string]any{
+		"type":      "reaction_removed",
+		"user":      "U2",
+		"reaction":  "joy",
+		"item_user": "U1",
+		"item":      map[string]any{"type": "message", "channel": "C1", "ts": "1.0"},
+	})
+
+	reaction = h.Reaction()
+	if reaction.Emoji != "joy" || reaction.ReactorID != "U2" || !reaction.Removed {
+		t.Errorf("unexpected reaction %+v", reaction)
+	}
+
+	if err := s.React(&models.Message{Channel: "C1", ID: "1.0"}, ":thumbsup:"); err != nil {
+		t.Fatal(err)
+	}
+	if params := api.waitCalls("reactions.add", 1)[0].Params; params["name"] != "thumbsup" || params["timestamp"] != "1.0" {
+		t.Errorf("unexpected reaction sent %v", params)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	api := newSlackAPI(t)
+	h := platformtest.NewRecorder(t, nil)
+	connect(t, api, h)
+	<-api.conns
+
+	api.events <- map[string]any{"type": "disconnect", "reason": "refresh_requested"}
+
+	select {
+	case <-api.conns:
+	case <-time.After(5 * time.Second):
+		t.Fatal("did not reconnect")
+	}
+
+	api.push("e1", textMessage("U1", ">ping", "1.0", ""))
+	if message := h.Message(); message.Content != ">ping" {
+		t.Errorf("unexpected message %+v", message)
+	}
+}
+
+func TestReconnectFailure(t *testing.T) {
+	api := newSlackAPI(t)
+	h := platformtest.NewRecorder(t, nil)
+	s := connect(t, api, h)
+	<-api.conns
+
+	api.mu.Lock()
+	api.failOpens = 1
+	api.mu.Unlock()
+	api.events <- map[string]any{"type": "disconnect", "reason": "refresh_requested"}
+
+	// the connection that could not be opened again is left to the bot
+	if got := h.State(); got.State != platform.StateDisconnected || got.Err == nil {
+		t.Errorf("got state %s (%v), want disconnected with an error", got.State, got.Err)
+	}
+	if err := s.Connect(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	<-api.conns
+
+	api.push("e1", textMessage("U1", ">ping", "1.0", ""))
+	if message := h.Message(); message.Content != ">ping" {
+		t.Errorf("unexpected message %+v", message)
+	}
+}
+
+func TestBadToken(t *testing.T) {
+	api := newSlackAPI(t)
+	cfg := &config.Config{}
+	cfg.Slack.AppToken = appToken
+	cfg.Slack.BotToken = "wrong"
+
+	s := New(cfg, platformtest.NewRecorder(t, nil))
+	s.apiURL = api.server.URL + "/api/"
+
+	err := s.Connect(context.Background())
+	if apiErr, ok := err.(*apiError); !ok || apiErr.Code != "invalid_auth" {
+		t.Errorf("Connect() = %v, want invalid_auth", err)
+	}
+}
@@ -0,0 +1,169 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// apiResponse wraps every Bot API result, see
+// https://core.telegram.org/bots/api#making-requests
+type apiResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// apiError is a request the Bot API refused.
+type apiError struct {
+	Method      string
+	Code        int
+	Description string
+	// RetryAfter is set when the bot is being rate limited
+	RetryAfter time.Duration
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("telegram: %s: %d %s", e.Method, e.Code, e.Description)
+}
+
+type user struct {
+	ID        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot"`
+	FirstName string `json:"first_name"`
+	Username  string `json:"username"`
+}
+
+// name returns how the user is shown in messages.
+func (u *user) name() string {
+	if u.Username != "" {
+		return u.Username
+	}
+
+	return u.FirstName
+}
+
+type chat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+}
+
+type message struct {
+	MessageID int64  `json:"message_id"`
+	From      *user  `json:"from"`
+	Chat      chat   `json:"chat"`
+	Date      int64  `json:"date"`
+	Text      string `json:"text"`
+}
+
+type reactionType struct {
+	Type  string `json:"type"`
+	Emoji string `json:"emoji,omitempty"`
+}
+
+type messageReaction struct {
+	Chat        chat           `json:"chat"`
+	MessageID   int64          `json:"message_id"`
+	User        *user          `json:"user"`
+	OldReaction []reactionType `json:"old_reaction"`
+	NewReaction []reactionType `json:"new_reaction"`
+}
+
+type update struct {
+	UpdateID        int64            `json:"update_id"`
+	Message         *message         `json:"message"`
+	MessageReaction *messageReaction `json:"message_reaction"`
+}
+
+// allowedUpdates are the update types the adapter asks for. Reactions are
+// only delivered in chats where the bot is an administrator.
+var allowedUpdates = []string{"message", "message_reaction"}
+
+// call invokes a Bot API method with params encoded as JSON and decodes the
+// result into out if it is not nil.
+func (t *Telegram) call(ctx context.Context, method string, params any, out any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	return t.do(ctx, method, bytes.NewReader(body), "application/json", out)
+}
+
+// upload invokes a Bot API method as a multipart form, for sending files.
+func (t *Telegram) upload(ctx context.Context, method string, fields map[string]string, field, name string, file io.Reader, out any) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	for key, value := range fields {
+		if err := form.WriteField(key, value); err != nil {
+			return err
+		}
+	}
+
+	part, err := form.CreateFormFile(field, name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	return t.do(ctx, method, &body, form.FormDataContentType(), out)
+}
+
+func (t *Telegram) do(ctx context.Context, method string, body io.Reader, contentType string, out any) error {
+	endpoint := strings.TrimRight(t.config.GetTelegramAPIURL(), "/") + "/bot" + t.config.GetTelegramToken() + "/" + method
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		// the URL holds the token, so only the cause is kept
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram: %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var result apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("telegram: %s: decoding response: %w", method, err)
+	}
+
+	if !result.OK {
+		return &apiError{
+			Method:      method,
+			Code:        result.ErrorCode,
+			Description: result.Description,
+			RetryAfter:  time.Duration(result.Parameters.RetryAfter) * time.Second,
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.Unmarshal(result.Result, out)
+}
@@ -0,0 +1,384 @@
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/rs/zerolog/log"
+)
+
+func init() {
+	platform.Register(platform.Driver{
+		Name:    "telegram",
+		Enabled: (*config.Config).IsTelegramEnabled,
+		New: func(cfg *config.Config, handler platform.Handler) platform.Platform {
+			return New(cfg, handler)
+		},
+	})
+}
+
+const (
+	// how long getUpdates waits for updates before returning empty
+	pollTimeout = 30 * time.Second
+	// webhookPath is where updates are received in webhook mode
+	webhookPath = "/telegram"
+	// recentMessages is how many messages are remembered per chat, so
+	// reactions to them can name the author
+	recentMessages = 200
+)
+
+// Telegram is the platform adapter for the Telegram Bot API. Updates are
+// received by long-polling getUpdates, or through the HTTP endpoint when
+// the webhook is enabled. A failed poll is reported to the handler as a
+// lost connection, and the handler calls Connect again.
+type Telegram struct {
+	config  *config.Config
+	handler platform.Handler
+	client  *http.Client
+
+	// sessionMu guards the session started by the last Connect
+	sessionMu sync.Mutex
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      chan struct{}
+	me        user
+
+	mu     sync.Mutex
+	recent map[int64][]*models.Message
+
+	// dispatch hands updates to the handler in order, chat by chat
+	dispatch platform.Dispatcher
+}
+
+func New(cfg *config.Config, handler platform.Handler) *Telegram {
+	return &Telegram{
+		config:  cfg,
+		handler: handler,
+		client:  &http.Client{Timeout: pollTimeout + 30*time.Second},
+		recent:  make(map[int64][]*models.Message),
+	}
+}
+
+func (t *Telegram) Name() string {
+	return "telegram"
+}
+
+// Connect checks the token and either registers the webhook or starts
+// polling. Updates sent while the bot was away are dropped. It is called
+// again to reconnect once a poll failed.
+func (t *Telegram) Connect(ctx context.Context) error {
+	if t.config.GetTelegramToken() == "" {
+		return fmt.Errorf("telegram token is not configured")
+	}
+
+	var me user
+	if err := t.call(ctx, "getMe", struct{}{}, &me); err != nil {
+		log.Error().Err(err).Msg("failed to log in to telegram")
+		return err
+	}
+
+	if t.config.IsTelegramWebhook() {
+		if err := t.setWebhook(ctx); err != nil {
+			return err
+		}
+	} else {
+		params := map[string]any{"drop_pending_updates": true}
+		if err := t.call(ctx, "deleteWebhook", params, nil); err != nil {
+			log.Error().Err(err).Msg("failed to remove telegram webhook")
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	t.sessionMu.Lock()
+	if t.cancel != nil {
+		// the session of the connection lost, its poll has returned
+		t.cancel()
+	}
+	t.ctx, t.cancel, t.done = ctx, cancel, done
+	t.me = me
+	t.sessionMu.Unlock()
+
+	if t.config.IsTelegramWebhook() {
+		close(done)
+	} else {
+		go t.poll(ctx, done)
+	}
+
+	log.Info().
+		Str("username", me.Username).
+		Bool("webhook", t.config.IsTelegramWebhook()).
+		Msg("connected to telegram")
+
+	return nil
+}
+
+func (t *Telegram) Disconnect() error {
+	t.sessionMu.Lock()
+	cancel, done := t.cancel, t.done
+	t.sessionMu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+	<-done
+
+	return nil
+}
+
+// context returns the context of the current session, cancelled by
+// Disconnect, or nil before Connect.
+func (t *Telegram) context() context.Context {
+	t.sessionMu.Lock()
+	defer t.sessionMu.Unlock()
+
+	return t.ctx
+}
+
+// self returns the bot user the token belongs to.
+func (t *Telegram) self() user {
+	t.sessionMu.Lock()
+	defer t.sessionMu.Unlock()
+
+	return t.me
+}
+
+func (t *Telegram) setWebhook(ctx context.Context) error {
+	if !t.config.IsHTTPEndpointEnabled() || t.config.GetDomain() == "" {
+		return errors.New("telegram webhook needs the http endpoint enabled and a domain set")
+	}
+
+	if t.config.GetTelegramWebhookSecret() == "" {
+		return errors.New("telegram webhook needs a webhook secret")
+	}
+
+	params := map[string]any{
+		"url":                  "https://" + t.config.GetDomain() + webhookPath,
+		"secret_token":         t.config.GetTelegramWebhookSecret(),
+		"allowed_updates":      allowedUpdates,
+		"drop_pending_updates": true,
+	}
+	if err := t.call(ctx, "setWebhook", params, nil); err != nil {
+		log.Error().Err(err).Msg("failed to set telegram webhook")
+		return err
+	}
+
+	return nil
+}
+
+// WebhookPath returns where the HTTP endpoint should serve the webhook, or
+// "" when polling.
+func (t *Telegram) WebhookPath() string {
+	if !t.config.IsTelegramWebhook() {
+		return ""
+	}
+
+	return webhookPath
+}
+
+// ServeHTTP receives an update pushed by telegram in webhook mode.
+func (t *Telegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	secret := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(t.config.GetTelegramWebhookSecret())) != 1 {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var u update
+	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// the update is acknowledged once this returns, so commands run after
+	if ctx := t.context(); ctx != nil {
+		t.handleUpdate(ctx, &u)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// poll long-polls getUpdates until ctx is done, and reports the lost
+// connection when a poll fails.
+func (t *Telegram) poll(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	var offset int64
+	for {
+		params := map[string]any{
+			"offset":          offset,
+			"timeout":         int(pollTimeout.Seconds()),
+			"allowed_updates": allowedUpdates,
+		}
+
+		var updates []update
+		err := t.call(ctx, "getUpdates", params, &updates)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			log.Warn().Err(err).Str("platform", "telegram").Msg("telegram getUpdates failed")
+			t.handler.HandleState(t, platform.StateDisconnected, err)
+			return
+		}
+
+		for i := range updates {
+			offset = updates[i].UpdateID + 1
+			t.handleUpdate(ctx, &updates[i])
+		}
+	}
+}
+
+func (t *Telegram) handleUpdate(ctx context.Context, u *update) {
+	switch {
+	case u.Message != nil:
+		if message, ok := t.message(u.Message); ok {
+			t.dispatch.Dispatch(message.Channel, func() {
+				t.handler.HandleMessage(ctx, t, message)
+			})
+		}
+
+	case u.MessageReaction != nil:
+		t.handleReaction(ctx, u.MessageReaction)
+	}
+}
+
+// message converts a text message, remembering it for reactions. Bot
+// commands such as /ping@gerrybot are rewritten to use the prefix, so they
+// work like any other command.
+func (t *Telegram) message(m *message) (*models.Message, bool) {
+	me := t.self()
+	if m.From == nil || m.From.ID == me.ID || m.Text == "" {
+		return nil, false
+	}
+
+	content := m.Text
+	if command, ok := strings.CutPrefix(content, "/"); ok {
+		name, args, _ := strings.Cut(command, " ")
+		name, bot, addressed := strings.Cut(name, "@")
+
+		// commands addressed to another bot in the chat are not ours
+		if addressed && !strings.EqualFold(bot, me.Username) {
+			return nil, false
+		}
+
+		content = strings.TrimSpace(t.config.GetBotPrefix() + name + " " + args)
+	}
+
+	message := &models.Message{
+		Content:    content,
+		Author:     m.From.name(),
+		AuthorID:   strconv.FormatInt(m.From.ID, 10),
+		Channel:    strconv.FormatInt(m.Chat.ID, 10),
+		ID:         strconv.FormatInt(m.MessageID, 10),
+		RecievedAt: time.Now(),
+		Platform:   t.Name(),
+	}
+	t.remember(m.Chat.ID, message)
+
+	return message, true
+}
+
+// handleReaction passes on the emojis added and removed in a reaction
+// update. The Bot API cannot fetch messages, so the author of the message
+// reacted to is only known if the bot saw it recently.
+func (t *Telegram) handleReaction(ctx context.Context, r *messageReaction) {
+	if r.User == nil || r.User.ID == t.self().ID {
+		return
+	}
+
+	message := t.lookup(r.Chat.ID, r.MessageID)
+
+	changed := func(from, to []reactionType, removed bool) {
+		for _, reaction := range from {
+			if reaction.Type != "emoji" || containsEmoji(to, reaction.Emoji) {
+				continue
+			}
+
+			log.Info().
+				Str("platform", "telegram").
+				Str("event", "reaction").
+				Str("message_id", message.ID).
+				Int64("user_id", r.User.ID).
+				Str("emoji", reaction.Emoji).
+				Bool("removed", removed).
+				Msg("reaction")
+
+			reaction := &models.MessageReaction{
+				Message:   message,
+				Emoji:     reaction.Emoji,
+				Reactor:   r.User.name(),
+				ReactorID: strconv.FormatInt(r.User.ID, 10),
+				Removed:   removed,
+			}
+			t.dispatch.Dispatch(message.Channel, func() {
+				t.handler.HandleReaction(ctx, t, reaction)
+			})
+		}
+	}
+
+	changed(r.NewReaction, r.OldReaction, false)
+	changed(r.OldReaction, r.NewReaction, true)
+}
+
+func containsEmoji(reactions []reactionType, emoji string) bool {
+	for _, reaction := range reactions {
+		if reaction.Type == "emoji" && reaction.Emoji == emoji {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *Telegram) remember(chatID int64, message *models.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	recent := append(t.recent[chatID], message)
+	if len(recent) > recentMessages {
+		recent = recent[len(recent)-recentMessages:]
+	}
+	t.recent[chatID] = recent
+}
+
+// lookup returns a recently seen message, or one with only its chat and ID
+// set if it was not seen.
+func (t *Telegram) lookup(chatID, messageID int64) *models.Message {
+	id := strconv.FormatInt(messageID, 10)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, message := range t.recent[chatID] {
+		if message.ID == id {
+			return message
+		}
+	}
+
+	return &models.Message{
+		Channel:    strconv.FormatInt(chatID, 10),
+		ID:         id,
+		RecievedAt: time.Now(),
+		Platform:   t.Name(),
+	}
+}
@@ -0,0 +1,233 @@
+package telegram
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/distrobyte/gerry/internal/render"
+	"github.com/rs/zerolog/log"
+)
+
+// messageLimit is the longest text a message may have, in characters.
+const messageLimit = 4096
+
+// Send posts a response to a chat. Text longer than a message allows is
+// spread over several, and images on disk are uploaded with sendPhoto.
+func (t *Telegram) Send(channel string, response *models.Response) error {
+	return t.send(channel, response, "")
+}
+
+// Reply responds to a message in its chat. Ephemeral responses go to a
+// private chat with the author, which only works once they have started
+// one with the bot.
+func (t *Telegram) Reply(message *models.Message, response *models.Response) error {
+	if response.Ephemeral && message.AuthorID != message.Channel {
+		if message.AuthorID == "" {
+			return platform.ErrNotPrivate
+		}
+		if err := t.send(message.AuthorID, response, ""); err != nil {
+			return fmt.Errorf("%w: %v", platform.ErrNotPrivate, err)
+		}
+		return nil
+	}
+
+	return t.send(message.Channel, response, message.ID)
+}
+
+// React sets the reaction of the bot on a message. Telegram only allows a
+// fixed set of emojis.
+func (t *Telegram) React(message *models.Message, emoji string) error {
+	params := map[string]any{
+		"chat_id":    message.Channel,
+		"message_id": messageID(message.ID),
+		"reaction":   []reactionType{{Type: "emoji", Emoji: emoji}},
+	}
+
+	err := t.call(t.context(), "setMessageReaction", params, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to add reaction")
+	}
+
+	return err
+}
+
+// Typing shows the typing indicator in a chat for up to five seconds.
+func (t *Telegram) Typing(channel string) error {
+	return t.call(t.context(), "sendChatAction", map[string]any{"chat_id": channel, "action": "typing"}, nil)
+}
+
+func (t *Telegram) send(chatID string, response *models.Response, replyTo string) error {
+	parseMode := "HTML"
+	if t.config.GetTelegramFormat() == "markdown" {
+		parseMode = "MarkdownV2"
+	}
+
+	for _, text := range t.render(response) {
+		params := map[string]any{
+			"chat_id":              chatID,
+			"text":                 text,
+			"parse_mode":           parseMode,
+			"link_preview_options": map[string]any{"is_disabled": true},
+		}
+		if replyTo != "" {
+			params["reply_parameters"] = map[string]any{"message_id": messageID(replyTo), "allow_sending_without_reply": true}
+			// only the first message is a reply
+			replyTo = ""
+		}
+
+		if err := t.call(t.context(), "sendMessage", params, nil); err != nil {
+			log.Error().Err(err).Str("platform", "telegram").Msg("failed to send message")
+			return err
+		}
+	}
+
+	for _, image := range response.Images {
+		if image.Path == "" {
+			continue
+		}
+
+		if err := t.sendPhoto(chatID, image); err != nil {
+			log.Error().Err(err).Str("platform", "telegram").Str("image", image.Path).Msg("failed to send image")
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *Telegram) sendPhoto(chatID string, image models.Image) error {
+	file, err := os.Open(image.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	name := image.Name
+	if name == "" {
+		name = filepath.Base(image.Path)
+	}
+
+	return t.upload(t.context(), "sendPhoto", map[string]string{"chat_id": chatID}, "photo", name, file, nil)
+}
+
+// messageID converts a message ID back to the integer the API expects.
+func messageID(id string) int64 {
+	n, _ := strconv.ParseInt(id, 10, 64)
+	return n
+}
+
+// part is a formatted piece of a message, with the length telegram counts
+// for it: only the visible text, not the markup.
+type part struct {
+	text   string
+	length int
+}
+
+// render formats a response as the texts of one or more messages. Images
+// on disk are left out, they are sent as photos.
+func (t *Telegram) render(response *models.Response) []string {
+	escape, bold, pre, link := htmlFormat()
+	if t.config.GetTelegramFormat() == "markdown" {
+		escape, bold, pre, link = markdownFormat()
+	}
+
+	var parts []part
+	add := func(text string, format func(string) string) {
+		// a part too long for a message on its own is cut short, as
+		// splitting it could break its formatting
+		text = truncate(text, messageLimit)
+		parts = append(parts, part{text: format(text), length: utf8.RuneCountInString(text)})
+	}
+
+	if response.Title != "" {
+		add(response.Title, bold)
+	}
+	if response.Text != "" {
+		add(response.Text, escape)
+	}
+	for _, table := range response.Tables {
+		add(render.Table(table), pre)
+	}
+	for _, code := range response.Code {
+		add(code.Content, pre)
+	}
+	for _, image := range response.Images {
+		if image.Path == "" && image.URL != "" {
+			add(image.Name, func(name string) string { return link(name, image.URL) })
+		}
+	}
+
+	return pack(parts, messageLimit)
+}
+
+func htmlFormat() (escape, bold, pre func(string) string, link func(name, url string) string) {
+	escape = html.EscapeString
+	bold = func(text string) string { return "<b>" + escape(text) + "</b>" }
+	pre = func(text string) string { return "<pre>" + escape(text) + "</pre>" }
+	link = func(name, url string) string { return fmt.Sprintf(`<a href="%s">%s</a>`, escape(url), escape(name)) }
+	return
+}
+
+var (
+	// MarkdownV2 needs these escaped everywhere outside code
+	markdownEscaper = strings.NewReplacer(
+		`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "~", `\~`, "`", "\\`",
+		">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`, "|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+	)
+	// and only these inside code blocks and link URLs
+	markdownCodeEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+	markdownURLEscaper  = strings.NewReplacer(`\`, `\\`, ")", `\)`)
+)
+
+func markdownFormat() (escape, bold, pre func(string) string, link func(name, url string) string) {
+	escape = markdownEscaper.Replace
+	bold = func(text string) string { return "*" + escape(text) + "*" }
+	pre = func(text string) string { return "```\n" + markdownCodeEscaper.Replace(text) + "\n```" }
+	link = func(name, url string) string {
+		return "[" + escape(name) + "](" + markdownURLEscaper.Replace(url) + ")"
+	}
+	return
+}
+
+// pack joins parts with newlines into as few texts of at most limit
+// characters as it can.
+func pack(parts []part, limit int) []string {
+	var texts []string
+	var current string
+	var length int
+
+	for _, part := range parts {
+		if current != "" && length+1+part.length <= limit {
+			current += "\n" + part.text
+			length += 1 + part.length
+			continue
+		}
+
+		if current != "" {
+			texts = append(texts, current)
+		}
+		current, length = part.text, part.length
+	}
+
+	if current != "" {
+		texts = append(texts, current)
+	}
+
+	return texts
+}
+
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+
+	return string(runes[:limit])
+}
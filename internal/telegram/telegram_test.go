@@ -0,0 +1,409 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/distrobyte/gerry/internal/testkit/platformtest"
+)
+
+const token = "123:secret"
+
+// botAPI is a stub of the Bot API methods the adapter uses. Updates queued
+// with push are returned by the next getUpdates.
+type botAPI struct {
+	t       *testing.T
+	server  *httptest.Server
+	updates chan []map[string]any
+
+	mu    sync.Mutex
+	calls []call
+	// failPolls is how many of the next getUpdates fail
+	failPolls int
+}
+
+type call struct {
+	Method string
+	Params map[string]any
+	// File is the content of an uploaded file
+	File string
+}
+
+func newBotAPI(t *testing.T) *botAPI {
+	t.Helper()
+
+	api := &botAPI{t: t, updates: make(chan []map[string]any, 4)}
+	api.server = httptest.NewServer(http.HandlerFunc(api.serve))
+	t.Cleanup(api.server.Close)
+
+	return api
+}
+
+func (api *botAPI) serve(w http.ResponseWriter, r *http.Request) {
+	method, ok := strings.CutPrefix(r.URL.Path, "/bot"+token+"/")
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]any{"ok": false, "error_code": 401, "description": "Unauthorized"})
+		return
+	}
+
+	c := call{Method: method, Params: make(map[string]any)}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		r.ParseMultipartForm(1 << 20)
+		for key, values := range r.MultipartForm.Value {
+			c.Params[key] = values[0]
+		}
+		for _, files := range r.MultipartForm.File {
+			file, _ := files[0].Open()
+			data, _ := io.ReadAll(file)
+			c.File = files[0].Filename + ":" + string(data)
+		}
+	} else {
+		json.NewDecoder(r.Body).Decode(&c.Params)
+	}
+
+	var result any = true
+	switch method {
+	case "getMe":
+		result = map[string]any{"id": 1, "is_bot": true, "first_name": "Gerry", "username": "gerrybot"}
+	case "getUpdates":
+		api.mu.Lock()
+		fail := api.failPolls > 0
+		if fail {
+			api.failPolls--
+		}
+		api.mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusBadGateway)
+			json.NewEncoder(w).Encode(map[string]any{"ok": false, "error_code": 502, "description": "Bad Gateway"})
+			return
+		}
+
+		select {
+		case updates := <-api.updates:
+			result = updates
+		case <-time.After(50 * time.Millisecond):
+			result = []any{}
+		case <-r.Context().Done():
+			return
+		}
+		// polls are not interesting to assert on
+		json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+		return
+	case "sendMessage", "sendPhoto":
+		result = map[string]any{"message_id": 1000}
+	}
+
+	api.mu.Lock()
+	api.calls = append(api.calls, c)
+	api.mu.Unlock()
+
+	json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+func (api *botAPI) push(updates ...map[string]any) {
+	api.updates <- updates
+}
+
+// waitCalls waits until method was called n times and returns those calls.
+func (api *botAPI) waitCalls(method string, n int) []call {
+	api.t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var calls []call
+		api.mu.Lock()
+		for _, c := range api.calls {
+			if c.Method == method {
+				calls = append(calls, c)
+			}
+		}
+		api.mu.Unlock()
+
+		if len(calls) >= n {
+			return calls
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	api.t.Fatalf("%s was not called %d times", method, n)
+	return nil
+}
+
+func textMessage(updateID, messageID, from int64, username, text string) map[string]any {
+	return map[string]any{
+		"update_id": updateID,
+		"message": map[string]any{
+			"message_id": messageID,
+			"from":       map[string]any{"id": from, "first_name": username, "username": username},
+			"chat":       map[string]any{"id": -100, "type": "group"},
+			"date":       1700000000,
+			"text":       text,
+		},
+	}
+}
+
+func newConfig(api *botAPI) *config.Config {
+	cfg := &config.Config{Prefix: ">"}
+	cfg.Telegram.APIURL = api.server.URL
+	cfg.Telegram.Token = token
+	return cfg
+}
+
+func connect(t *testing.T, cfg *config.Config, h *platformtest.Recorder) *Telegram {
+	t.Helper()
+
+	tg := New(cfg, h)
+	if err := tg.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tg.Disconnect() })
+
+	return tg
+}
+
+func TestMessageAndReply(t *testing.T) {
+	api := newBotAPI(t)
+	h := platformtest.NewRecorder(t, &models.Response{Title: "Race results", Text: "a < b"})
+	connect(t, newConfig(api), h)
+
+	api.push(
+		textMessage(1, 10, 1, "gerrybot", ">own"),
+		textMessage(2, 11, 42, "alice", "/ping@otherbot"),
+		textMessage(3, 12, 42, "alice", "/karting@gerrybot stats"),
+	)
+
+	message := h.Message()
+	if message.Content != ">karting stats" || message.Author != "alice" || message.AuthorID != "42" || message.Channel != "-100" || message.ID != "12" || message.Platform != "telegram" {
+		t.Errorf("unexpected message %+v", message)
+	}
+
+	sent := api.waitCalls("sendMessage", 1)[0].Params
+	if sent["chat_id"] != "-100" || sent["parse_mode"] != "HTML" || sent["text"] != "<b>Race results</b>\na &lt; b" {
+		t.Errorf("unexpected message sent %v", sent)
+	}
+	if reply, _ := sent["reply_parameters"].(map[string]any); reply["message_id"] != float64(12) {
+		t.Errorf("message is not a reply: %v", sent)
+	}
+
+	h.NoMessage(100 * time.Millisecond)
+}
+
+func TestMessageOrder(t *testing.T) {
+	api := newBotAPI(t)
+	h := platformtest.NewRecorder(t, nil)
+	connect(t, newConfig(api), h)
+
+	updates := make([]map[string]any, 20)
+	for i := range updates {
+		updates[i] = textMessage(int64(i+1), int64(i+10), 42, "alice", fmt.Sprintf("message %d", i))
+	}
+	api.push(updates...)
+
+	// keyword reactions and the history rely on messages arriving in order
+	for i := range updates {
+		if got, want := h.Message().Content, fmt.Sprintf("message %d", i); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestPollFailure(t *testing.T) {
+	api := newBotAPI(t)
+	h := platformtest.NewRecorder(t, nil)
+	tg := connect(t, newConfig(api), h)
+
+	api.mu.Lock()
+	api.failPolls = 1
+	api.mu.Unlock()
+
+	// the failed poll is left to the bot to connect again
+	if got := h.State(); got.State != platform.StateDisconnected || got.Err == nil {
+		t.Errorf("got state %s (%v), want disconnected with an error", got.State, got.Err)
+	}
+	if err := tg.Connect(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	api.push(textMessage(1, 10, 42, "alice", ">ping"))
+	if got := h.Message(); got.ID != "10" {
+		t.Errorf("unexpected message %+v", got)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	api := newBotAPI(t)
+	cfg := newConfig(api)
+	cfg.Telegram.Format = "markdown"
+	tg := connect(t, cfg, platformtest.NewRecorder(t, nil))
+
+	err := tg.Send("-100", &models.Response{
+		Title: "Karting stats",
+		Text:  "v1.2 (beta)!",
+		Code:  []models.CodeBlock{{Content: "a`b"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := api.waitCalls("sendMessage", 1)[0].Params
+	want := "*Karting stats*\nv1\\.2 \\(beta\\)\\!\n```\na\\`b\n```"
+	if sent["parse_mode"] != "MarkdownV2" || sent["text"] != want {
+		t.Errorf("sent %q, want %q", sent["text"], want)
+	}
+}
+
+func TestSendPhoto(t *testing.T) {
+	api := newBotAPI(t)
+	tg := connect(t, newConfig(api), platformtest.NewRecorder(t, nil))
+
+	path := filepath.Join(t.TempDir(), "index.png")
+	if err := os.WriteFile(path, []byte("png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := tg.Send("-100", &models.Response{
+		Title:  "Karting ELO history",
+		Images: []models.Image{{Name: "karting.png", Path: path, URL: "https://example.com/karting.png"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if text := api.waitCalls("sendMessage", 1)[0].Params["text"]; text != "<b>Karting ELO history</b>" {
+		t.Errorf("unexpected text %q", text)
+	}
+
+	photo := api.waitCalls("sendPhoto", 1)[0]
+	if photo.Params["chat_id"] != "-100" || photo.File != "karting.png:png" {
+		t.Errorf("unexpected photo %+v", photo)
+	}
+}
+
+func TestReaction(t *testing.T) {
+	api := newBotAPI(t)
+	h := platformtest.NewRecorder(t, nil)
+	tg := connect(t, newConfig(api), h)
+
+	api.push(textMessage(1, 10, 7, "bob", "a joke"))
+	h.Message()
+
+	api.push(map[string]any{
+		"update_id": 2,
+		"message_reaction": map[string]any{
+			"chat":         map[string]any{"id": -100, "type": "group"},
+			"message_id":   10,
+			"user":         map[string]any{"id": 42, "first_name": "alice"},
+			"date":         1700000000,
+			"old_reaction": []any{map[string]any{"type": "emoji", "emoji": "👍"}},
+			"new_reaction": []any{
+				map[string]any{"type": "emoji", "emoji": "👍"},
+				map[string]any{"type": "emoji", "emoji": "😁"},
+			},
+		},
+	})
+
+	reaction := h.Reaction()
+	if reaction.Emoji != "😁" || reaction.Message.ID != "10" || reaction.Message.AuthorID != "7" || reaction.Message.Content != "a joke" || reaction.ReactorID != "42" || reaction.Reactor != "alice" || reaction.Removed {
+		t.Errorf("unexpected reaction %+v %+v", reaction, reaction.Message)
+	}
+
+	api.push(map[string]any{
+		"update_id": 3,
+		"message_reaction": map[string]any{
+			"chat":         map[string]any{"id": -100, "type": "group"},
+			"message_id":   10,
+			"user":         map[string]any{"id": 42, "first_name": "alice"},
+			"date":         1700000000,
+			"old_reaction": []any{map[string]any{"type": "emoji", "emoji": "👍"}, map[string]any{"type": "emoji", "emoji": "😁"}},
+			"new_reaction": []any{map[string]any{"type": "emoji", "emoji": "😁"}},
+		},
+	})
+
+	reaction = h.Reaction()
+	if reaction.Emoji != "👍" || reaction.ReactorID != "42" || !reaction.Removed {
+		t.Errorf("unexpected reaction %+v", reaction)
+	}
+
+	if err := tg.React(&models.Message{Channel: "-100", ID: "10"}, "👍"); err != nil {
+		t.Fatal(err)
+	}
+	if params := api.waitCalls("setMessageReaction", 1)[0].Params; params["message_id"] != float64(10) {
+		t.Errorf("unexpected reaction sent %v", params)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	api := newBotAPI(t)
+	cfg := newConfig(api)
+	cfg.HTTP.Enable = true
+	cfg.Domain = "gerry.example.com"
+	cfg.Telegram.Webhook = true
+	cfg.Telegram.WebhookSecret = "hook"
+	h := platformtest.NewRecorder(t, nil)
+	tg := connect(t, cfg, h)
+
+	if tg.WebhookPath() != "/telegram" {
+		t.Errorf("webhook path %q", tg.WebhookPath())
+	}
+
+	params := api.waitCalls("setWebhook", 1)[0].Params
+	if params["url"] != "https://gerry.example.com/telegram" || params["secret_token"] != "hook" {
+		t.Errorf("unexpected webhook %v", params)
+	}
+
+	body, _ := json.Marshal(textMessage(1, 10, 42, "alice", ">ping"))
+
+	req := httptest.NewRequest(http.MethodPost, "/telegram", strings.NewReader(string(body)))
+	req.Header.Set("X-Telegram-Bot-Api-Secret-Token", "wrong")
+	rec := httptest.NewRecorder()
+	tg.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong secret got status %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/telegram", strings.NewReader(string(body)))
+	req.Header.Set("X-Telegram-Bot-Api-Secret-Token", "hook")
+	rec = httptest.NewRecorder()
+	tg.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("got status %d", rec.Code)
+	}
+
+	if message := h.Message(); message.Content != ">ping" {
+		t.Errorf("unexpected message %+v", message)
+	}
+}
+
+func TestBadToken(t *testing.T) {
+	api := newBotAPI(t)
+	cfg := newConfig(api)
+	cfg.Telegram.Token = "wrong"
+
+	err := New(cfg, platformtest.NewRecorder(t, nil)).Connect(context.Background())
+	if apiErr, ok := err.(*apiError); !ok || apiErr.Code != 401 {
+		t.Errorf("Connect() = %v, want 401", err)
+	}
+}
+
+func TestPack(t *testing.T) {
+	parts := []part{{"<b>a</b>", 1}, {"bb", 2}, {"ccc", 3}}
+
+	got := pack(parts, 5)
+	if strings.Join(got, "|") != "<b>a</b>\nbb|ccc" {
+		t.Errorf("pack() = %q", got)
+	}
+}
@@ -0,0 +1,37 @@
+package testkit
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// Golden compares got with testdata/<name>.golden in the package under
+// test. Run the tests with -update to write the golden files instead.
+func Golden(t testing.TB, name, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file, run with -update to create it: %v", err)
+	}
+
+	if got != string(want) {
+		t.Errorf("output does not match %s, run with -update to accept it\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
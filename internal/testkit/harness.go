@@ -0,0 +1,185 @@
+// Package testkit runs a bot against a fake platform so commands and
+// features can be tested without a network.
+package testkit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/bot"
+	"github.com/distrobyte/gerry/internal/config"
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+	"github.com/distrobyte/gerry/internal/render"
+)
+
+// Epoch is the time the clock of every harness starts at.
+var Epoch = time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// Harness is a running bot connected to a fake platform only, storing its
+// data in a temporary directory.
+type Harness struct {
+	t        testing.TB
+	Bot      *bot.Bot
+	Config   *config.Config
+	Platform *Platform
+	Clock    *Clock
+	// Dir is the data directory of the bot
+	Dir string
+	// StateDir is the state directory of the bot
+	StateDir string
+
+	configure []func(cfg *config.Config)
+}
+
+// New starts a bot for the duration of the test. configure, if given, can
+// change the config before the bot is created. The bot is stopped when the
+// test ends.
+func New(t testing.TB, configure ...func(cfg *config.Config)) *Harness {
+	t.Helper()
+
+	h := &Harness{
+		t:         t,
+		Dir:       t.TempDir(),
+		StateDir:  t.TempDir(),
+		Clock:     NewClock(Epoch),
+		configure: configure,
+	}
+	h.Config = h.NextConfig()
+
+	b, err := bot.New(h.Config)
+	if err != nil {
+		t.Fatalf("creating bot: %v", err)
+	}
+	h.Bot = b
+	h.Platform = NewPlatform("fake", b)
+
+	b.SetClock(h.Clock.Now)
+	b.SetReconnectBackoff(time.Millisecond, 10*time.Millisecond)
+	b.UsePlatforms(h.Platform)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Start(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("bot stopped with error: %v", err)
+		}
+	})
+
+	select {
+	case <-h.Platform.Connected():
+	case err := <-done:
+		t.Fatalf("bot failed to start: %v", err)
+	}
+
+	return h
+}
+
+// NextConfig returns a new config equal to the one the bot started with,
+// for tests to change and pass to Reload.
+func (h *Harness) NextConfig() *config.Config {
+	cfg := &config.Config{
+		Prefix:      ">",
+		Environment: config.APP_ENVIRONMENT_LOCAL,
+		DataDir:     h.Dir,
+		StateDir:    h.StateDir,
+	}
+	for _, fn := range h.configure {
+		fn(cfg)
+	}
+
+	return cfg
+}
+
+// Send delivers content as a message from author and returns the response
+// the bot replied with, or nil if it did not reply.
+func (h *Harness) Send(author, content string) *models.Response {
+	h.t.Helper()
+
+	return h.Deliver(&models.Message{
+		Content:  content,
+		Author:   author,
+		AuthorID: author,
+		Channel:  "general",
+	})
+}
+
+// Deliver delivers message like Send, for tests that need to set more of
+// it, such as the platform it came from.
+func (h *Harness) Deliver(message *models.Message) *models.Response {
+	h.t.Helper()
+
+	sent := h.Platform.Message(message)
+
+	switch len(sent) {
+	case 0:
+		return nil
+	case 1:
+		return sent[0].Response
+	default:
+		h.t.Fatalf("%q got %d responses, expected at most one", message.Content, len(sent))
+		return nil
+	}
+}
+
+// Post delivers content as a message from author in the general channel
+// and returns the message, for tests that react to it.
+func (h *Harness) Post(author, content string) *models.Message {
+	h.t.Helper()
+
+	message := &models.Message{
+		Content:  content,
+		Author:   author,
+		AuthorID: author,
+		Channel:  "general",
+	}
+	h.Platform.Message(message)
+
+	return message
+}
+
+// React adds a reaction from reactor to message and returns the responses
+// sent while it was handled.
+func (h *Harness) React(reactor string, message *models.Message, emoji string) []Sent {
+	return h.Platform.Reaction(&models.MessageReaction{Message: message, Emoji: emoji, Reactor: reactor})
+}
+
+// Unreact removes a reaction like React.
+func (h *Harness) Unreact(reactor string, message *models.Message, emoji string) []Sent {
+	return h.Platform.Reaction(&models.MessageReaction{Message: message, Emoji: emoji, Reactor: reactor, Removed: true})
+}
+
+// WaitConnected waits until the bot reports the platform as connected.
+func (h *Harness) WaitConnected() {
+	h.t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, status := range h.Bot.Connections() {
+			if status.Platform == h.Platform.Name() && status.State == platform.StateConnected {
+				return
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	h.t.Fatalf("%s was not connected", h.Platform.Name())
+}
+
+// Markdown sends content like Send and renders the response as markdown.
+func (h *Harness) Markdown(author, content string) string {
+	h.t.Helper()
+
+	response := h.Send(author, content)
+	if response == nil {
+		h.t.Fatalf("%q got no response", content)
+	}
+
+	return render.Markdown(response)
+}
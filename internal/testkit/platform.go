@@ -0,0 +1,236 @@
+package testkit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+)
+
+// Sent is a response the fake platform was asked to deliver.
+type Sent struct {
+	Channel string
+	// ReplyTo is the message replied to, nil for responses sent to a channel
+	ReplyTo  *models.Message
+	Response *models.Response
+}
+
+// Reaction is a reaction the fake platform was asked to add.
+type Reaction struct {
+	Message *models.Message
+	Emoji   string
+}
+
+// Platform is a fake platform adapter. Tests script incoming messages and
+// reactions through it and inspect what the bot sent back. Events are
+// handled synchronously, so everything sent for an event has been recorded
+// once the call delivering it returns.
+type Platform struct {
+	name    string
+	handler platform.Handler
+
+	ctx       context.Context
+	connected chan struct{}
+
+	mu        sync.Mutex
+	sent      []Sent
+	reactions []Reaction
+	typing    []string
+	nextID    int
+	connects  int
+	reloads   int
+	// noPrivate makes ephemeral replies fail
+	noPrivate bool
+	// connectErrs are returned by the next calls to Connect
+	connectErrs []error
+}
+
+// NewPlatform returns a fake platform called name delivering events to
+// handler.
+func NewPlatform(name string, handler platform.Handler) *Platform {
+	return &Platform{
+		name:      name,
+		handler:   handler,
+		connected: make(chan struct{}),
+	}
+}
+
+func (p *Platform) Name() string {
+	return p.name
+}
+
+func (p *Platform) Connect(ctx context.Context) error {
+	p.mu.Lock()
+	if len(p.connectErrs) > 0 {
+		err := p.connectErrs[0]
+		p.connectErrs = p.connectErrs[1:]
+		p.mu.Unlock()
+		return err
+	}
+	p.connects++
+	first := p.connects == 1
+	p.mu.Unlock()
+
+	if first {
+		p.ctx = ctx
+		close(p.connected)
+	}
+
+	return nil
+}
+
+func (p *Platform) Disconnect() error {
+	return nil
+}
+
+func (p *Platform) Send(channel string, response *models.Response) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.sent = append(p.sent, Sent{Channel: channel, Response: response})
+	return nil
+}
+
+func (p *Platform) Reply(message *models.Message, response *models.Response) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if response.Ephemeral && p.noPrivate {
+		return platform.ErrNotPrivate
+	}
+
+	p.sent = append(p.sent, Sent{Channel: message.Channel, ReplyTo: message, Response: response})
+	return nil
+}
+
+func (p *Platform) React(message *models.Message, emoji string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.reactions = append(p.reactions, Reaction{Message: message, Emoji: emoji})
+	return nil
+}
+
+func (p *Platform) Typing(channel string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.typing = append(p.typing, channel)
+	return nil
+}
+
+// NoPrivate makes ephemeral replies fail with platform.ErrNotPrivate, as
+// on platforms that cannot reply to the author alone.
+func (p *Platform) NoPrivate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.noPrivate = true
+}
+
+// Reload records that the bot applied a reloaded config.
+func (p *Platform) Reload() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.reloads++
+	return nil
+}
+
+// Reloads returns how many times the bot applied a reloaded config.
+func (p *Platform) Reloads() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.reloads
+}
+
+// Connected is closed once the bot has connected the platform.
+func (p *Platform) Connected() <-chan struct{} {
+	return p.connected
+}
+
+// Drop tells the bot the connection was lost for good with err, as adapters
+// do once they cannot reach their platform. The next calls to Connect fail
+// with connectErrs before one succeeds.
+func (p *Platform) Drop(err error, connectErrs ...error) {
+	p.FailConnect(connectErrs...)
+	p.handler.HandleState(p, platform.StateDisconnected, err)
+}
+
+// FailConnect makes the next calls to Connect fail with errs before one
+// succeeds.
+func (p *Platform) FailConnect(errs ...error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.connectErrs = append(p.connectErrs, errs...)
+}
+
+// Message delivers message to the bot and returns what was sent while it
+// was handled. Platform, ID and RecievedAt are filled in when empty.
+func (p *Platform) Message(message *models.Message) []Sent {
+	p.mu.Lock()
+	p.nextID++
+	if message.ID == "" {
+		message.ID = fmt.Sprint(p.nextID)
+	}
+	if message.Platform == "" {
+		message.Platform = p.name
+	}
+	if message.RecievedAt.IsZero() {
+		message.RecievedAt = time.Now()
+	}
+	before := len(p.sent)
+	p.mu.Unlock()
+
+	p.handler.HandleMessage(p.ctx, p, message)
+
+	return p.sentSince(before)
+}
+
+// Reaction delivers a reaction and returns what was sent while it was
+// handled. ReactorID defaults to Reactor.
+func (p *Platform) Reaction(reaction *models.MessageReaction) []Sent {
+	p.mu.Lock()
+	if reaction.ReactorID == "" {
+		reaction.ReactorID = reaction.Reactor
+	}
+	before := len(p.sent)
+	p.mu.Unlock()
+
+	p.handler.HandleReaction(p.ctx, p, reaction)
+
+	return p.sentSince(before)
+}
+
+// Sent returns every response sent so far.
+func (p *Platform) Sent() []Sent {
+	return p.sentSince(0)
+}
+
+// Reactions returns every reaction the bot added so far.
+func (p *Platform) Reactions() []Reaction {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Reaction(nil), p.reactions...)
+}
+
+// TypingIn returns the channels a typing indicator was shown in so far.
+func (p *Platform) TypingIn() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string(nil), p.typing...)
+}
+
+func (p *Platform) sentSince(i int) []Sent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Sent(nil), p.sent[i:]...)
+}
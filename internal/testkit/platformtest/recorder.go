@@ -0,0 +1,122 @@
+// Package platformtest helps test platform adapters against stubs of their
+// services. It is kept apart from testkit, which runs a whole bot and so
+// imports every adapter.
+package platformtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/distrobyte/gerry/internal/models"
+	"github.com/distrobyte/gerry/internal/platform"
+)
+
+// waitTimeout is how long a Recorder waits for an event before failing
+const waitTimeout = 5 * time.Second
+
+// StateChange is a connection state reported by a platform adapter.
+type StateChange struct {
+	State platform.State
+	Err   error
+}
+
+// Recorder is a platform.Handler that records the events a platform adapter
+// produces, for tests of adapters against a stub of their service. Messages
+// are replied to with the response it was created with, if any.
+type Recorder struct {
+	t     testing.TB
+	reply *models.Response
+
+	messages  chan *models.Message
+	reactions chan *models.MessageReaction
+	states    chan StateChange
+}
+
+// NewRecorder returns a recorder replying to every message with reply, or
+// to none when it is nil.
+func NewRecorder(t testing.TB, reply *models.Response) *Recorder {
+	return &Recorder{
+		t:         t,
+		reply:     reply,
+		messages:  make(chan *models.Message, 16),
+		reactions: make(chan *models.MessageReaction, 16),
+		states:    make(chan StateChange, 16),
+	}
+}
+
+func (r *Recorder) HandleMessage(ctx context.Context, p platform.Platform, message *models.Message) {
+	r.messages <- message
+	if r.reply != nil {
+		p.Reply(message, r.reply)
+	}
+}
+
+func (r *Recorder) HandleReaction(ctx context.Context, p platform.Platform, reaction *models.MessageReaction) {
+	r.reactions <- reaction
+}
+
+func (r *Recorder) HandleState(p platform.Platform, state platform.State, err error) {
+	r.states <- StateChange{State: state, Err: err}
+}
+
+// Message waits for the next message the adapter delivered.
+func (r *Recorder) Message() *models.Message {
+	r.t.Helper()
+
+	select {
+	case message := <-r.messages:
+		return message
+	case <-time.After(waitTimeout):
+		r.t.Fatal("no message was handled")
+		return nil
+	}
+}
+
+// NoMessage checks the adapter delivers no message within wait.
+func (r *Recorder) NoMessage(wait time.Duration) {
+	r.t.Helper()
+
+	select {
+	case message := <-r.messages:
+		r.t.Errorf("unexpected message %+v", message)
+	case <-time.After(wait):
+	}
+}
+
+// Reaction waits for the next reaction the adapter delivered.
+func (r *Recorder) Reaction() *models.MessageReaction {
+	r.t.Helper()
+
+	select {
+	case reaction := <-r.reactions:
+		return reaction
+	case <-time.After(waitTimeout):
+		r.t.Fatal("no reaction was handled")
+		return nil
+	}
+}
+
+// NoReaction checks the adapter delivers no reaction within wait.
+func (r *Recorder) NoReaction(wait time.Duration) {
+	r.t.Helper()
+
+	select {
+	case reaction := <-r.reactions:
+		r.t.Errorf("unexpected reaction %+v", reaction)
+	case <-time.After(wait):
+	}
+}
+
+// State waits for the next connection state the adapter reported.
+func (r *Recorder) State() StateChange {
+	r.t.Helper()
+
+	select {
+	case change := <-r.states:
+		return change
+	case <-time.After(waitTimeout):
+		r.t.Fatal("no state was reported")
+		return StateChange{}
+	}
+}